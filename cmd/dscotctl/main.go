@@ -9,8 +9,10 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"dscotctl/internal/config"
+	"dscotctl/internal/defaults"
 	"dscotctl/internal/deployer"
 	"dscotctl/internal/logging"
 )
@@ -34,29 +36,493 @@ func main() {
 
 	ctx := withSignals(context.Background())
 
-	// Parse flags
-	fs := flag.NewFlagSet(BinaryName, flag.ExitOnError)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "-version", "--version", "version":
+		fmt.Printf("%s version %s (built %s)\n", BinaryName, Version, BuildTime)
+		return
+	case "-help", "--help", "-h", "help":
+		usage()
+		return
+	}
+
+	if err := runCommand(ctx, args); err != nil {
+		fmt.Fprintf(os.Stderr, "\nError:\n  %s\n\n", logging.Redact(formatError(err)))
+		os.Exit(1)
+	}
+}
+
+// command describes a CLI subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+// commands lists all subcommands in the order they are shown in the help text.
+var commands = []command{
+	{"deploy", "Deploy or converge the cluster", cmdDeploy},
+	{"teardown", "Tear down the cluster", cmdTeardown},
+	{"status", "Check cluster health (exits non-zero when degraded)", cmdStatus},
+	{"validate", "Validate the configuration file", cmdValidate},
+	{"plan", "Inspect live nodes and show what deploy would change", cmdPlan},
+	{"services deploy", "Redeploy service definitions only", cmdServicesDeploy},
+	{"certs renew", "Obtain or renew ACME certificates for NGINX_HOST virtual hosts", cmdCertsRenew},
+	{"storage status", "Show distributed storage status", cmdStorageStatus},
+	{"storage upgrade", "Upgrade MicroCeph to a new snap channel", cmdStorageUpgrade},
+	{"storage snapshots list", "List CephFS snapshots of a service's data", cmdStorageSnapshotsList},
+	{"storage snapshots restore", "Restore a service's data from a snapshot", cmdStorageSnapshotsRestore},
+	{"backup list", "List backups in the configured S3 target", cmdBackupList},
+	{"backup", "Back up service data and Swarm metadata to S3", cmdBackup},
+	{"restore", "Restore service data from an S3 backup", cmdRestore},
+	{"swarm backup", "Archive the Swarm Raft state from a manager", cmdSwarmBackup},
+	{"swarm restore", "Rebuild a Swarm that lost quorum around one manager", cmdSwarmRestore},
+	{"known-hosts forget", "Forget a node's recorded SSH host key (accept a changed key)", cmdKnownHostsForget},
+	{"node add", "Add a configured node to the cluster", cmdNodeAdd},
+	{"node remove", "Drain and remove a node from the cluster", cmdNodeRemove},
+	{"upgrade", "Upgrade OS and Docker packages one node at a time", cmdUpgrade},
+	{"monitor", "Serve cluster health as Prometheus metrics", cmdMonitor},
+}
+
+// runCommand resolves the subcommand (one or two words) and runs it.
+func runCommand(ctx context.Context, args []string) error {
+	// Legacy invocation without a subcommand ("dscotctl -configpath x.json")
+	if strings.HasPrefix(args[0], "-") {
+		logging.L().Warnw("⚠ running without a subcommand is deprecated, use 'deploy'")
+		return cmdDeploy(ctx, args)
+	}
+
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) < len(words) {
+			continue
+		}
+		matched := true
+		for i, w := range words {
+			if args[i] != w {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return c.run(ctx, args[len(words):])
+		}
+	}
+
+	usage()
+	return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+// newFlagSet creates a flag set for a subcommand with the common -configpath flag.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(BinaryName+" "+name, flag.ExitOnError)
 	configPath := fs.String("configpath", "", "Path to JSON configuration file (default: dscotctl.json in binary directory)")
+	return fs, configPath
+}
+
+// loadConfig loads the configuration and logs a summary.
+func loadConfig(configPath string) (*config.Config, error) {
+	log := logging.L()
+
+	log.Infow("loading configuration", "configPath", configPath)
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infow("configuration loaded successfully",
+		"configFile", cfg.ConfigPath,
+		"clusterName", cfg.GlobalSettings.ClusterName,
+		"nodes", len(cfg.Nodes),
+		"overlayProvider", cfg.GlobalSettings.OverlayProvider,
+	)
+	return cfg, nil
+}
+
+func cmdDeploy(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("deploy")
 	dryRun := fs.Bool("dry-run", false, "Validate configuration without deploying")
-	showHelp := fs.Bool("help", false, "Show help message")
-	showVersion := fs.Bool("version", false, "Show version information")
+	resume := fs.Bool("resume", false, "Skip phases completed by the previous run of an unchanged config")
+	fromPhase := fs.String("from-phase", "", "Start at this phase (e.g. 9), skipping earlier phases")
+	onlyPhase := fs.String("only-phase", "", "Run only this phase (e.g. 8b)")
+	_ = fs.Parse(args)
+
+	opts := deployer.DeployOptions{Resume: *resume, FromPhase: *fromPhase, OnlyPhase: *onlyPhase}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
 
-	if err := fs.Parse(os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
-		os.Exit(1)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
 	}
 
-	if *showVersion {
-		fmt.Printf("%s version %s (built %s)\n", BinaryName, Version, BuildTime)
-		return
+	if cfg.GetDecommissioning().Enabled {
+		return fmt.Errorf("globalSettings.decommissioning.enabled is set; use '%s teardown' to decommission the cluster", BinaryName)
 	}
 
-	if *showHelp {
-		usage()
-		return
+	if *dryRun {
+		logging.L().Infow("dry-run mode: configuration is valid")
+		return nil
+	}
+
+	log := logging.L().With("command", "deploy")
+	log.Infow("deployment mode",
+		"preScripts", len(cfg.GlobalSettings.PreScripts),
+		"postScripts", len(cfg.GlobalSettings.PostScripts),
+		"storageEnabled", cfg.IsStorageEnabled(),
+		"journal", deployer.JournalPath(cfg.ConfigPath),
+		"scriptReport", deployer.ScriptReportPath(cfg.ConfigPath),
+	)
+
+	if err := deployer.DeployWithOptions(ctx, cfg, opts); err != nil {
+		log.Errorw("deployment failed")
+		return err
+	}
+
+	log.Infow("✅ Deployment completed successfully!")
+	return nil
+}
+
+func cmdTeardown(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("teardown")
+	disconnectOverlays := fs.Bool("disconnect-overlays", false, "Disconnect overlay networks (overrides decommissioning.disconnectOverlays)")
+	removeStorage := fs.Bool("remove-storage", false, "Remove the distributed storage cluster (overrides decommissioning.removeStorage)")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Explicit flags override the decommissioning settings in the config file
+	decom := cfg.GetDecommissioning()
+	flagSet := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { flagSet[f.Name] = true })
+	if flagSet["disconnect-overlays"] {
+		decom.DisconnectOverlays = *disconnectOverlays
+	}
+	if flagSet["remove-storage"] {
+		decom.RemoveStorage = removeStorage
 	}
 
-	run(ctx, *configPath, *dryRun)
+	log := logging.L().With("command", "teardown")
+	log.Infow("teardown mode",
+		"disconnectOverlays", decom.DisconnectOverlays,
+		"removeStorage", decom.ShouldRemoveStorage(cfg.GetDistributedStorage()),
+		"removeDockerSwarm", decom.ShouldRemoveDockerSwarm(),
+	)
+
+	if err := deployer.Teardown(ctx, cfg, decom.DisconnectOverlays); err != nil {
+		log.Errorw("teardown failed")
+		return err
+	}
+
+	log.Infow("✅ Teardown completed successfully!")
+	return nil
+}
+
+func cmdStatus(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("status")
+	asJSON := fs.Bool("json", false, "Print the status as JSON")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	report, err := deployer.Status(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Healthy {
+		return fmt.Errorf("cluster is degraded: %d problem(s) found", len(report.Problems))
+	}
+	return nil
+}
+
+func cmdValidate(_ context.Context, args []string) error {
+	fs, configPath := newFlagSet("validate")
+	_ = fs.Parse(args)
+
+	if _, err := loadConfig(*configPath); err != nil {
+		return err
+	}
+	logging.L().Infow("✅ configuration is valid")
+	return nil
+}
+
+func cmdPlan(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("plan")
+	asJSON := fs.Bool("json", false, "Print the plan as JSON")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	plan, err := deployer.BuildPlan(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return plan.WriteJSON(os.Stdout)
+	}
+	return plan.WriteText(os.Stdout)
+}
+
+func cmdServicesDeploy(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("services deploy")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.DeployServices(ctx, cfg)
+}
+
+func cmdCertsRenew(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("certs renew")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.RenewCertificates(ctx, cfg)
+}
+
+func cmdStorageStatus(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("storage status")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	status, err := deployer.StorageStatus(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if !status.Healthy {
+		return fmt.Errorf("storage cluster is not healthy")
+	}
+	return nil
+}
+
+func cmdStorageUpgrade(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("storage upgrade")
+	channel := fs.String("channel", "", "Target snap channel (default: snapChannel from the configuration)")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.UpgradeStorage(ctx, cfg, *channel)
+}
+
+func cmdStorageSnapshotsList(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("storage snapshots list")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s storage snapshots list -configpath <config.json> <service>", BinaryName)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := deployer.ListSnapshots(ctx, cfg, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		fmt.Println(snapshot)
+	}
+	return nil
+}
+
+func cmdStorageSnapshotsRestore(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("storage snapshots restore")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: %s storage snapshots restore -configpath <config.json> <service> <snapshot>", BinaryName)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.RestoreSnapshot(ctx, cfg, fs.Arg(0), fs.Arg(1))
+}
+
+func cmdBackup(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("backup")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := deployer.Backup(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Println(manifest.ID)
+	return nil
+}
+
+func cmdBackupList(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("backup list")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := deployer.ListBackups(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		encrypted := ""
+		if m.Encrypted {
+			encrypted = " (encrypted)"
+		}
+		fmt.Printf("%s  %s%s\n", m.ID, strings.Join(m.Stacks, ","), encrypted)
+	}
+	return nil
+}
+
+func cmdRestore(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("restore")
+	backupID := fs.String("backup", "", "Backup ID to restore (default: latest)")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.Restore(ctx, cfg, *backupID, fs.Args())
+}
+
+func cmdSwarmBackup(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("swarm backup")
+	output := fs.String("output", "", "Archive file to write (default: swarm-<clusterName>-<timestamp>.tar.gz)")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	path, err := deployer.BackupSwarmState(ctx, cfg, *output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func cmdSwarmRestore(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("swarm restore")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: %s swarm restore -configpath <config.json> <node> [archive]", BinaryName)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.RecoverSwarm(ctx, cfg, fs.Arg(0), fs.Arg(1))
+}
+
+func cmdKnownHostsForget(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet(BinaryName+" known-hosts forget", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s known-hosts forget <host>", BinaryName)
+	}
+	return deployer.ForgetHostKey(fs.Arg(0))
+}
+
+func cmdNodeAdd(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("node add")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s node add -configpath <config.json> <node>", BinaryName)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.AddNode(ctx, cfg, fs.Arg(0))
+}
+
+func cmdNodeRemove(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("node remove")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s node remove -configpath <config.json> <node>", BinaryName)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.RemoveNode(ctx, cfg, fs.Arg(0))
+}
+
+func cmdUpgrade(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("upgrade")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.Upgrade(ctx, cfg)
+}
+
+func cmdMonitor(ctx context.Context, args []string) error {
+	fs, configPath := newFlagSet("monitor")
+	listen := fs.String("listen", defaults.MonitorListenAddr, "Listen address of the /metrics endpoint")
+	interval := fs.Duration("interval", defaults.MonitorIntervalSeconds*time.Second, "Time between two health collections")
+	concurrency := fs.Int("concurrency", defaults.StatusConcurrency, "Number of nodes checked in parallel")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return deployer.Monitor(ctx, cfg, deployer.MonitorOptions{
+		ListenAddr:  *listen,
+		Interval:    *interval,
+		Concurrency: *concurrency,
+	})
 }
 
 func withSignals(parent context.Context) context.Context {
@@ -106,108 +572,92 @@ func formatError(err error) string {
 }
 
 func usage() {
+	var cmds strings.Builder
+	for _, c := range commands {
+		fmt.Fprintf(&cmds, "  %-26s %s\n", c.name, c.usage)
+	}
+
 	fmt.Fprintf(os.Stderr, `%s - Docker Swarm Cluster Orchestration Tool
 Version: %s (built %s)
 
 Deploy and manage Docker Swarm clusters with distributed storage (MicroCeph) via SSH.
 
 Usage:
-  %s [flags]
+  %s <command> [flags]
 
-Flags:
+Commands:
+%s
+Common Flags:
   -configpath string
         Path to JSON configuration file (default: dscotctl.json in binary directory)
-  -dry-run
-        Validate configuration without deploying
-  -version
-        Show version information
-  -help
-        Show this help message
-
-Execution Mode:
-  The execution mode is controlled by the configuration file:
 
-  Deploy (default):
-    "globalSettings.decommissioning.enabled": false
-
-  Decommission/Teardown:
-    "globalSettings.decommissioning.enabled": true
-    "globalSettings.decommissioning.disconnectOverlays": true/false
-    "globalSettings.decommissioning.removeStorage": true/false (defaults to forceRecreation)
-    "globalSettings.decommissioning.removeDockerSwarm": true/false (default: true)
+Command Flags:
+  deploy -dry-run
+        Validate configuration without deploying
+  deploy -resume
+        Skip phases completed by the previous run (config must be unchanged)
+  deploy -from-phase string
+        Start at this phase, skipping earlier ones (1, 2, 2.5, 3 ... 8b, 8c, 9 ... 12)
+  deploy -only-phase string
+        Run only this phase (Phase 1 always runs to open SSH connections)
+  monitor -concurrency int
+        Number of nodes checked in parallel (default 8)
+  monitor -interval duration
+        Time between two health collections (default 1m0s)
+  monitor -listen string
+        Listen address of the /metrics endpoint (default ":9469")
+  plan -json
+        Print the plan as JSON (for review in merge requests)
+  restore -backup string
+        Backup ID to restore (default: latest); pass stack names to restore only those
+  status -json
+        Print the status as JSON
+  storage upgrade -channel string
+        Target MicroCeph snap channel (default: snapChannel from the configuration)
+  swarm backup -output string
+        Archive file to write (default: swarm-<clusterName>-<timestamp>.tar.gz)
+  teardown -disconnect-overlays
+        Disconnect overlay networks (default: decommissioning.disconnectOverlays)
+  teardown -remove-storage
+        Remove the storage cluster (default: decommissioning.removeStorage)
+
+Other:
+  -version    Show version information
+  -help       Show this help message
 
 Examples:
   # Deploy cluster
-  %s -configpath cluster.json
+  %s deploy -configpath cluster.json
 
-  # Validate configuration
-  %s -configpath cluster.json -dry-run
+  # Resume a failed deployment
+  %s deploy -configpath cluster.json -resume
 
-For configuration examples, see dscotctl.json.example
+  # Show what deploy would change on the live cluster
+  %s plan -configpath cluster.json
 
-`, BinaryName, Version, BuildTime, BinaryName, BinaryName, BinaryName)
-}
+  # Validate configuration
+  %s validate -configpath cluster.json
 
-func run(ctx context.Context, configPath string, dryRun bool) {
-	log := logging.L()
+  # Tear down the cluster including storage
+  %s teardown -configpath cluster.json -remove-storage
 
-	// Load configuration
-	log.Infow("loading configuration", "configPath", configPath)
-	cfg, err := config.Load(configPath)
-	if err != nil {
-		log.Errorw("failed to load configuration")
-		fmt.Fprintf(os.Stderr, "\nError:\n  %s\n\n", formatError(err))
-		os.Exit(1)
-	}
+  # Remove a node
+  %s node remove -configpath cluster.json node3.example.com
 
-	ds := cfg.GetDistributedStorage()
-	decom := cfg.GetDecommissioning()
+  # Patch all nodes without downtime
+  %s upgrade -configpath cluster.json
 
-	log.Infow("configuration loaded successfully",
-		"configFile", cfg.ConfigPath,
-		"clusterName", cfg.GlobalSettings.ClusterName,
-		"nodes", len(cfg.Nodes),
-		"overlayProvider", cfg.GlobalSettings.OverlayProvider,
-		"decommissioning", decom.Enabled,
-	)
+  # Restore a service's data from a snapshot
+  %s storage snapshots restore -configpath cluster.json N8N scheduled-2025-01-01-00_00_00_UTC
 
-	if dryRun {
-		log.Infow("dry-run mode: configuration is valid")
-		return
-	}
+  # Back up to S3 and restore only the N8N stack from the latest backup
+  %s backup -configpath cluster.json
+  %s restore -configpath cluster.json N8N
 
-	// Check execution mode from config
-	if decom.Enabled {
-		// Decommissioning mode
-		log = log.With("command", "decommission")
-		log.Infow("decommissioning mode enabled",
-			"disconnectOverlays", decom.DisconnectOverlays,
-			"removeStorage", decom.ShouldRemoveStorage(ds),
-			"removeDockerSwarm", decom.ShouldRemoveDockerSwarm(),
-		)
-
-		if err := deployer.Teardown(ctx, cfg, decom.DisconnectOverlays); err != nil {
-			log.Errorw("decommissioning failed")
-			fmt.Fprintf(os.Stderr, "\nError:\n  %s\n\n", formatError(err))
-			os.Exit(1)
-		}
+  # Rebuild the Swarm on node1 from a Raft state backup after losing quorum
+  %s swarm restore -configpath cluster.json node1.example.com swarm-prod-20250101T000000Z.tar.gz
 
-		log.Infow("✅ Decommissioning completed successfully!")
-	} else {
-		// Deployment mode
-		log = log.With("command", "deploy")
-		log.Infow("deployment mode",
-			"preScripts", len(cfg.GlobalSettings.PreScripts),
-			"postScripts", len(cfg.GlobalSettings.PostScripts),
-			"storageEnabled", ds.Enabled,
-		)
-
-		if err := deployer.Deploy(ctx, cfg); err != nil {
-			log.Errorw("deployment failed")
-			fmt.Fprintf(os.Stderr, "\nError:\n  %s\n\n", formatError(err))
-			os.Exit(1)
-		}
+For configuration examples, see dscotctl.json.example
 
-		log.Infow("✅ Deployment completed successfully!")
-	}
+`, BinaryName, Version, BuildTime, BinaryName, cmds.String(), BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName, BinaryName)
 }
@@ -5,9 +5,13 @@ go 1.24.0
 toolchain go1.24.4
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
+	filippo.io/age v1.2.1
+	github.com/google/uuid v1.6.0
+	golang.org/x/crypto v0.45.0
+)
+
+require (
 	go.uber.org/multierr v1.10.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
-	golang.org/x/crypto v0.45.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
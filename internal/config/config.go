@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
 )
 
 // Config represents the cluster configuration loaded from JSON.
@@ -91,6 +93,51 @@ type MicroCephProviderConfig struct {
 	// If empty, credentials are only logged to console.
 	// Default: "" (no file written)
 	S3CredentialsFile string `json:"s3CredentialsFile"`
+
+	// EnableRBD creates an RBD pool for block volumes and installs the RBD
+	// Docker volume plugin on every Swarm node. Services opt in with the
+	// STORAGE_CLASS: rbd header; databases perform much better on RBD than on CephFS.
+	// Default: false
+	EnableRBD bool `json:"enableRbd"`
+
+	// RBDPoolName is the Ceph pool that RBD volumes are created in.
+	// Default: "docker-swarm-rbd"
+	RBDPoolName string `json:"rbdPoolName"`
+
+	// RBDVolumePlugin is the Docker managed plugin that provides the RBD volume
+	// driver. It is installed under the alias "rbd".
+	// Default: "wetopi/rbd:latest"
+	RBDVolumePlugin string `json:"rbdVolumePlugin"`
+
+	// Snapshots schedules CephFS snapshots with retention, for the filesystem
+	// root or for individual service data directories.
+	// Default: none
+	Snapshots []SnapshotSchedule `json:"snapshots"`
+}
+
+// SnapshotSchedule schedules CephFS snapshots of one directory. Each count is
+// the number of snapshots kept for that period; 0 disables the period.
+type SnapshotSchedule struct {
+	// Service snapshots the service's data directory (<mountPath>/data/<service>).
+	// Empty snapshots the whole filesystem.
+	Service string `json:"service"`
+
+	// Hourly is the number of hourly snapshots to keep.
+	Hourly int `json:"hourly"`
+
+	// Daily is the number of daily snapshots to keep.
+	Daily int `json:"daily"`
+
+	// Weekly is the number of weekly snapshots to keep.
+	Weekly int `json:"weekly"`
+}
+
+// Path returns the scheduled directory relative to the CephFS root.
+func (s SnapshotSchedule) Path() string {
+	if s.Service == "" {
+		return "/"
+	}
+	return "/" + defaults.ServiceDataSubdir + "/" + s.Service
 }
 
 // StorageProviders contains provider-specific configurations.
@@ -114,6 +161,66 @@ type EligibleDisks struct {
 	ExclusionExpression []string `json:"exclusionExpression"`
 }
 
+// PoolPolicy controls replication and placement of the storage pools.
+// Zero values keep the provider's automatic settings; MicroCeph picks the
+// replica count and failure domain from the number of OSD hosts.
+type PoolPolicy struct {
+	// Size is the number of copies kept of each object in replicated pools.
+	// Default: 0 (provider default)
+	Size int `json:"size"`
+
+	// MinSize is the number of copies that must be available for I/O to continue.
+	// Default: 0 (provider default)
+	MinSize int `json:"minSize"`
+
+	// FailureDomain is the CRUSH bucket type that copies are spread across:
+	// "osd", "host", "rack", "datacenter" or "region". For the last three every
+	// OSD node needs a node label of the same name (e.g. "rack": "r1"), unless
+	// crushTopology derives it from geolocation.
+	// Default: "" (provider default, or chosen from crushTopology when enabled)
+	FailureDomain string `json:"failureDomain"`
+
+	// ErasureCoding stores CephFS file data in an erasure-coded pool.
+	ErasureCoding ErasureCodingPolicy `json:"erasureCoding"`
+
+	// AutoscaleMode is the placement group autoscaler mode: "on", "warn" or "off".
+	// Default: "" (provider default, "on")
+	AutoscaleMode string `json:"autoscaleMode"`
+}
+
+// CrushTopology places OSD hosts into a CRUSH hierarchy of
+// root > region > datacenter > rack > host so copies can be spread across
+// locations. Each level comes from the node label of the same name.
+type CrushTopology struct {
+	// Enabled builds the CRUSH hierarchy from node labels "region",
+	// "datacenter" and "rack". Levels without a label are skipped.
+	// Default: false
+	Enabled bool `json:"enabled"`
+
+	// UseGeolocation fills in missing region and datacenter labels from the
+	// detected geolocation: region is "<country>-<region>", datacenter adds the city.
+	// Default: false
+	UseGeolocation bool `json:"useGeolocation"`
+}
+
+// ErasureCodingPolicy configures an erasure-coded data pool for CephFS.
+// Metadata and the default data pool stay replicated as Ceph recommends;
+// new files are written to the erasure-coded pool.
+type ErasureCodingPolicy struct {
+	// Enabled adds an erasure-coded data pool to the filesystem.
+	// Default: false
+	Enabled bool `json:"enabled"`
+
+	// DataChunks (k) is the number of data chunks per object.
+	// Default: 2
+	DataChunks int `json:"dataChunks"`
+
+	// CodingChunks (m) is the number of coding chunks per object; this many
+	// failure domains can be lost without losing data.
+	// Default: 1
+	CodingChunks int `json:"codingChunks"`
+}
+
 // DistributedStorage contains distributed storage configuration.
 // This is under GlobalSettings to keep all cluster-wide settings together.
 type DistributedStorage struct {
@@ -135,6 +242,12 @@ type DistributedStorage struct {
 	// Default: "docker-swarm"
 	PoolName string `json:"poolName"`
 
+	// PoolPolicy controls replication, failure domain and erasure coding of the pools.
+	PoolPolicy PoolPolicy `json:"poolPolicy"`
+
+	// CrushTopology places OSD hosts into region/datacenter/rack CRUSH buckets.
+	CrushTopology CrushTopology `json:"crushTopology"`
+
 	// EligibleDisks defines disk selection criteria using regex patterns.
 	EligibleDisks EligibleDisks `json:"eligibleDisks"`
 
@@ -147,9 +260,12 @@ type ScriptConfig struct {
 	Enabled         bool              `json:"enabled"`         // Enable this script (must be true to execute)
 	ContinueOnError bool              `json:"continueOnError"` // Continue deployment if this script fails (default: false)
 	Name            string            `json:"name"`            // Script name/description
-	Source          string            `json:"source"`          // Local path or http/https URL
+	Source          string            `json:"source"`          // Local path (relative to the config file) or http/https URL
 	Parameters      string            `json:"parameters"`      // Script parameters/arguments
 	Conditions      []ScriptCondition `json:"conditions"`      // Conditions for script execution (all must match, empty = run on all nodes)
+	TimeoutSeconds  int               `json:"timeoutSeconds"`  // Kill the script after this many seconds (0 = no timeout)
+	Environment     map[string]string `json:"environment"`     // Environment variables set for the script
+	RunAs           string            `json:"runAs"`           // Run the script as this user via sudo (default: SSH user)
 }
 
 // ScriptCondition represents a condition for script execution.
@@ -162,8 +278,8 @@ type ScriptCondition struct {
 
 // Decommissioning contains settings for cluster teardown/decommissioning.
 type Decommissioning struct {
-	// Enabled triggers cluster teardown instead of deployment.
-	// When true, the cluster will be torn down according to the settings below.
+	// Enabled marks the cluster as being decommissioned. Teardown is only run
+	// by the "teardown" command; "deploy" refuses to run while this is true.
 	// Default: false
 	Enabled bool `json:"enabled"`
 
@@ -196,6 +312,97 @@ type GlobalSettings struct {
 	PostScripts                    []ScriptConfig     `json:"postScripts"`                    // Scripts to execute after deployment
 	RemoveSSHPublicKeyOnCompletion bool               `json:"removeSSHPublicKeyOnCompletion"` // Remove SSH public key from nodes on completion (default: false)
 	Decommissioning                Decommissioning    `json:"decommissioning"`                // Cluster teardown/decommissioning settings
+	Backup                         BackupConfig       `json:"backup"`                         // Off-cluster backup target for the backup/restore commands
+	ACME                           ACMEConfig         `json:"acme"`                           // ACME certificates for EdgeLoadBalancer virtual hosts
+	EdgeAuth                       EdgeAuthConfig     `json:"edgeAuth"`                       // OIDC authentication for services with NGINX_AUTH: oidc
+}
+
+// EdgeAuthConfig configures the OIDC provider used by the EdgeAuth companion
+// (oauth2-proxy) that protects EdgeLoadBalancer locations of services with
+// NGINX_AUTH: oidc.
+type EdgeAuthConfig struct {
+	// Enabled deploys the EdgeAuth companion when a service uses NGINX_AUTH: oidc.
+	Enabled bool `json:"enabled"`
+	// IssuerURL is the OIDC issuer, e.g. https://login.example.com/realms/main.
+	IssuerURL string `json:"issuerUrl"`
+	// ClientID and ClientSecret identify the EdgeAuth client at the provider.
+	// ClientSecret accepts secret references.
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// CookieSecret encrypts the session cookies (16, 24 or 32 characters,
+	// secret references supported). Generated and kept on shared storage when empty.
+	CookieSecret string `json:"cookieSecret"`
+	// EmailDomains limits sign-in to these e-mail domains. Default: ["*"]
+	EmailDomains []string `json:"emailDomains"`
+	// AllowedGroups limits sign-in to members of these groups (optional).
+	AllowedGroups []string `json:"allowedGroups"`
+	// Scope is the OIDC scope requested. Default: "openid email profile"
+	Scope string `json:"scope"`
+	// Image is the oauth2-proxy image. Default: see defaults.EdgeAuthImage
+	Image string `json:"image"`
+	// InsecureSkipVerify skips TLS verification of the provider. Only for
+	// local mock providers with a self-signed certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// ACMEConfig configures certificate issuance for the hostnames that services
+// declare with NGINX_HOST, using the ACME HTTP-01 challenge.
+type ACMEConfig struct {
+	// Enabled obtains and renews certificates on every service deployment.
+	Enabled bool `json:"enabled"`
+	// Email is the contact address registered with the ACME account (optional).
+	Email string `json:"email"`
+	// DirectoryURL is the ACME directory, e.g. a local Pebble instance for testing.
+	// Default: Let's Encrypt production
+	DirectoryURL string `json:"directoryUrl"`
+	// RenewBeforeDays renews a certificate this many days before it expires.
+	// Default: 30
+	RenewBeforeDays int `json:"renewBeforeDays"`
+	// InsecureSkipVerify skips TLS verification of the ACME directory. Only for
+	// test servers such as Pebble that use a self-signed certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// BackupConfig configures off-cluster backups to an S3-compatible endpoint
+// (AWS S3, MinIO, or the RADOS Gateway of another cluster).
+type BackupConfig struct {
+	// Endpoint is the S3 endpoint URL, e.g. "https://s3.example.com" or "http://minio.local:9000".
+	Endpoint string `json:"endpoint"`
+	// Region is the S3 signing region.
+	// Default: "us-east-1"
+	Region string `json:"region"`
+	// Bucket is the bucket backups are written to. It is created if missing.
+	Bucket string `json:"bucket"`
+	// Prefix is the key prefix for this cluster's backups.
+	// Default: the cluster name
+	Prefix string `json:"prefix"`
+	// AccessKey and SecretKey are the S3 credentials (secret references are supported).
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	// EncryptionPassphrase encrypts every archive with age before upload
+	// (secret references are supported). Empty uploads unencrypted archives.
+	EncryptionPassphrase string `json:"encryptionPassphrase"`
+	// Retention is the number of backups to keep; older ones are deleted after a successful backup.
+	// Default: 7
+	Retention int `json:"retention"`
+	// Stacks limits the service data directories that are backed up.
+	// Default: all directories under <mountPath>/data (Portainer is always included)
+	Stacks []string `json:"stacks"`
+}
+
+// ValidateBackup checks that the backup target is configured.
+func (b *BackupConfig) ValidateBackup() error {
+	switch {
+	case b.Endpoint == "":
+		return fmt.Errorf("globalSettings.backup.endpoint is required")
+	case b.Bucket == "":
+		return fmt.Errorf("globalSettings.backup.bucket is required")
+	case b.AccessKey == "" || b.SecretKey == "":
+		return fmt.Errorf("globalSettings.backup.accessKey and secretKey are required")
+	case b.Retention < 0:
+		return fmt.Errorf("globalSettings.backup.retention must not be negative")
+	}
+	return nil
 }
 
 // KeepalivedConfig contains global Keepalived/VRRP settings for high availability.
@@ -236,6 +443,7 @@ type NodeConfig struct {
 	PrivateKeyPassword     string `json:"privateKeyPassword"`     // Password for encrypted private key (optional, blank = no passphrase)
 	UseSSHAutomaticKeyPair bool   `json:"useSSHAutomaticKeyPair"` // Use automatically generated SSH key pair (default: false)
 	SSHPort                int    `json:"sshPort"`                // SSH port (default: 22)
+	HostKeyFingerprint     string `json:"hostKeyFingerprint"`     // Pinned SSH host key fingerprint, e.g. "SHA256:..." (optional)
 
 	// Node Role Settings
 	Role string `json:"role"` // "manager", "worker", or "both" (required)
@@ -302,17 +510,32 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
+	// Decrypt age/SOPS-encrypted config files
+	data, err = decryptConfig(configPath, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config file %s: %w", configPath, err)
+	}
+
 	// Parse JSON
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
 	}
 
+	// Resolve env:/file:/cmd: secret references
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets in %s: %w", configPath, err)
+	}
+
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	for _, warning := range cfg.Warnings() {
+		logging.L().Warnw("⚠ " + warning)
+	}
+
 	// Apply defaults
 	cfg.ApplyDefaults()
 
@@ -328,6 +551,15 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// ResolvePath resolves a local path from the configuration. Relative paths
+// are taken relative to the directory containing the configuration file.
+func (c *Config) ResolvePath(p string) string {
+	if p == "" || filepath.IsAbs(p) || c.ConfigPath == "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(c.ConfigPath), p)
+}
+
 // Validate validates the configuration.
 func (c *Config) Validate() error {
 	if len(c.Nodes) == 0 {
@@ -357,9 +589,202 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one manager node is required")
 	}
 
+	for _, scripts := range [][]ScriptConfig{c.GlobalSettings.PreScripts, c.GlobalSettings.PostScripts} {
+		for _, script := range scripts {
+			if script.TimeoutSeconds < 0 {
+				return fmt.Errorf("script %s: timeoutSeconds must not be negative", script.Name)
+			}
+			for key := range script.Environment {
+				if !envVarNamePattern.MatchString(key) {
+					return fmt.Errorf("script %s: invalid environment variable name %q", script.Name, key)
+				}
+			}
+		}
+	}
+
+	if acme := c.GlobalSettings.ACME; acme.Enabled {
+		if !strings.HasPrefix(acme.DirectoryURL, "https://") && !strings.HasPrefix(acme.DirectoryURL, "http://") {
+			return fmt.Errorf("globalSettings.acme.directoryUrl must be an http(s) URL")
+		}
+		if acme.RenewBeforeDays < 0 {
+			return fmt.Errorf("globalSettings.acme.renewBeforeDays must not be negative")
+		}
+	}
+
+	if edgeAuth := c.GlobalSettings.EdgeAuth; edgeAuth.Enabled {
+		if !strings.HasPrefix(edgeAuth.IssuerURL, "https://") && !strings.HasPrefix(edgeAuth.IssuerURL, "http://") {
+			return fmt.Errorf("globalSettings.edgeAuth.issuerUrl must be an http(s) URL")
+		}
+		if edgeAuth.ClientID == "" || edgeAuth.ClientSecret == "" {
+			return fmt.Errorf("globalSettings.edgeAuth.clientId and clientSecret are required")
+		}
+		switch len(edgeAuth.CookieSecret) {
+		case 0, 16, 24, 32:
+		default:
+			return fmt.Errorf("globalSettings.edgeAuth.cookieSecret must be 16, 24 or 32 characters")
+		}
+	}
+
+	if c.IsStorageEnabled() {
+		if err := c.validatePoolPolicy(); err != nil {
+			return err
+		}
+		if err := c.validateSnapshots(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Warnings returns configuration problems that do not prevent a deployment
+// but weaken the Swarm's fault tolerance.
+func (c *Config) Warnings() []string {
+	var warnings []string
+
+	var managers []NodeConfig
+	for _, node := range c.Nodes {
+		if node.IsEnabled() && (node.Role == "manager" || node.Role == "both") {
+			managers = append(managers, node)
+		}
+	}
+
+	n := len(managers)
+	if n > 0 && n%2 == 0 {
+		warnings = append(warnings, fmt.Sprintf("%d managers tolerate %d failure(s), the same as %d; use an odd number of managers", n, (n-1)/2, n-1))
+	}
+	if n > defaults.SwarmMaxRecommendedManagers {
+		warnings = append(warnings, fmt.Sprintf("%d managers exceed the recommended maximum of %d and slow down Raft consensus", n, defaults.SwarmMaxRecommendedManagers))
+	}
+
+	if region := sharedRegion(managers); region != "" {
+		warnings = append(warnings, fmt.Sprintf("all %d managers are in region %q; an outage of that region loses quorum", n, region))
+	}
+
+	return warnings
+}
+
+// sharedRegion returns the region label shared by all nodes, or "" when there
+// are fewer than two nodes, a node has no region label or the regions differ.
+func sharedRegion(nodes []NodeConfig) string {
+	if len(nodes) < 2 {
+		return ""
+	}
+	region := nodes[0].Labels["region"]
+	for _, node := range nodes[1:] {
+		if node.Labels["region"] != region {
+			return ""
+		}
+	}
+	return region
+}
+
+// validatePoolPolicy checks the storage pool policy against the storage nodes.
+func (c *Config) validatePoolPolicy() error {
+	policy := c.GlobalSettings.DistributedStorage.PoolPolicy
+	topology := c.GlobalSettings.DistributedStorage.CrushTopology
+
+	if policy.Size < 0 || policy.MinSize < 0 {
+		return fmt.Errorf("distributedStorage.poolPolicy: size and minSize must not be negative")
+	}
+	if policy.Size > 0 && policy.MinSize > policy.Size {
+		return fmt.Errorf("distributedStorage.poolPolicy: minSize (%d) must not exceed size (%d)", policy.MinSize, policy.Size)
+	}
+
+	switch policy.AutoscaleMode {
+	case "", "on", "warn", "off":
+	default:
+		return fmt.Errorf("distributedStorage.poolPolicy: autoscaleMode must be 'on', 'warn' or 'off'")
+	}
+
+	switch policy.FailureDomain {
+	case "", "osd", "host":
+	case "rack", "datacenter", "region":
+		// Each OSD node is placed in the bucket named by its label; geolocation
+		// can stand in for region and datacenter
+		if topology.Enabled && topology.UseGeolocation && policy.FailureDomain != "rack" {
+			break
+		}
+		for _, node := range c.GetStorageNodes() {
+			if node.Role == "manager" {
+				continue
+			}
+			if node.Labels[policy.FailureDomain] == "" {
+				return fmt.Errorf("distributedStorage.poolPolicy: failureDomain %q requires a %q label on node %s",
+					policy.FailureDomain, policy.FailureDomain, node.SSHFQDNorIP)
+			}
+		}
+	default:
+		return fmt.Errorf("distributedStorage.poolPolicy: failureDomain must be 'osd', 'host', 'rack', 'datacenter' or 'region'")
+	}
+
+	ec := policy.ErasureCoding
+	if ec.Enabled && (ec.DataChunks < 2 || ec.CodingChunks < 1) {
+		return fmt.Errorf("distributedStorage.poolPolicy.erasureCoding: dataChunks must be at least 2 and codingChunks at least 1")
+	}
+	if ec.Enabled {
+		// Erasure-coded pools default to one chunk per host
+		level := policy.FailureDomain
+		if level == "" {
+			level = "host"
+		}
+		if domains := c.failureDomainCount(level); domains > 0 && ec.DataChunks+ec.CodingChunks > domains {
+			return fmt.Errorf("distributedStorage.poolPolicy.erasureCoding: dataChunks + codingChunks (%d) exceeds the %d %s failure domain(s); each chunk needs its own %s",
+				ec.DataChunks+ec.CodingChunks, domains, level, level)
+		}
+	}
+
+	return nil
+}
+
+// failureDomainCount returns the number of distinct CRUSH buckets of a level
+// the OSD nodes are spread across, or 0 when it cannot be known from the
+// configuration (OSD counts, or levels derived from geolocation).
+func (c *Config) failureDomainCount(level string) int {
+	topology := c.GlobalSettings.DistributedStorage.CrushTopology
+	if level == "osd" || (topology.Enabled && topology.UseGeolocation && level != "rack" && level != "host") {
+		return 0
+	}
+
+	buckets := make(map[string]bool)
+	for _, node := range c.GetStorageNodes() {
+		if node.Role == "manager" {
+			continue
+		}
+		if level == "host" {
+			buckets[node.SSHFQDNorIP] = true
+		} else if name := node.Labels[level]; name != "" {
+			buckets[name] = true
+		}
+	}
+	return len(buckets)
+}
+
+// validateSnapshots checks the CephFS snapshot schedules.
+func (c *Config) validateSnapshots() error {
+	seen := make(map[string]bool)
+	for _, schedule := range c.GlobalSettings.DistributedStorage.Providers.MicroCeph.Snapshots {
+		path := schedule.Path()
+		if strings.ContainsAny(schedule.Service, "/ ") || schedule.Service == "." || schedule.Service == ".." {
+			return fmt.Errorf("snapshots: invalid service name %q", schedule.Service)
+		}
+		if seen[path] {
+			return fmt.Errorf("snapshots: %s is scheduled more than once", path)
+		}
+		seen[path] = true
+		if schedule.Hourly < 0 || schedule.Daily < 0 || schedule.Weekly < 0 {
+			return fmt.Errorf("snapshots %s: retention counts must not be negative", path)
+		}
+		if schedule.Hourly == 0 && schedule.Daily == 0 && schedule.Weekly == 0 {
+			return fmt.Errorf("snapshots %s: at least one of hourly, daily or weekly must be set", path)
+		}
+	}
+	return nil
+}
+
+// envVarNamePattern matches valid shell environment variable names.
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // ApplyDefaults applies default values to the configuration.
 // Default values are centralized in the defaults package.
 func (c *Config) ApplyDefaults() {
@@ -368,6 +793,39 @@ func (c *Config) ApplyDefaults() {
 		c.GlobalSettings.OverlayProvider = defaults.OverlayProviderNone
 	}
 
+	// Backup defaults
+	backup := &c.GlobalSettings.Backup
+	if backup.Region == "" {
+		backup.Region = defaults.BackupRegion
+	}
+	if backup.Prefix == "" {
+		backup.Prefix = c.GlobalSettings.ClusterName
+	}
+	if backup.Retention == 0 {
+		backup.Retention = defaults.BackupRetention
+	}
+
+	// ACME defaults
+	acme := &c.GlobalSettings.ACME
+	if acme.DirectoryURL == "" {
+		acme.DirectoryURL = defaults.ACMEDirectoryURL
+	}
+	if acme.RenewBeforeDays == 0 {
+		acme.RenewBeforeDays = defaults.ACMERenewBeforeDays
+	}
+
+	// EdgeAuth defaults
+	edgeAuth := &c.GlobalSettings.EdgeAuth
+	if len(edgeAuth.EmailDomains) == 0 {
+		edgeAuth.EmailDomains = []string{"*"}
+	}
+	if edgeAuth.Scope == "" {
+		edgeAuth.Scope = defaults.EdgeAuthScope
+	}
+	if edgeAuth.Image == "" {
+		edgeAuth.Image = defaults.EdgeAuthImage
+	}
+
 	// DistributedStorage defaults (now under GlobalSettings)
 	ds := &c.GlobalSettings.DistributedStorage
 	if ds.Provider == "" {
@@ -377,6 +835,14 @@ func (c *Config) ApplyDefaults() {
 		ds.PoolName = defaults.CephPoolName
 	}
 
+	ec := &ds.PoolPolicy.ErasureCoding
+	if ec.DataChunks == 0 {
+		ec.DataChunks = defaults.ErasureCodingDataChunks
+	}
+	if ec.CodingChunks == 0 {
+		ec.CodingChunks = defaults.ErasureCodingCodingChunks
+	}
+
 	// MicroCeph provider defaults
 	mc := &ds.Providers.MicroCeph
 	if mc.SnapChannel == "" {
@@ -396,6 +862,12 @@ func (c *Config) ApplyDefaults() {
 	if mc.RadosGatewayPort == 0 {
 		mc.RadosGatewayPort = defaults.RadosGatewayPort
 	}
+	if mc.RBDPoolName == "" {
+		mc.RBDPoolName = defaults.CephRBDPoolName
+	}
+	if mc.RBDVolumePlugin == "" {
+		mc.RBDVolumePlugin = defaults.RBDVolumePlugin
+	}
 
 	// Node defaults
 	for i := range c.Nodes {
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"dscotctl/internal/logging"
+)
+
+// Secret reference prefixes accepted by secret fields in the configuration.
+//
+//	env:NAME        value of environment variable NAME
+//	file:/path      contents of a file (trailing newline trimmed)
+//	cmd:command     stdout of a shell command (trailing newline trimmed)
+//
+// Any other value is used literally.
+const (
+	SecretPrefixEnv  = "env:"
+	SecretPrefixFile = "file:"
+	SecretPrefixCmd  = "cmd:"
+)
+
+// Environment variables naming the age identity file used to decrypt an
+// age-encrypted configuration file. DSCOTCTL_AGE_KEY_FILE takes precedence.
+const (
+	AgeKeyFileEnv     = "DSCOTCTL_AGE_KEY_FILE"
+	SopsAgeKeyFileEnv = "SOPS_AGE_KEY_FILE"
+)
+
+// ageHeaders are the prefixes of binary and armored age files.
+var ageHeaders = [][]byte{[]byte("age-encryption.org/v1"), []byte(armor.Header)}
+
+// overlayKeyPattern extracts setup/auth keys from overlay provider flags,
+// e.g. --setup-key "KEY" or --authkey='KEY'.
+var overlayKeyPattern = regexp.MustCompile(`--(?:setup-key|authkey|auth-key)(?:\s+|\s*=\s*)["']?([^"'\s]+)`)
+
+// ResolveSecret resolves a secret reference to its value.
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretPrefixEnv):
+		name := strings.TrimPrefix(ref, SecretPrefixEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, SecretPrefixFile):
+		path := strings.TrimPrefix(ref, SecretPrefixFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(ref, SecretPrefixCmd):
+		command := strings.TrimPrefix(ref, SecretPrefixCmd)
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret command failed: %w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	return ref, nil
+}
+
+// resolveSecrets replaces secret references in the configuration with their
+// values and registers every secret value for log redaction.
+func (c *Config) resolveSecrets() error {
+	resolve := func(field string, value *string) error {
+		if *value == "" {
+			return nil
+		}
+		resolved, err := ResolveSecret(*value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		*value = resolved
+		logging.RegisterSecret(resolved)
+		return nil
+	}
+
+	gs := &c.GlobalSettings
+	if err := resolve("globalSettings.setRootPassword", &gs.SetRootPassword); err != nil {
+		return err
+	}
+
+	// The overlay config is only secret for key-based providers; for WireGuard
+	// it is an interface name or config path
+	overlayConfig, err := ResolveSecret(gs.OverlayConfig)
+	if err != nil {
+		return fmt.Errorf("globalSettings.overlayConfig: %w", err)
+	}
+	gs.OverlayConfig = overlayConfig
+	switch strings.ToLower(gs.OverlayProvider) {
+	case "netbird", "tailscale":
+		logging.RegisterSecret(overlayConfig)
+	}
+	if !IsAutoValue(gs.Keepalived.AuthPass) {
+		if err := resolve("globalSettings.keepalived.authPass", &gs.Keepalived.AuthPass); err != nil {
+			return err
+		}
+	}
+
+	// Overlay keys are embedded in provider flags; redact the key itself too
+	for _, m := range overlayKeyPattern.FindAllStringSubmatch(gs.OverlayConfig, -1) {
+		logging.RegisterSecret(m[1])
+	}
+
+	backup := &gs.Backup
+	for field, value := range map[string]*string{
+		"globalSettings.backup.accessKey":            &backup.AccessKey,
+		"globalSettings.backup.secretKey":            &backup.SecretKey,
+		"globalSettings.backup.encryptionPassphrase": &backup.EncryptionPassphrase,
+		"globalSettings.edgeAuth.clientSecret":       &gs.EdgeAuth.ClientSecret,
+		"globalSettings.edgeAuth.cookieSecret":       &gs.EdgeAuth.CookieSecret,
+	} {
+		if err := resolve(field, value); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		if err := resolve(fmt.Sprintf("nodes[%d].password", i), &node.Password); err != nil {
+			return err
+		}
+		if err := resolve(fmt.Sprintf("nodes[%d].privateKeyPassword", i), &node.PrivateKeyPassword); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decryptConfig returns the plaintext of a configuration file. Age-encrypted
+// files (binary or armored) are decrypted with the identity file named by
+// DSCOTCTL_AGE_KEY_FILE or SOPS_AGE_KEY_FILE. SOPS-encrypted JSON is
+// decrypted with the sops binary. Other files are returned unchanged.
+func decryptConfig(configPath string, data []byte) ([]byte, error) {
+	for _, header := range ageHeaders {
+		if bytes.HasPrefix(bytes.TrimSpace(data), header) {
+			return decryptAge(data)
+		}
+	}
+
+	if isSopsFile(data) {
+		cmd := exec.Command("sops", "--decrypt", "--input-type", "json", "--output-type", "json", configPath)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt SOPS config with sops: %w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+
+	return data, nil
+}
+
+// decryptAge decrypts an age-encrypted configuration file.
+func decryptAge(data []byte) ([]byte, error) {
+	keyFile := os.Getenv(AgeKeyFileEnv)
+	if keyFile == "" {
+		keyFile = os.Getenv(SopsAgeKeyFileEnv)
+	}
+	if keyFile == "" {
+		return nil, fmt.Errorf("config file is age-encrypted; set %s to an age identity file", AgeKeyFileEnv)
+	}
+
+	keyData, err := os.ReadFile(filepath.Clean(keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity file: %w", err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity file %s: %w", keyFile, err)
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bufio.NewReader(bytes.NewReader(bytes.TrimSpace(data))))
+	}
+
+	plain, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age config: %w", err)
+	}
+	return io.ReadAll(plain)
+}
+
+// isSopsFile reports whether data is a SOPS-encrypted JSON document.
+func isSopsFile(data []byte) bool {
+	var doc struct {
+		Sops json.RawMessage `json:"sops"`
+	}
+	return json.Unmarshal(data, &doc) == nil && len(doc.Sops) > 0
+}
@@ -65,6 +65,16 @@ func AllNetworks() []NetworkConfig {
 	}
 }
 
+// =============================================================================
+// Docker Swarm Managers
+// =============================================================================
+
+const (
+	// SwarmMaxRecommendedManagers is the largest manager count Docker recommends;
+	// more managers add Raft overhead without useful extra fault tolerance.
+	SwarmMaxRecommendedManagers = 7
+)
+
 // =============================================================================
 // MicroCeph / CephFS Defaults
 // =============================================================================
@@ -90,6 +100,21 @@ const (
 
 	// CephPoolName is the default name for the Ceph storage pool.
 	CephPoolName = "docker-swarm"
+
+	// ErasureCodingDataChunks is the default number of data chunks (k) for erasure-coded pools.
+	ErasureCodingDataChunks = 2
+
+	// ErasureCodingCodingChunks is the default number of coding chunks (m) for erasure-coded pools.
+	ErasureCodingCodingChunks = 1
+
+	// CephRBDPoolName is the default name for the Ceph pool holding RBD volumes.
+	CephRBDPoolName = "docker-swarm-rbd"
+
+	// RBDVolumePlugin is the default Docker managed plugin for RBD volumes.
+	RBDVolumePlugin = "wetopi/rbd:latest"
+
+	// RBDVolumeDriver is the alias the RBD volume plugin is installed under.
+	RBDVolumeDriver = "rbd"
 )
 
 // =============================================================================
@@ -156,3 +181,59 @@ const (
 	KeepalivedHealthCheckScript = "HealthCheck.sh"
 )
 
+// =============================================================================
+// Backup Defaults
+// =============================================================================
+
+const (
+	// BackupRegion is the default S3 signing region for backup targets.
+	BackupRegion = "us-east-1"
+
+	// BackupRetention is the default number of backups kept in the bucket.
+	BackupRetention = 7
+)
+
+// =============================================================================
+// ACME Defaults
+// =============================================================================
+
+const (
+	// ACMEDirectoryURL is the default ACME directory (Let's Encrypt production).
+	ACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+	// ACMERenewBeforeDays is the default number of days before expiry a certificate is renewed.
+	ACMERenewBeforeDays = 30
+)
+
+// =============================================================================
+// EdgeAuth Defaults
+// =============================================================================
+
+const (
+	// EdgeAuthImage is the default oauth2-proxy image of the EdgeAuth companion
+	// (v7.8 or later, for --cookie-secret-file).
+	EdgeAuthImage = "quay.io/oauth2-proxy/oauth2-proxy:v7.8.1"
+
+	// EdgeAuthScope is the default OIDC scope requested by EdgeAuth.
+	EdgeAuthScope = "openid email profile"
+
+	// EdgeAuthReplicas is the number of EdgeAuth replicas. Sessions live in
+	// cookies, so any replica can serve any request.
+	EdgeAuthReplicas = 2
+)
+
+// =============================================================================
+// Status and Monitor Defaults
+// =============================================================================
+
+const (
+	// StatusConcurrency is the default number of nodes checked in parallel by
+	// status and monitor.
+	StatusConcurrency = 8
+
+	// MonitorListenAddr is the default listen address of the metrics endpoint.
+	MonitorListenAddr = ":9469"
+
+	// MonitorIntervalSeconds is the default time in seconds between two health collections.
+	MonitorIntervalSeconds = 60
+)
@@ -0,0 +1,703 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/s3"
+	"dscotctl/internal/ssh"
+	"dscotctl/internal/storage"
+)
+
+// backupIDFormat is the timestamp format of backup IDs. IDs sort chronologically.
+const backupIDFormat = "20060102T150405Z"
+
+// portainerStack is the data directory holding the Portainer database; it is
+// always included in backups.
+const portainerStack = "Portainer"
+
+// BackupManifest describes a backup. It is stored unencrypted next to the
+// archives as manifest.json.
+type BackupManifest struct {
+	ID        string    `json:"id"`
+	Cluster   string    `json:"cluster"`
+	Created   time.Time `json:"created"`
+	Encrypted bool      `json:"encrypted"`
+	Stacks    []string  `json:"stacks"`
+}
+
+// swarmState is the Swarm metadata stored in swarm.json. Each field holds the
+// raw `docker <object> inspect` output.
+type swarmState struct {
+	Configs  json.RawMessage `json:"configs"`
+	Secrets  json.RawMessage `json:"secrets"`
+	Services json.RawMessage `json:"services"`
+	Networks json.RawMessage `json:"networks"`
+	Nodes    json.RawMessage `json:"nodes"`
+}
+
+// swarmStateCommands lists the commands that collect each swarmState field.
+var swarmStateCommands = []struct {
+	Name    string
+	Command string
+}{
+	{"configs", "docker config ls -q | xargs -r docker config inspect"},
+	{"secrets", "docker secret ls -q | xargs -r docker secret inspect"},
+	{"services", "docker service ls -q | xargs -r docker service inspect"},
+	{"networks", "docker network ls -q --filter scope=swarm | xargs -r docker network inspect"},
+	{"nodes", "docker node ls -q | xargs -r docker node inspect"},
+}
+
+// Backup archives each stack's data directory from a storage snapshot plus the
+// Swarm metadata to the configured S3 target, then prunes backups beyond the
+// retention count. Archives are encrypted with age when a passphrase is set.
+func Backup(ctx context.Context, cfg *config.Config) (*BackupManifest, error) {
+	bc := cfg.GlobalSettings.Backup
+	if err := bc.ValidateBackup(); err != nil {
+		return nil, err
+	}
+
+	id := time.Now().UTC().Format(backupIDFormat)
+	log := logging.L().With("component", "backup", "backup", id)
+
+	client, err := s3.New(bc.Endpoint, bc.Region, bc.AccessKey, bc.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.EnsureBucket(ctx, bc.Bucket); err != nil {
+		return nil, err
+	}
+
+	provider, sshPool, clientNode, err := openSnapshotClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer sshPool.Close()
+
+	managers, _ := categorizeNodes(cfg)
+	manager, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archive from a snapshot so every stack is captured at the same point in time
+	dataPath := "/" + defaults.ServiceDataSubdir
+	snapshot := "backup-" + id
+	if err := provider.CreateSnapshot(ctx, sshPool, clientNode, dataPath, snapshot); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := provider.DeleteSnapshot(ctx, sshPool, clientNode, dataPath, snapshot); err != nil {
+			log.Warnw("⚠ failed to delete backup snapshot", "error", err)
+		}
+	}()
+	snapRoot := provider.GetMountPath() + dataPath + "/.snap/" + snapshot
+
+	stacks, err := backupStacks(ctx, sshPool, clientNode, snapRoot, bc.Stacks)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := backupPrefix(bc, id)
+	suffix := ""
+	if bc.EncryptionPassphrase != "" {
+		suffix = ".age"
+	}
+
+	for _, stack := range stacks {
+		key := prefix + "data/" + stack + ".tar.gz" + suffix
+		tarCmd := fmt.Sprintf("tar -C %s -czf - %s", ssh.ShellQuote(snapRoot), ssh.ShellQuote(stack))
+		log.Infow("→ archiving stack data", "stack", stack, "key", key)
+		if err := uploadCommandOutput(ctx, client, bc.Bucket, key, sshPool, clientNode, tarCmd, bc.EncryptionPassphrase); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", stack, err)
+		}
+		log.Infow("✓ stack data archived", "stack", stack)
+	}
+
+	state, err := collectSwarmState(ctx, sshPool, manager)
+	if err != nil {
+		return nil, err
+	}
+	if err := uploadBytes(ctx, client, bc.Bucket, prefix+"swarm.json"+suffix, state, bc.EncryptionPassphrase); err != nil {
+		return nil, err
+	}
+	log.Infow("✓ swarm metadata archived")
+
+	// The manifest is written last; backups without one are incomplete
+	manifest := &BackupManifest{
+		ID:        id,
+		Cluster:   cfg.GlobalSettings.ClusterName,
+		Created:   time.Now().UTC(),
+		Encrypted: bc.EncryptionPassphrase != "",
+		Stacks:    stacks,
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := uploadBytes(ctx, client, bc.Bucket, prefix+"manifest.json", manifestData, ""); err != nil {
+		return nil, err
+	}
+
+	if err := pruneBackups(ctx, client, bc); err != nil {
+		log.Warnw("⚠ failed to apply backup retention", "error", err)
+	}
+
+	log.Infow("✅ backup complete", "bucket", bc.Bucket, "prefix", prefix, "stacks", len(stacks))
+	return manifest, nil
+}
+
+// ListBackups returns the complete backups in the configured target, oldest first.
+func ListBackups(ctx context.Context, cfg *config.Config) ([]BackupManifest, error) {
+	bc := cfg.GlobalSettings.Backup
+	if err := bc.ValidateBackup(); err != nil {
+		return nil, err
+	}
+	client, err := s3.New(bc.Endpoint, bc.Region, bc.AccessKey, bc.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := listBackupIDs(ctx, client, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []BackupManifest
+	for _, id := range ids {
+		manifest, err := readManifest(ctx, client, bc, id)
+		if s3.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, *manifest)
+	}
+	return manifests, nil
+}
+
+// Restore restores stack data directories and missing Swarm configs from a
+// backup. An empty backupID selects the latest complete backup; empty stacks
+// restores every stack in the backup. Each stack is scaled to zero while its
+// data is replaced and a "pre-restore" snapshot of the current data is kept.
+func Restore(ctx context.Context, cfg *config.Config, backupID string, stacks []string) error {
+	bc := cfg.GlobalSettings.Backup
+	if err := bc.ValidateBackup(); err != nil {
+		return err
+	}
+
+	client, err := s3.New(bc.Endpoint, bc.Region, bc.AccessKey, bc.SecretKey)
+	if err != nil {
+		return err
+	}
+
+	if backupID == "" {
+		manifests, err := ListBackups(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if len(manifests) == 0 {
+			return fmt.Errorf("no backups found in s3://%s/%s", bc.Bucket, bc.Prefix)
+		}
+		backupID = manifests[len(manifests)-1].ID
+	}
+	log := logging.L().With("component", "backup", "backup", backupID)
+
+	manifest, err := readManifest(ctx, client, bc, backupID)
+	if err != nil {
+		if s3.IsNotFound(err) {
+			return fmt.Errorf("backup %s not found or incomplete (see 'backup list')", backupID)
+		}
+		return err
+	}
+	if manifest.Encrypted && bc.EncryptionPassphrase == "" {
+		return fmt.Errorf("backup %s is encrypted; globalSettings.backup.encryptionPassphrase is required", backupID)
+	}
+	passphrase := ""
+	suffix := ""
+	if manifest.Encrypted {
+		passphrase = bc.EncryptionPassphrase
+		suffix = ".age"
+	}
+
+	if len(stacks) == 0 {
+		stacks = manifest.Stacks
+	}
+	for _, stack := range stacks {
+		found := false
+		for _, s := range manifest.Stacks {
+			found = found || s == stack
+		}
+		if !found {
+			return fmt.Errorf("stack %q is not in backup %s (contains: %s)", stack, backupID, strings.Join(manifest.Stacks, ", "))
+		}
+	}
+
+	provider, sshPool, clientNode, err := openSnapshotClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer sshPool.Close()
+
+	managers, _ := categorizeNodes(cfg)
+	manager, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		return err
+	}
+
+	prefix := backupPrefix(bc, backupID)
+	if err := restoreSwarmConfigs(ctx, client, bc.Bucket, prefix+"swarm.json"+suffix, passphrase, sshPool, manager); err != nil {
+		return err
+	}
+
+	for _, stack := range stacks {
+		key := prefix + "data/" + stack + ".tar.gz" + suffix
+		if err := restoreStackData(ctx, client, bc.Bucket, key, passphrase, provider, sshPool, clientNode, manager, stack); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", stack, err)
+		}
+	}
+
+	log.Infow("✅ restore complete", "stacks", len(stacks))
+	return nil
+}
+
+// restoreStackData replaces a stack's data directory with its archive. The
+// archive is downloaded, decrypted and extracted into a temporary directory
+// next to the data directory while the stack keeps running; only then is the
+// stack scaled to zero and the extracted data swapped in. If the swap fails
+// the data directory is rolled back to the "pre-restore" snapshot and the
+// stack is left scaled to zero for inspection.
+func restoreStackData(ctx context.Context, client *s3.Client, bucket, key, passphrase string, provider storage.Provider, sshPool *ssh.Pool, clientNode, manager, stack string) (err error) {
+	log := logging.L().With("component", "backup", "stack", stack)
+
+	dataRoot := provider.GetMountPath() + "/" + defaults.ServiceDataSubdir
+	dir := dataRoot + "/" + stack
+	// Dot directories are skipped when backing up, so a leftover is never archived
+	tmpDir := dataRoot + "/.restore-" + stack
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stackScaleTimeout)
+		defer cancel()
+		sshPool.Run(cleanupCtx, clientNode, fmt.Sprintf("rm -rf %s", ssh.ShellQuote(tmpDir)))
+	}()
+
+	prepareCmd := fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s", ssh.ShellQuote(tmpDir))
+	if _, stderr, err := sshPool.Run(ctx, clientNode, prepareCmd); err != nil {
+		return fmt.Errorf("failed to create %s: %w (stderr: %s)", tmpDir, err, stderr)
+	}
+
+	body, err := client.GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	archive, err := decryptReader(body, passphrase)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("→ extracting archive", "key", key)
+	untarCmd := fmt.Sprintf("tar -xzf - -C %s", ssh.ShellQuote(tmpDir))
+	if stderr, err := sshPool.RunStream(ctx, clientNode, untarCmd, archive, io.Discard); err != nil {
+		return fmt.Errorf("failed to extract archive, stack data left unchanged: %w (stderr: %s)", err, stderr)
+	}
+	extracted := tmpDir + "/" + stack
+	if _, _, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("test -d %s", ssh.ShellQuote(extracted))); err != nil {
+		return fmt.Errorf("archive %s does not contain %s/, stack data left unchanged", key, stack)
+	}
+	log.Infow("✓ archive extracted")
+
+	states, err := stackServices(ctx, sshPool, manager, stack)
+	if err != nil {
+		return err
+	}
+	log.Infow("→ scaling stack to zero", "services", len(states))
+	rolledBack := false
+	defer func() {
+		if rolledBack {
+			log.Warnw("⚠ stack left scaled to zero after failed restore; scale it back up once the data is checked", "services", states)
+			return
+		}
+		scaleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stackScaleTimeout)
+		defer cancel()
+		if scaleErr := scaleStackUp(scaleCtx, sshPool, manager, states); scaleErr != nil {
+			log.Errorw("failed to restore stack scale", "error", scaleErr)
+			if err == nil {
+				err = scaleErr
+			}
+		}
+	}()
+	if err := scaleStackDown(ctx, sshPool, manager, states); err != nil {
+		return err
+	}
+	if len(states) > 0 {
+		if err := waitForStackStopped(ctx, sshPool, manager, stack); err != nil {
+			return err
+		}
+	}
+
+	if _, stderr, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("mkdir -p %s", ssh.ShellQuote(dir))); err != nil {
+		return fmt.Errorf("failed to create %s: %w (stderr: %s)", dir, err, stderr)
+	}
+	preRestore := "pre-restore-" + time.Now().UTC().Format("2006-01-02-15_04_05")
+	if err := provider.CreateSnapshot(ctx, sshPool, clientNode, serviceDataPath(stack), preRestore); err != nil {
+		return fmt.Errorf("failed to snapshot current contents: %w", err)
+	}
+	log.Infow("✓ current contents saved", "snapshot", preRestore)
+
+	// The directory itself is kept so its snapshots survive; .snap is not a
+	// real directory entry, so find leaves them alone. The temporary directory
+	// is on the same filesystem, so mv is a rename.
+	swapCmd := fmt.Sprintf("find %[1]s -mindepth 1 -delete && find %[2]s -mindepth 1 -maxdepth 1 -exec mv -t %[1]s {} +", ssh.ShellQuote(dir), ssh.ShellQuote(extracted))
+	if _, stderr, swapErr := sshPool.Run(ctx, clientNode, swapCmd); swapErr != nil {
+		rolledBack = true
+		log.Errorw("failed to swap in restored data, rolling back", "snapshot", preRestore, "error", swapErr)
+		// The swap may have failed because ctx was cancelled; the rollback must still run
+		rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stackScaleTimeout)
+		defer cancel()
+		if err := provider.RestoreSnapshot(rollbackCtx, sshPool, clientNode, serviceDataPath(stack), preRestore); err != nil {
+			return fmt.Errorf("failed to swap in restored data (%v, stderr: %s) and failed to roll back to snapshot %s: %w", swapErr, stderr, preRestore, err)
+		}
+		return fmt.Errorf("failed to swap in restored data, rolled back to snapshot %s: %w (stderr: %s)", preRestore, swapErr, stderr)
+	}
+
+	log.Infow("✓ stack data restored", "undo", preRestore)
+	return nil
+}
+
+// restoreSwarmConfigs recreates Swarm configs missing from the cluster and
+// reports missing secrets, whose values are not part of the backup.
+func restoreSwarmConfigs(ctx context.Context, client *s3.Client, bucket, key, passphrase string, sshPool *ssh.Pool, manager string) error {
+	log := logging.L().With("component", "backup", "node", manager)
+
+	body, err := client.GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	r, err := decryptReader(body, passphrase)
+	if err != nil {
+		return err
+	}
+
+	var state swarmState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("failed to parse swarm metadata: %w", err)
+	}
+
+	var configs []struct {
+		Spec struct {
+			Name   string            `json:"Name"`
+			Labels map[string]string `json:"Labels"`
+			Data   string            `json:"Data"`
+		} `json:"Spec"`
+	}
+	if err := json.Unmarshal(state.Configs, &configs); err != nil {
+		return fmt.Errorf("failed to parse swarm configs: %w", err)
+	}
+	var secrets []struct {
+		Spec struct {
+			Name string `json:"Name"`
+		} `json:"Spec"`
+	}
+	if err := json.Unmarshal(state.Secrets, &secrets); err != nil {
+		return fmt.Errorf("failed to parse swarm secrets: %w", err)
+	}
+
+	existingConfigs, err := swarmObjectNames(ctx, sshPool, manager, "config")
+	if err != nil {
+		return err
+	}
+	for _, c := range configs {
+		if existingConfigs[c.Spec.Name] {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(c.Spec.Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode config %s: %w", c.Spec.Name, err)
+		}
+
+		args := []string{"docker", "config", "create"}
+		labels := make([]string, 0, len(c.Spec.Labels))
+		for k, v := range c.Spec.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			args = append(args, "--label", ssh.ShellQuote(label))
+		}
+		args = append(args, ssh.ShellQuote(c.Spec.Name), "-")
+
+		if stderr, err := sshPool.RunStream(ctx, manager, strings.Join(args, " "), bytes.NewReader(data), io.Discard); err != nil {
+			return fmt.Errorf("failed to create config %s: %w (stderr: %s)", c.Spec.Name, err, stderr)
+		}
+		log.Infow("✓ swarm config recreated", "config", c.Spec.Name)
+	}
+
+	existingSecrets, err := swarmObjectNames(ctx, sshPool, manager, "secret")
+	if err != nil {
+		return err
+	}
+	for _, s := range secrets {
+		if !existingSecrets[s.Spec.Name] {
+			log.Warnw("⚠ swarm secret missing; recreate it before deploying the stack that uses it", "secret", s.Spec.Name)
+		}
+	}
+	return nil
+}
+
+// swarmObjectNames returns the names of the Swarm configs or secrets in the cluster.
+func swarmObjectNames(ctx context.Context, sshPool *ssh.Pool, manager, kind string) (map[string]bool, error) {
+	stdout, stderr, err := sshPool.Run(ctx, manager, fmt.Sprintf("docker %s ls --format '{{.Name}}'", kind))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list swarm %ss: %w (stderr: %s)", kind, err, stderr)
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(stdout) {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// backupStacks returns the data directories to back up. Without a configured
+// list every directory in the snapshot is included; Portainer always is.
+func backupStacks(ctx context.Context, sshPool *ssh.Pool, clientNode, snapRoot string, configured []string) ([]string, error) {
+	stdout, stderr, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type d -printf '%%f\\n'", ssh.ShellQuote(snapRoot)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service data directories: %w (stderr: %s)", err, stderr)
+	}
+	present := make(map[string]bool)
+	for _, name := range strings.Fields(stdout) {
+		if !strings.HasPrefix(name, ".") {
+			present[name] = true
+		}
+	}
+
+	wanted := configured
+	if len(wanted) == 0 {
+		for name := range present {
+			wanted = append(wanted, name)
+		}
+	} else {
+		wanted = append([]string{portainerStack}, wanted...)
+	}
+
+	seen := make(map[string]bool)
+	var stacks []string
+	for _, name := range wanted {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !present[name] {
+			if name != portainerStack {
+				logging.L().Warnw("⚠ configured backup stack has no data directory; skipping", "component", "backup", "stack", name)
+			}
+			continue
+		}
+		stacks = append(stacks, name)
+	}
+	sort.Strings(stacks)
+	return stacks, nil
+}
+
+// collectSwarmState returns the Swarm metadata as JSON.
+func collectSwarmState(ctx context.Context, sshPool *ssh.Pool, manager string) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	for _, c := range swarmStateCommands {
+		stdout, stderr, err := sshPool.Run(ctx, manager, c.Command)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect swarm %s: %w (stderr: %s)", c.Name, err, stderr)
+		}
+		raw := json.RawMessage(strings.TrimSpace(stdout))
+		if len(raw) == 0 {
+			raw = json.RawMessage("[]")
+		}
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("unexpected output collecting swarm %s", c.Name)
+		}
+		fields[c.Name] = raw
+	}
+
+	data, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode swarm metadata: %w", err)
+	}
+	return data, nil
+}
+
+// uploadCommandOutput streams the stdout of a remote command to bucket/key,
+// encrypting it when passphrase is set.
+func uploadCommandOutput(ctx context.Context, client *s3.Client, bucket, key string, sshPool *ssh.Pool, node, command, passphrase string) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := func() error {
+			w, err := encryptWriter(pw, passphrase)
+			if err != nil {
+				return err
+			}
+			if stderr, err := sshPool.RunStream(ctx, node, command, nil, w); err != nil {
+				return fmt.Errorf("failed to run %q: %w (stderr: %s)", command, err, stderr)
+			}
+			return w.Close()
+		}()
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	putErr := client.PutObject(ctx, bucket, key, pr)
+	// Unblock the command if the upload stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err := <-done; err != nil {
+		return err
+	}
+	return putErr
+}
+
+// uploadBytes uploads data to bucket/key, encrypting it when passphrase is set.
+func uploadBytes(ctx context.Context, client *s3.Client, bucket, key string, data []byte, passphrase string) error {
+	var buf bytes.Buffer
+	w, err := encryptWriter(&buf, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.PutObject(ctx, bucket, key, &buf)
+}
+
+// nopWriteCloser adds a no-op Close to a writer.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// encryptWriter wraps w with age passphrase encryption. With an empty
+// passphrase data is written unchanged. Close must be called to flush.
+func encryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nopWriteCloser{w}, nil
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption recipient: %w", err)
+	}
+	enc, err := age.Encrypt(w, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start encryption: %w", err)
+	}
+	return enc, nil
+}
+
+// decryptReader reverses encryptWriter.
+func decryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	if passphrase == "" {
+		return r, nil
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decryption identity: %w", err)
+	}
+	dec, err := age.Decrypt(r, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup (wrong passphrase?): %w", err)
+	}
+	return dec, nil
+}
+
+// backupPrefix returns the key prefix of a backup, ending in "/".
+func backupPrefix(bc config.BackupConfig, id string) string {
+	return strings.TrimPrefix(path.Join(bc.Prefix, id)+"/", "/")
+}
+
+// listBackupIDs returns the IDs of all backups under the prefix, oldest first.
+func listBackupIDs(ctx context.Context, client *s3.Client, bc config.BackupConfig) ([]string, error) {
+	root := backupPrefix(bc, "")
+	if root == "/" {
+		root = ""
+	}
+	_, prefixes, err := client.List(ctx, bc.Bucket, root, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range prefixes {
+		id := strings.TrimSuffix(strings.TrimPrefix(p, root), "/")
+		if _, err := time.Parse(backupIDFormat, id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// readManifest downloads the manifest of a backup.
+func readManifest(ctx context.Context, client *s3.Client, bc config.BackupConfig, id string) (*BackupManifest, error) {
+	body, err := client.GetObject(ctx, bc.Bucket, backupPrefix(bc, id)+"manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest BackupManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of backup %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// pruneBackups deletes all but the newest bc.Retention complete backups.
+// Incomplete backups older than the oldest kept one are deleted as well;
+// newer ones are left alone, since they may still be in progress.
+func pruneBackups(ctx context.Context, client *s3.Client, bc config.BackupConfig) error {
+	ids, err := listBackupIDs(ctx, client, bc)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for i := len(ids) - 1; i >= 0; i-- {
+		id := ids[i]
+		if kept < bc.Retention {
+			_, err := readManifest(ctx, client, bc, id)
+			if err != nil && !s3.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				kept++
+			}
+			continue
+		}
+
+		keys, _, err := client.List(ctx, bc.Bucket, backupPrefix(bc, id), "")
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := client.DeleteObject(ctx, bc.Bucket, key); err != nil {
+				return err
+			}
+		}
+		logging.L().Infow("✓ expired backup deleted", "component", "backup", "backup", id)
+	}
+	return nil
+}
@@ -0,0 +1,527 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/nodeconfig"
+	"dscotctl/internal/orchestrator"
+	"dscotctl/internal/services"
+	"dscotctl/internal/ssh"
+	"dscotctl/internal/sshkeys"
+	"dscotctl/internal/storage"
+)
+
+// nodeDrainTimeout is how long RemoveNode waits for tasks to leave a drained node.
+const nodeDrainTimeout = 5 * time.Minute
+
+// openSSHPool creates an SSH connection pool for commands that operate on an
+// existing cluster. Unlike Deploy, it does not install public keys on nodes.
+func openSSHPool(cfg *config.Config) (*ssh.Pool, error) {
+	keyType := cfg.GlobalSettings.SSHKeyType
+	if keyType == "" {
+		keyType = sshkeys.DefaultKeyType
+	}
+
+	keyPair, err := sshkeys.EnsureKeyPair("", keyType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure SSH key pair: %w", err)
+	}
+
+	return createSSHPool(cfg, keyPair)
+}
+
+// findNode returns the configured node matching name by SSH address or new hostname.
+func findNode(cfg *config.Config, name string) (*config.NodeConfig, error) {
+	for i := range cfg.Nodes {
+		node := &cfg.Nodes[i]
+		if strings.EqualFold(node.SSHFQDNorIP, name) || (node.NewHostname != "" && strings.EqualFold(node.NewHostname, name)) {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("node %q not found in configuration", name)
+}
+
+// findReachableManager returns the first manager that is reachable and has
+// Swarm control available. Falls back through the list so commands keep
+// working while the primary manager is down.
+func findReachableManager(ctx context.Context, sshPool *ssh.Pool, managers []string) (string, error) {
+	log := logging.L().With("component", "deployer")
+
+	for _, manager := range managers {
+		stdout, stderr, err := sshPool.Run(ctx, manager, "docker info --format '{{.Swarm.ControlAvailable}}'")
+		if err != nil {
+			log.Warnw("manager not reachable", "host", manager, "error", err, "stderr", strings.TrimSpace(stderr))
+			continue
+		}
+		if strings.TrimSpace(stdout) == "true" {
+			return manager, nil
+		}
+		log.Warnw("node is not an active Swarm manager", "host", manager)
+	}
+
+	return "", fmt.Errorf("no reachable Swarm manager among %d configured managers", len(managers))
+}
+
+// getDockerManagerHost returns the Docker Swarm hostname of a manager node,
+// falling back to the SSH address when it cannot be determined.
+func getDockerManagerHost(ctx context.Context, sshPool *ssh.Pool, primaryMaster string) string {
+	log := logging.L().With("component", "deployer")
+
+	stdout, _, err := sshPool.Run(ctx, primaryMaster, "docker node ls --filter role=manager --format '{{.Hostname}}' | head -1")
+	if err == nil && strings.TrimSpace(stdout) != "" {
+		dockerManagerHost := strings.TrimSpace(stdout)
+		log.Infow("primary manager Docker hostname", "hostname", dockerManagerHost)
+		return dockerManagerHost
+	}
+
+	log.Warnw("could not determine Docker manager hostname, using SSH address", "fallback", primaryMaster)
+	return primaryMaster
+}
+
+// getKeepalivedVIP returns the VIP of an existing Keepalived deployment.
+// A fixed VIP is taken from the configuration; an auto-detected VIP is read
+// back from keepalived.conf on the first node that has one.
+func getKeepalivedVIP(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) string {
+	if !cfg.IsKeepalivedEnabled() {
+		return ""
+	}
+
+	kc := cfg.GetKeepalived()
+	if kc.VIP != "" && !config.IsAutoValue(kc.VIP) {
+		return strings.Split(kc.VIP, "/")[0]
+	}
+
+	readCmd := "awk '/virtual_ipaddress/ {getline; print $1; exit}' /etc/keepalived/keepalived.conf 2>/dev/null"
+	for _, node := range cfg.GetKeepalivedNodes() {
+		stdout, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, readCmd)
+		if err != nil {
+			continue
+		}
+		if vip := strings.TrimSpace(stdout); vip != "" {
+			return strings.Split(vip, "/")[0]
+		}
+	}
+
+	return ""
+}
+
+// StorageStatus returns the status of the distributed storage cluster.
+func StorageStatus(ctx context.Context, cfg *config.Config) (*storage.ClusterStatus, error) {
+	if !cfg.IsStorageEnabled() {
+		return nil, fmt.Errorf("distributed storage is not enabled in configuration")
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	return storageStatus(ctx, cfg, sshPool)
+}
+
+// storageStatus queries storage status from the first MON node that answers.
+func storageStatus(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) (*storage.ClusterStatus, error) {
+	log := logging.L().With("component", "storage-status")
+
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage provider: %w", err)
+	}
+
+	monNodes, _, _ := getStorageNodesByRole(cfg)
+	if len(monNodes) == 0 {
+		return nil, fmt.Errorf("no storage-enabled manager nodes found")
+	}
+
+	var lastErr error
+	for _, node := range monNodes {
+		status, err := provider.Status(ctx, sshPool, node)
+		if err != nil {
+			log.Warnw("failed to query storage status", "node", node, "error", err)
+			lastErr = err
+			continue
+		}
+		log.Infow("storage cluster status",
+			"provider", provider.Name(),
+			"node", node,
+			"healthy", status.Healthy,
+			"osdCount", status.NodeCount,
+		)
+		return status, nil
+	}
+
+	return nil, fmt.Errorf("failed to query storage status from any MON node: %w", lastErr)
+}
+
+// UpgradeStorage moves the storage cluster to a new release channel. An empty
+// channel uses the snapChannel from the configuration.
+func UpgradeStorage(ctx context.Context, cfg *config.Config, channel string) error {
+	if !cfg.IsStorageEnabled() {
+		return fmt.Errorf("distributed storage is not enabled in configuration")
+	}
+	if channel == "" {
+		channel = cfg.GetDistributedStorage().Providers.MicroCeph.SnapChannel
+	}
+
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	managers, workers, _ := getStorageNodesByRole(cfg)
+	return storage.UpgradeCluster(ctx, sshPool, provider, managers, workers, channel, buildStorageNodeInfoMap(cfg))
+}
+
+// DeployServices redeploys service definitions on an existing cluster without
+// running the other deployment phases.
+func DeployServices(ctx context.Context, cfg *config.Config) error {
+	log := logging.L().With("component", "deployer")
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	managers, workers := categorizeNodes(cfg)
+	primaryMaster, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		return err
+	}
+
+	dockerManagerHost := getDockerManagerHost(ctx, sshPool, primaryMaster)
+	keepalivedVIP := getKeepalivedVIP(ctx, cfg, sshPool)
+	allSSHNodes := append(managers, workers...)
+
+	log.Infow("deploying services", "primaryMaster", primaryMaster, "nodes", len(allSSHNodes), "keepalivedVIP", keepalivedVIP)
+	return deployServicesPhase(ctx, cfg, sshPool, primaryMaster, allSSHNodes, len(workers) > 0, dockerManagerHost, keepalivedVIP)
+}
+
+// RenewCertificates obtains or renews the ACME certificates of all NGINX_HOST
+// virtual hosts without redeploying services. Suitable for a daily cron job.
+func RenewCertificates(ctx context.Context, cfg *config.Config) error {
+	opts := acmeOptions(cfg)
+	if opts == nil {
+		return fmt.Errorf("ACME is not enabled in configuration (globalSettings.acme.enabled)")
+	}
+
+	svcList, err := services.DiscoverServices(serviceDefinitionDir(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to discover services: %w", err)
+	}
+	edge := services.GetEdgeLoadBalancerService(svcList)
+	if edge == nil {
+		return fmt.Errorf("EdgeLoadBalancer service is not enabled")
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	managers, _ := categorizeNodes(cfg)
+	primaryMaster, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		return err
+	}
+
+	storagePath := ""
+	if ds := cfg.GetDistributedStorage(); ds.Enabled {
+		storagePath = ds.Providers.MicroCeph.MountPath
+	}
+	nginxServiceName := fmt.Sprintf("%s_%s", edge.Name, edge.Name)
+	nodeHostnameToSSH := mapNodeHostnames(ctx, sshPool, getEnabledNodes(cfg))
+	if err := services.RenewCertificates(ctx, sshPool, primaryMaster, storagePath, svcList, nginxServiceName, nodeHostnameToSSH, *opts); err != nil {
+		return err
+	}
+	logging.L().Infow("✅ certificates are current")
+	return nil
+}
+
+// AddNode brings a node that has been added to the configuration into the
+// cluster without touching the other nodes: it installs dependencies, joins
+// the overlay network, joins the storage cluster (adding OSDs on worker/both
+// nodes), joins the Swarm and applies labels on that node only.
+func AddNode(ctx context.Context, cfg *config.Config, name string) error {
+	log := logging.L().With("component", "node-add")
+
+	node, err := findNode(cfg, name)
+	if err != nil {
+		return err
+	}
+	if !node.IsEnabled() {
+		return fmt.Errorf("node %s is disabled in configuration; enable it before adding", node.SSHFQDNorIP)
+	}
+	target := node.SSHFQDNorIP
+	nodeCfg := scopeToNode(cfg, *node)
+
+	managers, _ := categorizeNodes(cfg)
+	var otherManagers []string
+	for _, m := range managers {
+		if m != target {
+			otherManagers = append(otherManagers, m)
+		}
+	}
+	if len(otherManagers) == 0 {
+		return fmt.Errorf("cannot add %s: no existing manager to join; run deploy instead", target)
+	}
+
+	log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "adding node to cluster"))
+
+	// Only the new node needs the public key; existing nodes already have it
+	keyPair, err := prepareSSHKeys(nodeCfg)
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSH keys: %w", err)
+	}
+	sshPool, err := createSSHPool(cfg, keyPair)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	manager, err := findReachableManager(ctx, sshPool, otherManagers)
+	if err != nil {
+		return err
+	}
+
+	if err := setHostnames(ctx, nodeCfg, sshPool); err != nil {
+		return fmt.Errorf("failed to set hostname: %w", err)
+	}
+	if cfg.GlobalSettings.SetRootPassword != "" {
+		if err := setRootPassword(ctx, nodeCfg, sshPool); err != nil {
+			return fmt.Errorf("failed to set root password: %w", err)
+		}
+	}
+	if err := installDependencies(ctx, nodeCfg, sshPool); err != nil {
+		return fmt.Errorf("failed to install dependencies: %w", err)
+	}
+	if err := configureOverlay(ctx, nodeCfg, sshPool); err != nil {
+		return fmt.Errorf("failed to configure overlay network: %w", err)
+	}
+
+	ds := cfg.GetDistributedStorage()
+	if ds.Enabled && node.StorageEnabled {
+		if err := addStorageNode(ctx, cfg, sshPool, *node); err != nil {
+			return err
+		}
+	}
+
+	if ds.Enabled && ds.Providers.MicroCeph.EnableRBD {
+		storageManagers, _, _ := getStorageNodesByRole(cfg)
+		for _, mon := range storageManagers {
+			if mon == target {
+				continue
+			}
+			if err := setupRBDVolumePlugin(ctx, cfg, sshPool, mon, []string{target}); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	joinAddr := swarmJoinAddress(manager, nodeOverlayInfo(ctx, cfg, sshPool, manager))
+	asManager := node.Role == "manager" || node.Role == "both"
+
+	log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "joining Docker Swarm"), "manager", manager, "joinAddr", joinAddr)
+	if err := orchestrator.JoinNode(ctx, sshPool, manager, target, joinAddr, asManager); err != nil {
+		return fmt.Errorf("failed to join swarm: %w", err)
+	}
+	log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "joined Docker Swarm"))
+
+	if err := applyNodeLabels(ctx, nodeCfg, sshPool, manager); err != nil {
+		return fmt.Errorf("failed to apply node labels: %w", err)
+	}
+
+	nodeConfigurator := nodeconfig.NewNodeConfigurator(sshPool)
+	if err := nodeConfigurator.ConfigureAllNodes(ctx, getEnabledNodes(nodeCfg)); err != nil {
+		log.Warnw("failed to configure some node settings", "error", err)
+	}
+
+	log.Infow(formatNodeMessage("✅", target, node.NewHostname, node.Role, "node added to cluster"))
+	return nil
+}
+
+// addStorageNode joins a node to the running storage cluster via the first
+// other MON node that answers.
+func addStorageNode(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, node config.NodeConfig) error {
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+
+	monNode, err := findStorageMON(ctx, cfg, sshPool, provider, node.SSHFQDNorIP)
+	if err != nil {
+		return err
+	}
+	monNodes, _, _ := getStorageNodesByRole(cfg)
+
+	info := storage.NodeInfo{SSHFQDNorIP: node.SSHFQDNorIP, NewHostname: node.NewHostname, Role: node.Role}
+	if err := storage.JoinNode(ctx, sshPool, provider, cfg, monNode, monNodes, info); err != nil {
+		return fmt.Errorf("failed to join storage cluster: %w", err)
+	}
+	return nil
+}
+
+// findStorageMON returns the first storage MON node other than exclude whose
+// cluster status can be queried.
+func findStorageMON(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, provider storage.Provider, exclude string) (string, error) {
+	log := logging.L().With("component", "deployer")
+
+	monNodes, _, _ := getStorageNodesByRole(cfg)
+	for _, mon := range monNodes {
+		if mon == exclude {
+			continue
+		}
+		if _, err := provider.Status(ctx, sshPool, mon); err != nil {
+			log.Warnw("storage MON not reachable", "host", mon, "error", err)
+			continue
+		}
+		return mon, nil
+	}
+	return "", fmt.Errorf("no reachable storage MON node other than %s", exclude)
+}
+
+// scopeToNode returns a copy of cfg whose node list holds only node, so the
+// per-phase helpers can be reused for a single node.
+func scopeToNode(cfg *config.Config, node config.NodeConfig) *config.Config {
+	scoped := *cfg
+	scoped.Nodes = []config.NodeConfig{node}
+	return &scoped
+}
+
+// RemoveNode drains a node's Swarm tasks, drains and removes its OSDs so the
+// storage cluster rebalances first, then removes it from the storage cluster
+// and the Swarm. The node must still be enabled in the configuration so it can be reached over SSH.
+func RemoveNode(ctx context.Context, cfg *config.Config, name string) error {
+	log := logging.L().With("component", "node-remove")
+
+	node, err := findNode(cfg, name)
+	if err != nil {
+		return err
+	}
+	if !node.IsEnabled() {
+		return fmt.Errorf("node %s is disabled in configuration; it must be enabled to be removed", node.SSHFQDNorIP)
+	}
+	target := node.SSHFQDNorIP
+
+	managers, _ := categorizeNodes(cfg)
+	var otherManagers []string
+	for _, m := range managers {
+		if m != target {
+			otherManagers = append(otherManagers, m)
+		}
+	}
+	if len(otherManagers) == 0 {
+		return fmt.Errorf("cannot remove %s: it is the only manager", target)
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	manager, err := findReachableManager(ctx, sshPool, otherManagers)
+	if err != nil {
+		return err
+	}
+
+	if node.Role == "manager" || node.Role == "both" {
+		if err := orchestrator.CheckQuorum(ctx, sshPool, manager, 1); err != nil {
+			return fmt.Errorf("refusing to remove %s: %w", target, err)
+		}
+	}
+
+	stdout, stderr, err := sshPool.Run(ctx, target, "docker info --format '{{.Name}}'")
+	if err != nil {
+		return fmt.Errorf("failed to get Docker node name: %w (stderr: %s)", err, stderr)
+	}
+	swarmName := strings.TrimSpace(stdout)
+
+	log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "draining node"))
+	drainCmd := fmt.Sprintf("docker node update --availability drain %s", swarmName)
+	if _, stderr, err := sshPool.Run(ctx, manager, drainCmd); err != nil {
+		return fmt.Errorf("failed to drain node: %w (stderr: %s)", err, stderr)
+	}
+	if err := waitForNodeDrained(ctx, sshPool, manager, swarmName); err != nil {
+		log.Warnw(formatNodeMessage("⚠", target, node.NewHostname, node.Role, "tasks still running after drain timeout (continuing)"), "error", err)
+	} else {
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node drained"))
+	}
+
+	if cfg.IsStorageEnabled() && node.StorageEnabled {
+		if err := unmountDistributedStorage(ctx, sshPool, []string{target}, cfg); err != nil {
+			log.Warnw(formatNodeMessage("⚠", target, node.NewHostname, node.Role, "failed to unmount storage"), "error", err)
+		}
+
+		provider, err := storage.NewProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create storage provider: %w", err)
+		}
+		monNode, err := findStorageMON(ctx, cfg, sshPool, provider, target)
+		if err != nil {
+			return err
+		}
+		log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "draining OSDs and leaving storage cluster"), "monNode", monNode)
+		if err := provider.DecommissionNode(ctx, sshPool, monNode, target); err != nil {
+			return fmt.Errorf("failed to remove node from storage cluster: %w", err)
+		}
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node removed from storage cluster"))
+	}
+
+	if node.Role == "manager" || node.Role == "both" {
+		demoteCmd := fmt.Sprintf("docker node demote %s", swarmName)
+		if _, stderr, err := sshPool.Run(ctx, manager, demoteCmd); err != nil {
+			return fmt.Errorf("failed to demote node: %w (stderr: %s)", err, stderr)
+		}
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node demoted"))
+	}
+
+	if err := leaveSwarm(ctx, sshPool, []string{target}); err != nil {
+		return fmt.Errorf("failed to leave swarm: %w", err)
+	}
+
+	rmCmd := fmt.Sprintf("docker node rm --force %s", swarmName)
+	if _, stderr, err := sshPool.Run(ctx, manager, rmCmd); err != nil {
+		return fmt.Errorf("failed to remove node from swarm: %w (stderr: %s)", err, stderr)
+	}
+	log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node removed from swarm"))
+
+	log.Infow(formatNodeMessage("✅", target, node.NewHostname, node.Role, "node removed; disable or delete it in the configuration"))
+	return nil
+}
+
+// waitForNodeDrained waits until no running tasks remain on a drained node.
+func waitForNodeDrained(ctx context.Context, sshPool *ssh.Pool, manager, swarmName string) error {
+	checkCmd := fmt.Sprintf("docker node ps %s --filter desired-state=running -q", swarmName)
+	deadline := time.Now().Add(nodeDrainTimeout)
+
+	for {
+		stdout, _, err := sshPool.Run(ctx, manager, checkCmd)
+		if err == nil && strings.TrimSpace(stdout) == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for tasks to leave %s", nodeDrainTimeout, swarmName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -1,12 +1,13 @@
 package deployer
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,11 +32,21 @@ func formatNodeMessage(prefix, hostname, newHostname, role, message string) stri
 
 // Deploy orchestrates the complete cluster deployment from the configuration.
 func Deploy(ctx context.Context, cfg *config.Config) error {
+	return DeployWithOptions(ctx, cfg, DeployOptions{})
+}
+
+// DeployWithOptions runs the deployment phases selected by opts. Progress is
+// recorded per phase and per node in a run journal next to the configuration
+// file (see JournalPath), which -resume uses to skip completed phases.
+func DeployWithOptions(ctx context.Context, cfg *config.Config, opts DeployOptions) error {
 	log := logging.L().With("component", "deployer")
 
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	// Track overall deployment metrics
 	startTime := time.Now()
-	var phasesCompleted int
 
 	// Count enabled/disabled nodes
 	enabledNodes := getEnabledNodes(cfg)
@@ -46,6 +57,9 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 		"totalNodes", len(cfg.Nodes),
 		"enabledNodes", len(enabledNodes),
 		"disabledNodes", disabledCount,
+		"resume", opts.Resume,
+		"fromPhase", opts.FromPhase,
+		"onlyPhase", opts.OnlyPhase,
 		"startTime", startTime.Format(time.RFC3339),
 	)
 
@@ -53,66 +67,253 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 		return fmt.Errorf("no enabled nodes found in configuration")
 	}
 
-	// Phase 1: Prepare SSH keys and connection pool
-	log.Infow("Phase 1: Preparing SSH keys and connections")
-	keyPair, err := prepareSSHKeys(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to prepare SSH keys: %w", err)
+	sshManagers, sshWorkers := categorizeNodes(cfg)
+	if len(sshManagers) == 0 {
+		return fmt.Errorf("no manager nodes found")
 	}
+	allSSHNodes := append(append([]string{}, sshManagers...), sshWorkers...)
+	primaryMaster := sshManagers[0] // SSH hostname for SSH operations
 
-	sshPool, err := createSSHPool(cfg, keyPair)
+	journal, err := openJournal(cfg.ConfigPath, opts.Resume)
 	if err != nil {
-		return fmt.Errorf("failed to create SSH pool: %w", err)
+		return fmt.Errorf("failed to open run journal: %w", err)
+	}
+	runner := &phaseRunner{journal: journal, opts: opts}
+	scriptReport := newScriptReport(cfg.ConfigPath)
+
+	// Phase 1: Prepare SSH keys and connection pool
+	var keyPair *sshkeys.KeyPair
+	var sshPool *ssh.Pool
+	if err := runner.run(ctx, "1", func(ctx context.Context) error {
+		log.Infow("Phase 1: Preparing SSH keys and connections")
+		var err error
+		keyPair, err = prepareSSHKeys(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to prepare SSH keys: %w", err)
+		}
+
+		sshPool, err = createSSHPool(cfg, keyPair)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH pool: %w", err)
+		}
+		log.Infow("✅ SSH keys and connection pool ready")
+		return nil
+	}); err != nil {
+		return err
 	}
-	log.Infow("✅ SSH keys and connection pool ready")
-	phasesCompleted++
 
 	// Phase 2: Set hostnames if configured
-	log.Infow("Phase 2: Setting hostnames")
-	if err := setHostnames(ctx, cfg, sshPool); err != nil {
-		return fmt.Errorf("failed to set hostnames: %w", err)
+	if err := runner.run(ctx, "2", func(ctx context.Context) error {
+		log.Infow("Phase 2: Setting hostnames")
+		if err := setHostnames(ctx, cfg, sshPool); err != nil {
+			return fmt.Errorf("failed to set hostnames: %w", err)
+		}
+		log.Infow("✅ Hostnames configured")
+		return nil
+	}); err != nil {
+		return err
 	}
-	log.Infow("✅ Hostnames configured")
-	phasesCompleted++
 
 	// Phase 2.5: Set root password if configured
-	if cfg.GlobalSettings.SetRootPassword != "" {
+	if err := runner.run(ctx, "2.5", func(ctx context.Context) error {
+		if cfg.GlobalSettings.SetRootPassword == "" {
+			log.Infow("Phase 2.5: Skipping root password (not configured)")
+			return nil
+		}
 		log.Infow("Phase 2.5: Setting root password on all nodes")
 		if err := setRootPassword(ctx, cfg, sshPool); err != nil {
 			return fmt.Errorf("failed to set root password: %w", err)
 		}
 		log.Infow("✅ Root password set on all nodes")
-	} else {
-		log.Infow("Phase 2.5: Skipping root password (not configured)")
+		return nil
+	}); err != nil {
+		return err
 	}
-	phasesCompleted++
 
 	// Phase 3: Execute pre-deployment scripts
-	log.Infow("Phase 3: Executing pre-deployment scripts")
-	if err := executeScripts(ctx, cfg, sshPool, cfg.GlobalSettings.PreScripts, "pre"); err != nil {
-		return fmt.Errorf("failed to execute pre-deployment scripts: %w", err)
+	if err := runner.run(ctx, "3", func(ctx context.Context) error {
+		log.Infow("Phase 3: Executing pre-deployment scripts")
+		if err := executeScripts(ctx, cfg, sshPool, cfg.GlobalSettings.PreScripts, "pre", scriptReport); err != nil {
+			return fmt.Errorf("failed to execute pre-deployment scripts: %w", err)
+		}
+		log.Infow("✅ Pre-deployment scripts complete")
+		return nil
+	}); err != nil {
+		return err
 	}
-	log.Infow("✅ Pre-deployment scripts complete")
-	phasesCompleted++
 
 	// Phase 4: Install dependencies on all nodes
-	log.Infow("Phase 4: Installing dependencies on all nodes")
-	if err := installDependencies(ctx, cfg, sshPool); err != nil {
-		return fmt.Errorf("failed to install dependencies: %w", err)
+	if err := runner.run(ctx, "4", func(ctx context.Context) error {
+		log.Infow("Phase 4: Installing dependencies on all nodes")
+		if err := installDependencies(ctx, cfg, sshPool); err != nil {
+			return fmt.Errorf("failed to install dependencies: %w", err)
+		}
+		log.Infow("✅ Dependencies installed")
+		return nil
+	}); err != nil {
+		return err
 	}
-	log.Infow("✅ Dependencies installed")
-	phasesCompleted++
 
 	// Phase 5: Configure overlay network on all nodes
-	log.Infow("Phase 5: Configuring overlay network")
-	if err := configureOverlay(ctx, cfg, sshPool); err != nil {
-		return fmt.Errorf("failed to configure overlay network: %w", err)
+	if err := runner.run(ctx, "5", func(ctx context.Context) error {
+		log.Infow("Phase 5: Configuring overlay network")
+		if err := configureOverlay(ctx, cfg, sshPool); err != nil {
+			return fmt.Errorf("failed to configure overlay network: %w", err)
+		}
+		log.Infow("✅ Overlay network configured")
+		return nil
+	}); err != nil {
+		return err
 	}
-	log.Infow("✅ Overlay network configured")
-	phasesCompleted++
 
 	// Phase 6: Setup distributed storage if enabled
-	// Storage uses Swarm roles: managers become MON nodes, workers become OSD nodes
+	if err := runner.run(ctx, "6", func(ctx context.Context) error {
+		return setupStoragePhase(ctx, cfg, sshPool)
+	}); err != nil {
+		return err
+	}
+
+	// Phase 7: Setup Docker Swarm
+	if err := runner.run(ctx, "7", func(ctx context.Context) error {
+		log.Infow("Phase 7: Setting up Docker Swarm")
+		return setupSwarmPhase(ctx, cfg, sshPool, sshManagers, sshWorkers)
+	}); err != nil {
+		return err
+	}
+
+	// Phase 8: Detect geolocation and apply node labels
+	if err := runner.run(ctx, "8", func(ctx context.Context) error {
+		log.Infow("Phase 8: Detecting geolocation and applying node labels")
+		if err := applyNodeLabels(ctx, cfg, sshPool, primaryMaster); err != nil {
+			return fmt.Errorf("failed to apply node labels: %w", err)
+		}
+		log.Infow("✅ Node labels applied")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 8b: Configure Keepalived for high availability (if enabled)
+	var keepalivedVIP string // Used later for credentials file
+	if err := runner.run(ctx, "8b", func(ctx context.Context) error {
+		if !cfg.IsKeepalivedEnabled() {
+			return nil
+		}
+		log.Infow("Phase 8b: Configuring Keepalived for high availability")
+		keepalivedDeployment, err := services.PrepareKeepalivedDeployment(ctx, sshPool, cfg)
+		if err != nil {
+			return phasePartial(fmt.Errorf("failed to prepare Keepalived deployment: %w", err))
+		}
+		if !keepalivedDeployment.Enabled {
+			return nil
+		}
+		if err := services.InstallAndConfigureKeepalived(ctx, sshPool, keepalivedDeployment); err != nil {
+			return phasePartial(fmt.Errorf("failed to configure Keepalived: %w", err))
+		}
+		log.Infow("✅ Keepalived configured",
+			"vip", keepalivedDeployment.VIPCIDR,
+			"interface", keepalivedDeployment.Interface,
+			"nodeCount", len(keepalivedDeployment.Nodes),
+		)
+		keepalivedVIP = keepalivedDeployment.VIP // Store VIP for later use
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 8c: Configure per-node settings (ManagementPanel, Firewall)
+	if err := runner.run(ctx, "8c", func(ctx context.Context) error {
+		log.Infow("Phase 8c: Configuring per-node settings (ManagementPanel, Firewall)")
+		nodeConfigurator := nodeconfig.NewNodeConfigurator(sshPool)
+		if err := nodeConfigurator.ConfigureAllNodes(ctx, enabledNodes); err != nil {
+			return phasePartial(fmt.Errorf("failed to configure some node settings: %w", err))
+		}
+		log.Infow("✅ Per-node configuration complete")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 9: Deploy services from YAML files
+	if err := runner.run(ctx, "9", func(ctx context.Context) error {
+		log.Infow("Phase 9: Deploying services")
+		// Get primary manager's Docker Swarm hostname for services that need to connect to Docker API
+		dockerManagerHost := getDockerManagerHost(ctx, sshPool, primaryMaster)
+		if keepalivedVIP == "" {
+			// Phase 8b was skipped or did not configure a VIP in this run
+			keepalivedVIP = getKeepalivedVIP(ctx, cfg, sshPool)
+		}
+		if err := deployServicesPhase(ctx, cfg, sshPool, primaryMaster, allSSHNodes, len(sshWorkers) > 0, dockerManagerHost, keepalivedVIP); err != nil {
+			return phasePartial(fmt.Errorf("service deployment encountered errors: %w", err))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 10: Execute post-deployment scripts
+	if err := runner.run(ctx, "10", func(ctx context.Context) error {
+		log.Infow("Phase 10: Executing post-deployment scripts")
+		if err := executeScripts(ctx, cfg, sshPool, cfg.GlobalSettings.PostScripts, "post", scriptReport); err != nil {
+			return fmt.Errorf("failed to execute post-deployment scripts: %w", err)
+		}
+		log.Infow("✅ Post-deployment scripts complete")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 11: Reboot nodes if configured
+	if err := runner.run(ctx, "11", func(ctx context.Context) error {
+		log.Infow("Phase 11: Rebooting nodes if configured")
+		if err := rebootNodes(ctx, cfg, sshPool); err != nil {
+			return fmt.Errorf("failed to reboot nodes: %w", err)
+		}
+		log.Infow("✅ Reboot initiated for configured nodes")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Phase 12: Remove SSH public key from nodes if configured
+	if err := runner.run(ctx, "12", func(ctx context.Context) error {
+		if !cfg.GlobalSettings.RemoveSSHPublicKeyOnCompletion {
+			log.Infow("Phase 12: Skipping SSH public key removal (removeSSHPublicKeyOnCompletion=false)")
+			return nil
+		}
+		log.Infow("Phase 12: Removing SSH public key from nodes on completion")
+		if err := removeSSHPublicKeyFromNodes(ctx, cfg, sshPool, keyPair); err != nil {
+			log.Warnw("failed to remove SSH public key from nodes", "error", err)
+		} else {
+			log.Infow("✅ SSH public key removed from nodes")
+		}
+		log.Infow("ℹ️  Local SSH key pair kept in sshkeys/ directory for future use")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Calculate final metrics
+	endTime := time.Now()
+	duration := endTime.Sub(startTime)
+
+	log.Infow("🎉 Cluster deployment complete!",
+		"totalDuration", duration.String(),
+		"phasesCompleted", runner.completed,
+		"phasesPartial", runner.partial,
+		"phasesSkipped", runner.skipped,
+		"journal", journal.path,
+		"startTime", startTime.Format(time.RFC3339),
+		"endTime", endTime.Format(time.RFC3339),
+	)
+	return nil
+}
+
+// setupStoragePhase sets up distributed storage if enabled (Phase 6).
+// Storage uses Swarm roles: managers become MON nodes, workers become OSD nodes.
+func setupStoragePhase(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) error {
+	log := logging.L().With("component", "deployer")
+
 	storageManagers, storageWorkers, storageNodes := getStorageNodesByRole(cfg)
 	ds := cfg.GetDistributedStorage()
 	if ds.Enabled && len(storageNodes) > 0 {
@@ -152,6 +353,17 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 			return fmt.Errorf("failed to setup distributed storage: %w", err)
 		}
 
+		// Move MON/OSD services of existing members whose role changed
+		if err := reconcileStorageRoles(ctx, cfg, sshPool); err != nil {
+			return fmt.Errorf("failed to reconcile storage roles: %w", err)
+		}
+
+		if ds.Providers.MicroCeph.EnableRBD {
+			if err := setupRBDVolumePlugin(ctx, cfg, sshPool, storageManagers[0], getNodeAddresses(getEnabledNodes(cfg))); err != nil {
+				return err
+			}
+		}
+
 		log.Infow("✅ Distributed storage setup complete",
 			"managers", len(storageManagers),
 			"workers", len(storageWorkers),
@@ -161,14 +373,138 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 	} else {
 		log.Infow("Phase 6: Skipping distributed storage (no nodes with storageEnabled)")
 	}
-	phasesCompleted++
+	return nil
+}
+
+// reconcileStorageRoles moves MON and OSD services of nodes whose role changed
+// since the last deploy (recorded in the "node.role" Swarm label): managers run
+// a MON, workers hold OSDs and "both" nodes do both. Nodes without a role
+// change are left alone, since MicroCeph also places MONs on workers while the
+// cluster is small. MONs are added before any are removed, and OSDs are only
+// removed once Ceph reports their data safe elsewhere.
+func reconcileStorageRoles(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) error {
+	log := logging.L().With("component", "storage-roles")
+
+	previous := previousNodeRoles(ctx, cfg, sshPool)
+	if len(previous) == 0 {
+		return nil
+	}
 
-	// Phase 7: Setup Docker Swarm
-	log.Infow("Phase 7: Setting up Docker Swarm")
-	sshManagers, sshWorkers := categorizeNodes(cfg)
-	if len(sshManagers) == 0 {
-		return fmt.Errorf("no manager nodes found")
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
 	}
+	monNode, err := findStorageMON(ctx, cfg, sshPool, provider, "")
+	if err != nil {
+		return err
+	}
+
+	type roleChange struct {
+		node              config.NodeConfig
+		addMON, removeMON bool
+		removeOSD         bool
+	}
+	var changes []roleChange
+	for _, node := range getEnabledNodes(cfg) {
+		prev, ok := previous[node.SSHFQDNorIP]
+		if !node.StorageEnabled || !ok || prev == node.Role {
+			continue
+		}
+		hasMON, hasOSD, err := provider.NodeStorageRoles(ctx, sshPool, monNode, node.SSHFQDNorIP)
+		if err != nil {
+			return err
+		}
+		wasMON, wantMON := prev == "manager" || prev == "both", node.Role == "manager" || node.Role == "both"
+		wasOSD, wantOSD := prev == "worker" || prev == "both", node.Role == "worker" || node.Role == "both"
+		log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "role changed"), "previousRole", prev)
+
+		change := roleChange{
+			node:      node,
+			addMON:    !wasMON && wantMON && !hasMON,
+			removeMON: wasMON && !wantMON && hasMON,
+			removeOSD: wasOSD && !wantOSD && hasOSD,
+		}
+		if change.addMON || change.removeMON || change.removeOSD {
+			changes = append(changes, change)
+		}
+	}
+
+	for _, c := range changes {
+		if c.addMON {
+			log.Infow(formatNodeMessage("→", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "adding MON"))
+			if err := provider.EnableMON(ctx, sshPool, monNode, c.node.SSHFQDNorIP); err != nil {
+				return err
+			}
+			log.Infow(formatNodeMessage("✓", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "MON added"))
+		}
+	}
+	for _, c := range changes {
+		if !c.removeMON && !c.removeOSD {
+			continue
+		}
+		otherMON, err := findStorageMON(ctx, cfg, sshPool, provider, c.node.SSHFQDNorIP)
+		if err != nil {
+			return err
+		}
+		if c.removeMON {
+			log.Infow(formatNodeMessage("→", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "removing MON"), "monNode", otherMON)
+			if err := provider.DisableMON(ctx, sshPool, otherMON, c.node.SSHFQDNorIP); err != nil {
+				return err
+			}
+			log.Infow(formatNodeMessage("✓", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "MON removed"))
+		}
+		if c.removeOSD {
+			log.Infow(formatNodeMessage("→", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "draining and removing OSDs"), "monNode", otherMON)
+			if err := provider.RemoveStorage(ctx, sshPool, otherMON, c.node.SSHFQDNorIP); err != nil {
+				return err
+			}
+			log.Infow(formatNodeMessage("✓", c.node.SSHFQDNorIP, c.node.NewHostname, c.node.Role, "OSDs removed"))
+		}
+	}
+	return nil
+}
+
+// previousNodeRoles returns the roles recorded in the "node.role" Swarm label
+// by the last deploy, keyed by SSH address. It is empty before the Swarm exists.
+func previousNodeRoles(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) map[string]string {
+	managers, workers := categorizeNodes(cfg)
+	manager, err := findReachableManager(ctx, sshPool, append(append([]string{}, managers...), workers...))
+	if err != nil {
+		return nil
+	}
+
+	stdout, _, err := sshPool.Run(ctx, manager, `docker node ls -q | xargs -r docker node inspect --format '{{.Description.Hostname}} {{index .Spec.Labels "node.role"}}'`)
+	if err != nil {
+		return nil
+	}
+	byHostname := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			byHostname[fields[0]] = fields[1]
+		}
+	}
+
+	roles := make(map[string]string)
+	for _, node := range getEnabledNodes(cfg) {
+		hostname := node.NewHostname
+		if hostname == "" {
+			out, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname 2>/dev/null")
+			if err != nil {
+				continue
+			}
+			hostname = strings.TrimSpace(out)
+		}
+		if role, ok := byHostname[hostname]; ok {
+			roles[node.SSHFQDNorIP] = role
+		}
+	}
+	return roles
+}
+
+// setupSwarmPhase initializes Docker Swarm, joins all nodes and creates the
+// default overlay networks (Phase 7).
+func setupSwarmPhase(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, sshManagers, sshWorkers []string) error {
+	log := logging.L().With("component", "deployer")
 
 	// Get overlay info for all nodes if overlay provider is configured
 	allSSHNodes := append(sshManagers, sshWorkers...)
@@ -220,13 +556,7 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 	primaryMasterInfo := overlayInfoMap[primaryMaster]
 
 	// Build join address for remote nodes to connect to primary manager
-	// Priority: FQDN > overlay IP (interface names like wt0 won't work remotely)
-	var primaryMasterJoinAddr string
-	if primaryMasterInfo.FQDN != "" && primaryMasterInfo.FQDN != primaryMaster {
-		primaryMasterJoinAddr = primaryMasterInfo.FQDN + ":2377"
-	} else {
-		primaryMasterJoinAddr = primaryMasterInfo.IP + ":2377"
-	}
+	primaryMasterJoinAddr := swarmJoinAddress(primaryMaster, primaryMasterInfo)
 
 	log.Infow("→ Using addresses for Docker Swarm",
 		"primaryAdvertiseAddr", primaryMasterAdvertiseAddr,
@@ -238,73 +568,108 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 	}
 	log.Infow("✅ Docker Swarm setup complete", "primaryAdvertiseAddr", primaryMasterAdvertiseAddr, "primaryJoinAddr", primaryMasterJoinAddr)
 
-	// Get primary manager's Docker Swarm hostname for services that need to connect to Docker API
-	var dockerManagerHost string
-	stdout, _, err := sshPool.Run(ctx, primaryMaster, "docker node ls --filter role=manager --format '{{.Hostname}}' | head -1")
-	if err == nil && strings.TrimSpace(stdout) != "" {
-		dockerManagerHost = strings.TrimSpace(stdout)
-		log.Infow("primary manager Docker hostname", "hostname", dockerManagerHost)
-	} else {
-		// Fallback to SSH hostname
-		dockerManagerHost = primaryMaster
-		log.Warnw("could not determine Docker manager hostname, using SSH address", "fallback", dockerManagerHost)
-	}
 
-	// Phase 7b: Create default overlay networks
+	// Create default overlay networks
 	log.Infow("→ Creating default Docker Swarm overlay networks")
 	if err := createDefaultOverlayNetworks(ctx, sshPool, primaryMaster); err != nil {
 		return fmt.Errorf("failed to create overlay networks: %w", err)
 	}
 	log.Infow("✅ Overlay networks created")
-	phasesCompleted++
+	return nil
+}
 
-	// Phase 8: Detect geolocation and apply node labels
-	log.Infow("Phase 8: Detecting geolocation and applying node labels")
-	if err := applyNodeLabels(ctx, cfg, sshPool, primaryMaster); err != nil {
-		return fmt.Errorf("failed to apply node labels: %w", err)
-	}
-	log.Infow("✅ Node labels applied")
-	phasesCompleted++
+// mapNodeHostnames maps the hostname of each node to its SSH address.
+// Always queries the node for its actual hostname - this is what Docker Swarm uses
+func mapNodeHostnames(ctx context.Context, sshPool *ssh.Pool, nodes []config.NodeConfig) map[string]string {
+	log := logging.L().With("component", "deployer")
 
-	// Phase 8b: Configure Keepalived for high availability (if enabled)
-	var keepalivedVIP string // Used later for credentials file
-	if cfg.IsKeepalivedEnabled() {
-		log.Infow("Phase 8b: Configuring Keepalived for high availability")
-		keepalivedDeployment, err := services.PrepareKeepalivedDeployment(ctx, sshPool, cfg)
+	nodeHostnameToSSH := make(map[string]string)
+	for _, node := range nodes {
+		stdout, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname 2>/dev/null")
 		if err != nil {
-			log.Warnw("failed to prepare Keepalived deployment", "error", err)
-		} else if keepalivedDeployment.Enabled {
-			if err := services.InstallAndConfigureKeepalived(ctx, sshPool, keepalivedDeployment); err != nil {
-				log.Warnw("failed to configure Keepalived", "error", err)
-			} else {
-				log.Infow("✅ Keepalived configured",
-					"vip", keepalivedDeployment.VIPCIDR,
-					"interface", keepalivedDeployment.Interface,
-					"nodeCount", len(keepalivedDeployment.Nodes),
-				)
-				keepalivedVIP = keepalivedDeployment.VIP // Store VIP for later use
-			}
+			log.Warnw("failed to query hostname from node", "sshHost", node.SSHFQDNorIP, "error", err)
+			continue
+		}
+		hostname := strings.TrimSpace(stdout)
+		if hostname != "" {
+			nodeHostnameToSSH[hostname] = node.SSHFQDNorIP
+			log.Debugw("mapped node hostname to SSH", "hostname", hostname, "sshHost", node.SSHFQDNorIP)
 		}
 	}
-	phasesCompleted++
+	return nodeHostnameToSSH
+}
 
-	// Phase 8c: Configure per-node settings (ManagementPanel, Firewall)
-	log.Infow("Phase 8c: Configuring per-node settings (ManagementPanel, Firewall)")
-	nodeConfigurator := nodeconfig.NewNodeConfigurator(sshPool)
-	if err := nodeConfigurator.ConfigureAllNodes(ctx, enabledNodes); err != nil {
-		log.Warnw("failed to configure some node settings", "error", err)
-	} else {
-		log.Infow("✅ Per-node configuration complete")
+// serviceDefinitionDir returns the configured service definition directory,
+// defaulting to "services" next to the binary.
+func serviceDefinitionDir(cfg *config.Config) string {
+	if dir := cfg.GlobalSettings.ServiceDefinitionDirectory; dir != "" {
+		return dir
 	}
-	phasesCompleted++
+	exePath, _ := os.Executable()
+	return filepath.Join(filepath.Dir(exePath), "services")
+}
+
+// acmeOptions returns the ACME settings for service deployment, or nil when
+// certificate issuance is disabled.
+func acmeOptions(cfg *config.Config) *services.ACMEOptions {
+	acme := cfg.GlobalSettings.ACME
+	if !acme.Enabled {
+		return nil
+	}
+	return &services.ACMEOptions{
+		DirectoryURL:       acme.DirectoryURL,
+		Email:              acme.Email,
+		RenewBefore:        time.Duration(acme.RenewBeforeDays) * 24 * time.Hour,
+		InsecureSkipVerify: acme.InsecureSkipVerify,
+	}
+}
+
+// edgeAuthOptions returns the OIDC settings of the EdgeAuth companion, or nil
+// when OIDC authentication is disabled.
+func edgeAuthOptions(cfg *config.Config) *services.EdgeAuthOptions {
+	edgeAuth := cfg.GlobalSettings.EdgeAuth
+	if !edgeAuth.Enabled {
+		return nil
+	}
+	return &services.EdgeAuthOptions{
+		IssuerURL:          edgeAuth.IssuerURL,
+		ClientID:           edgeAuth.ClientID,
+		ClientSecret:       edgeAuth.ClientSecret,
+		CookieSecret:       edgeAuth.CookieSecret,
+		EmailDomains:       edgeAuth.EmailDomains,
+		AllowedGroups:      edgeAuth.AllowedGroups,
+		Scope:              edgeAuth.Scope,
+		Image:              edgeAuth.Image,
+		InsecureSkipVerify: edgeAuth.InsecureSkipVerify,
+	}
+}
+
+// swarmJoinAddress returns the address remote nodes use to join the Swarm
+// through a manager. Priority: FQDN > overlay IP (interface names like wt0
+// won't work remotely).
+func swarmJoinAddress(sshHost string, info OverlayInfo) string {
+	if info.FQDN != "" && info.FQDN != sshHost {
+		return info.FQDN + ":2377"
+	}
+	return info.IP + ":2377"
+}
+
+// deployServicesPhase deploys service definitions to the Swarm (Phase 9).
+// It is shared by Deploy and the standalone "services deploy" command.
+func deployServicesPhase(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, primaryMaster string, allSSHNodes []string, hasDedicatedWorkers bool, dockerManagerHost, keepalivedVIP string) error {
+	log := logging.L().With("phase", "services")
+	ds := cfg.GetDistributedStorage()
+	enabledNodes := getEnabledNodes(cfg)
 
-	// Phase 9: Deploy services from YAML files
-	log.Infow("Phase 9: Deploying services")
 	storageMountPath := ""
 	s3CredentialsFile := ""
 	radosGatewayPort := 0
+	blockVolumeDriver := ""
 	if ds.Enabled {
 		storageMountPath = ds.Providers.MicroCeph.MountPath
+		if ds.Providers.MicroCeph.EnableRBD {
+			blockVolumeDriver = defaults.RBDVolumeDriver
+		}
 		// Pass S3 credentials info if RGW is enabled
 		if ds.Providers.MicroCeph.EnableRadosGateway {
 			s3CredentialsFile = ds.Providers.MicroCeph.S3CredentialsFile
@@ -314,12 +679,7 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 
 	// Check if Portainer is enabled by scanning service definitions
 	portainerEnabled := false
-	serviceDefDir := cfg.GlobalSettings.ServiceDefinitionDirectory
-	if serviceDefDir == "" {
-		exePath, _ := os.Executable()
-		serviceDefDir = filepath.Join(filepath.Dir(exePath), "services")
-	}
-	svcList, _ := services.DiscoverServices(serviceDefDir)
+	svcList, _ := services.DiscoverServices(serviceDefinitionDir(cfg))
 	for _, svc := range svcList {
 		if svc.Enabled && strings.EqualFold(svc.Name, "Portainer") {
 			portainerEnabled = true
@@ -328,39 +688,25 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 	}
 
 	// Build hostname to SSH mapping for container discovery
-	// Always queries the node for its actual hostname - this is what Docker Swarm uses
-	nodeHostnameToSSH := make(map[string]string)
-	for _, node := range enabledNodes {
-		stdout, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname 2>/dev/null")
-		if err != nil {
-			log.Warnw("failed to query hostname from node", "sshHost", node.SSHFQDNorIP, "error", err)
-			continue
-		}
-		hostname := strings.TrimSpace(stdout)
-		if hostname != "" {
-			nodeHostnameToSSH[hostname] = node.SSHFQDNorIP
-			log.Debugw("mapped node hostname to SSH", "hostname", hostname, "sshHost", node.SSHFQDNorIP)
-		}
-	}
+	nodeHostnameToSSH := mapNodeHostnames(ctx, sshPool, enabledNodes)
 
-	// Determine if cluster has dedicated workers for placement constraint handling
-	// sshWorkers only contains nodes with role="worker" (not "both" or "manager")
+	// hasDedicatedWorkers is true only for nodes with role="worker" (not "both" or "manager")
 	clusterInfo := services.ClusterInfo{
-		HasDedicatedWorkers:       len(sshWorkers) > 0,
+		HasDedicatedWorkers:       hasDedicatedWorkers,
 		AllNodes:                  allSSHNodes,   // All nodes for directory creation
 		DistributedStorageEnabled: ds.Enabled,    // If true, storage is shared across nodes
 		PrimaryMaster:             primaryMaster, // Primary master for env var
 		DockerManagerHost:         dockerManagerHost,
+		BlockVolumeDriver:         blockVolumeDriver,
 		S3CredentialsFile:         s3CredentialsFile,
 		RadosGatewayPort:          radosGatewayPort,
 		KeepalivedVIP:             keepalivedVIP,
 		PortainerEnabled:          portainerEnabled,
 		NodeHostnameToSSH:         nodeHostnameToSSH,
+		ACME:                      acmeOptions(cfg),
+		EdgeAuth:                  edgeAuthOptions(cfg),
 	}
 	metrics, err := services.DeployServices(ctx, sshPool, primaryMaster, cfg.GlobalSettings.ServiceDefinitionDirectory, storageMountPath, clusterInfo)
-	if err != nil {
-		log.Warnw("service deployment encountered errors", "error", err)
-	}
 	if metrics != nil {
 		log.Infow("✅ Service deployment complete",
 			"found", metrics.TotalFound,
@@ -371,49 +717,7 @@ func Deploy(ctx context.Context, cfg *config.Config) error {
 			"duration", metrics.Duration.String(),
 		)
 	}
-	phasesCompleted++
-
-	// Phase 10: Execute post-deployment scripts
-	log.Infow("Phase 10: Executing post-deployment scripts")
-	if err := executeScripts(ctx, cfg, sshPool, cfg.GlobalSettings.PostScripts, "post"); err != nil {
-		return fmt.Errorf("failed to execute post-deployment scripts: %w", err)
-	}
-	log.Infow("✅ Post-deployment scripts complete")
-	phasesCompleted++
-
-	// Phase 11: Reboot nodes if configured
-	log.Infow("Phase 11: Rebooting nodes if configured")
-	if err := rebootNodes(ctx, cfg, sshPool); err != nil {
-		return fmt.Errorf("failed to reboot nodes: %w", err)
-	}
-	log.Infow("✅ Reboot initiated for configured nodes")
-	phasesCompleted++
-
-	// Phase 12: Remove SSH public key from nodes if configured
-	if cfg.GlobalSettings.RemoveSSHPublicKeyOnCompletion {
-		log.Infow("Phase 12: Removing SSH public key from nodes on completion")
-		if err := removeSSHPublicKeyFromNodes(ctx, cfg, sshPool, keyPair); err != nil {
-			log.Warnw("failed to remove SSH public key from nodes", "error", err)
-		} else {
-			log.Infow("✅ SSH public key removed from nodes")
-		}
-		log.Infow("ℹ️  Local SSH key pair kept in sshkeys/ directory for future use")
-	} else {
-		log.Infow("Phase 12: Skipping SSH public key removal (removeSSHPublicKeyOnCompletion=false)")
-	}
-	phasesCompleted++
-
-	// Calculate final metrics
-	endTime := time.Now()
-	duration := endTime.Sub(startTime)
-
-	log.Infow("🎉 Cluster deployment complete!",
-		"totalDuration", duration.String(),
-		"phasesCompleted", phasesCompleted,
-		"startTime", startTime.Format(time.RFC3339),
-		"endTime", endTime.Format(time.RFC3339),
-	)
-	return nil
+	return err
 }
 
 // Teardown orchestrates the complete cluster teardown from the configuration.
@@ -542,6 +846,11 @@ func prepareSSHKeys(cfg *config.Config) (*sshkeys.KeyPair, error) {
 
 	log.Infow("SSH key pair ready", "privateKey", keyPair.PrivateKeyPath, "keyType", keyType)
 
+	knownHosts, err := openKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+
 	// Install public key on enabled nodes that don't already use automatic key pair
 	// (these nodes will use password/privateKeyPath for initial connection, then key for future)
 	ctx := context.Background()
@@ -581,6 +890,8 @@ func prepareSSHKeys(cfg *config.Config) (*sshkeys.KeyPair, error) {
 			PrivateKeyPath:     node.PrivateKeyPath,
 			PrivateKeyPassword: node.PrivateKeyPassword,
 			Port:               node.SSHPort,
+			KnownHosts:         knownHosts,
+			HostKeyFingerprint: node.HostKeyFingerprint,
 		}
 
 		tempPool := ssh.NewPool(map[string]ssh.AuthConfig{
@@ -590,7 +901,10 @@ func prepareSSHKeys(cfg *config.Config) (*sshkeys.KeyPair, error) {
 		// Check if public key already exists
 		pubKeyTrimmed := strings.TrimSpace(keyPair.PublicKey)
 		checkCmd := fmt.Sprintf("grep -qF '%s' ~/.ssh/authorized_keys 2>/dev/null && echo 'EXISTS' || echo 'NOT_EXISTS'", pubKeyTrimmed)
-		stdout, _, _ := tempPool.Run(ctx, node.SSHFQDNorIP, checkCmd)
+		stdout, _, err := tempPool.Run(ctx, node.SSHFQDNorIP, checkCmd)
+		if ssh.IsHostKeyMismatch(err) {
+			return nil, fmt.Errorf("refusing to connect to %s: %w", node.SSHFQDNorIP, err)
+		}
 
 		if strings.TrimSpace(stdout) == "EXISTS" {
 			nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "public key already exists"))
@@ -616,11 +930,50 @@ func prepareSSHKeys(cfg *config.Config) (*sshkeys.KeyPair, error) {
 	return keyPair, nil
 }
 
+// openKnownHosts loads the known_hosts store used to verify node host keys.
+func openKnownHosts() (*ssh.KnownHosts, error) {
+	path, err := sshkeys.KnownHostsPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve known_hosts path: %w", err)
+	}
+
+	knownHosts, err := ssh.NewKnownHosts(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+	return knownHosts, nil
+}
+
+// ForgetHostKey removes the recorded host key of a node (host or host:port)
+// from known_hosts, so the next connection accepts and records its new key.
+// This is the only way to accept a changed key, e.g. after a reinstall.
+func ForgetHostKey(host string) error {
+	knownHosts, err := openKnownHosts()
+	if err != nil {
+		return err
+	}
+
+	removed, err := knownHosts.Forget(host)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%s has no entry in %s", host, knownHosts.Path())
+	}
+	logging.L().Infow("✓ host key forgotten; the next connection records the new key", "host", host, "file", knownHosts.Path())
+	return nil
+}
+
 // createSSHPool creates an SSH connection pool from the configuration.
 func createSSHPool(cfg *config.Config, keyPair *sshkeys.KeyPair) (*ssh.Pool, error) {
 	log := logging.L().With("phase", "ssh-pool")
 	authConfigs := make(map[string]ssh.AuthConfig)
 
+	knownHosts, err := openKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+
 	enabledNodes := getEnabledNodes(cfg)
 	log.Infow("creating SSH connection pool", "totalNodes", len(enabledNodes))
 
@@ -651,8 +1004,10 @@ func createSSHPool(cfg *config.Config, keyPair *sshkeys.KeyPair) (*ssh.Pool, err
 		nodeLog.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "configuring SSH connection"))
 
 		authConfig := ssh.AuthConfig{
-			Username: node.Username,
-			Port:     node.SSHPort,
+			Username:           node.Username,
+			Port:               node.SSHPort,
+			KnownHosts:         knownHosts,
+			HostKeyFingerprint: node.HostKeyFingerprint,
 		}
 
 		if node.UseSSHAutomaticKeyPair && keyPair != nil {
@@ -707,6 +1062,7 @@ func installDependencies(ctx context.Context, cfg *config.Config, sshPool *ssh.P
 		// Install Docker (pass node role to determine if API should be enabled)
 		nodeLog.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "installing Docker"))
 		if err := installDocker(ctx, sshPool, node.SSHFQDNorIP, node.Role); err != nil {
+			recordNodeResult(ctx, node.SSHFQDNorIP, err)
 			return fmt.Errorf("failed to install Docker on %s: %w", node.SSHFQDNorIP, err)
 		}
 		nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "Docker installed"))
@@ -715,6 +1071,7 @@ func installDependencies(ctx context.Context, cfg *config.Config, sshPool *ssh.P
 		// to avoid duplicate installation and ensure proper cluster formation
 
 		nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "all dependencies installed"))
+		recordNodeResult(ctx, node.SSHFQDNorIP, nil)
 	}
 
 	return nil
@@ -972,9 +1329,11 @@ func configureOverlay(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool
 
 		nodeLog.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "configuring overlay network"))
 		if err := configureOverlayOnNode(ctx, sshPool, node, provider, overlayConfig); err != nil {
+			recordNodeResult(ctx, node.SSHFQDNorIP, err)
 			return fmt.Errorf("failed to configure %s overlay on %s: %w", provider, node.SSHFQDNorIP, err)
 		}
 		nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "overlay network configured"))
+		recordNodeResult(ctx, node.SSHFQDNorIP, nil)
 	}
 
 	log.Infow("✅ overlay network configured", "provider", provider)
@@ -997,53 +1356,6 @@ func configureOverlayOnNode(ctx context.Context, sshPool *ssh.Pool, node config.
 	}
 }
 
-// maskSetupKey masks setup keys for logging (replaces key with asterisks)
-// Handles formats like:
-//   --setup-key "C70334FC-522E-40E4-923A-2ECAE9400EAA" --allow-server-ssh
-//   --authkey='tskey-abc123def456'
-func maskSetupKey(fullConfig string) string {
-	if fullConfig == "" {
-		return ""
-	}
-
-	// Handle quoted keys: --setup-key "KEY" or --authkey='KEY'
-	// Replace the key value (inside quotes or after flag) with asterisks
-	result := fullConfig
-
-	// Pattern 1: --setup-key "UUID" or --setup-key 'UUID'
-	setupKeyPattern := `(--setup-key\s+["']?)([A-Za-z0-9-]+)(["']?)`
-	re := regexp.MustCompile(setupKeyPattern)
-	result = re.ReplaceAllStringFunc(result, func(match string) string {
-		// Extract the key and replace with asterisks of same length
-		submatches := re.FindStringSubmatch(match)
-		if len(submatches) >= 4 {
-			prefix := submatches[1]  // --setup-key " or --setup-key '
-			key := submatches[2]     // The actual key
-			suffix := submatches[3]  // " or '
-			masked := strings.Repeat("*", len(key))
-			return prefix + masked + suffix
-		}
-		return match
-	})
-
-	// Pattern 2: --authkey="KEY" or --authkey='KEY'
-	authKeyPattern := `(--authkey\s*=\s*["']?)([A-Za-z0-9-]+)(["']?)`
-	re2 := regexp.MustCompile(authKeyPattern)
-	result = re2.ReplaceAllStringFunc(result, func(match string) string {
-		submatches := re2.FindStringSubmatch(match)
-		if len(submatches) >= 4 {
-			prefix := submatches[1]
-			key := submatches[2]
-			suffix := submatches[3]
-			masked := strings.Repeat("*", len(key))
-			return prefix + masked + suffix
-		}
-		return match
-	})
-
-	return result
-}
-
 // configureNetbirdOnNode configures Netbird on a single node idempotently.
 func configureNetbirdOnNode(ctx context.Context, sshPool *ssh.Pool, node config.NodeConfig, overlayConfig string) error {
 	log := logging.L().With("node", node.SSHFQDNorIP, "provider", "netbird")
@@ -1099,16 +1411,14 @@ func configureNetbirdOnNode(ctx context.Context, sshPool *ssh.Pool, node config.
 
 	// Start netbird with setup key and flags if provided (with retry)
 	// overlayConfig can contain full flags like: --setup-key "KEY" --allow-server-ssh --enable-ssh-remote-port-forwarding
+	// The setup key is registered as a secret when the config is loaded and redacted from logs
 	upCmd := "netbird up"
-	maskedCmd := "netbird up"
 	if overlayConfig != "" {
 		upCmd = fmt.Sprintf("netbird up %s", overlayConfig)
-		// Mask the setup key for logging (preserves all flags, just masks the key value)
-		maskedCmd = fmt.Sprintf("netbird up %s", maskSetupKey(overlayConfig))
 	}
 
 	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "starting netbird and connecting to network"))
-	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("command: %s", maskedCmd)))
+	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("command: %s", upCmd)))
 	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "executing netbird up..."))
 
 	retryCfg := retry.NetworkConfig(fmt.Sprintf("start-netbird-%s", node.SSHFQDNorIP))
@@ -1197,16 +1507,14 @@ func configureTailscaleOnNode(ctx context.Context, sshPool *ssh.Pool, node confi
 	}
 
 	// Start tailscale with auth key if provided (with retry)
+	// The auth key is registered as a secret when the config is loaded and redacted from logs
 	upCmd := "tailscale up"
-	maskedCmd := "tailscale up"
 	if overlayConfig != "" {
 		upCmd = fmt.Sprintf("tailscale up --authkey='%s'", overlayConfig)
-		// Mask the auth key for logging
-		maskedCmd = fmt.Sprintf("tailscale up --authkey='%s'", maskSetupKey(overlayConfig))
 	}
 
 	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "starting tailscale and connecting to network"))
-	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("command: %s", maskedCmd)))
+	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("command: %s", upCmd)))
 	log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "executing tailscale up..."))
 
 	retryCfg := retry.NetworkConfig(fmt.Sprintf("start-tailscale-%s", node.SSHFQDNorIP))
@@ -1395,6 +1703,7 @@ func setHostnames(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) er
 		stdout, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname")
 		if err == nil && stdout == node.NewHostname+"\n" {
 			nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "hostname already set, skipping"))
+			recordNodeResult(ctx, node.SSHFQDNorIP, nil)
 			continue
 		}
 
@@ -1403,10 +1712,12 @@ func setHostnames(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool) er
 		nodeLog.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("executing hostname change (command: %s)", setCmd)))
 
 		if _, stderr, err := sshPool.Run(ctx, node.SSHFQDNorIP, setCmd); err != nil {
+			recordNodeResult(ctx, node.SSHFQDNorIP, err)
 			return fmt.Errorf("failed to set hostname on %s: %w (stderr: %s)", node.SSHFQDNorIP, err, stderr)
 		}
 
 		nodeLog.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "hostname set successfully"))
+		recordNodeResult(ctx, node.SSHFQDNorIP, nil)
 	}
 
 	return nil
@@ -1450,8 +1761,8 @@ func setRootPassword(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool)
 	return nil
 }
 
-// executeScripts executes scripts on nodes.
-func executeScripts(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, scripts []config.ScriptConfig, phase string) error {
+// executeScripts executes scripts on nodes and adds each run to the report.
+func executeScripts(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, scripts []config.ScriptConfig, phase string, report *ScriptReport) error {
 	log := logging.L().With("phase", fmt.Sprintf("%s-scripts", phase))
 
 	if len(scripts) == 0 {
@@ -1535,7 +1846,9 @@ func executeScripts(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool,
 			)
 
 			nodeLog.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, "executing script on node"))
-			if err := executeScriptOnNode(ctx, sshPool, node, script); err != nil {
+			result, err := executeScriptOnNode(ctx, cfg, sshPool, node, script, phase)
+			report.add(result)
+			if err != nil {
 				if script.ContinueOnError {
 					nodeLog.Warnw(formatNodeMessage("✗", node.SSHFQDNorIP, node.NewHostname, node.Role, "script failed but continuing (continueOnError=true)"), "error", err)
 				} else {
@@ -1553,47 +1866,135 @@ func executeScripts(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool,
 }
 
 // executeScriptOnNode executes a single script on a single node.
-func executeScriptOnNode(ctx context.Context, sshPool *ssh.Pool, node config.NodeConfig, script config.ScriptConfig) error {
+// Remote scripts are downloaded on the node, local scripts are uploaded over SSH.
+// The script file is removed from the node afterwards. The returned result is
+// non-nil once the node has been contacted, even when the script fails.
+func executeScriptOnNode(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, node config.NodeConfig, script config.ScriptConfig, phase string) (*ScriptResult, error) {
 	log := logging.L().With("node", node.SSHFQDNorIP, "script", script.Name)
 
+	result := &ScriptResult{
+		Node:      node.SSHFQDNorIP,
+		Script:    script.Name,
+		Source:    script.Source,
+		Phase:     phase,
+		StartedAt: time.Now(),
+		ExitCode:  -1,
+	}
+	fail := func(err error) (*ScriptResult, error) {
+		result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+		result.Error = logging.Redact(err.Error())
+		return result, err
+	}
+
 	// Determine if script is local or remote
-	isRemote := len(script.Source) > 7 && (script.Source[:7] == "http://" || script.Source[:8] == "https://")
+	isRemote := strings.HasPrefix(script.Source, "http://") || strings.HasPrefix(script.Source, "https://")
+
+	scriptPath := fmt.Sprintf("/tmp/dscotctl-script-%s.sh", sanitizeFileName(script.Name))
+	defer func() {
+		if err := sshPool.Remove(ctx, node.SSHFQDNorIP, scriptPath); err != nil {
+			log.Warnw("failed to remove script from node", "path", scriptPath, "error", err)
+		}
+	}()
 
-	var scriptPath string
 	if isRemote {
 		// Download remote script
-		scriptPath = fmt.Sprintf("/tmp/clusterctl-script-%s.sh", script.Name)
-		downloadCmd := fmt.Sprintf("curl -fsSL -o %s %s", scriptPath, script.Source)
+		downloadCmd := fmt.Sprintf("curl -fsSL -o %s %s", ssh.ShellQuote(scriptPath), ssh.ShellQuote(script.Source))
 		if _, stderr, err := sshPool.Run(ctx, node.SSHFQDNorIP, downloadCmd); err != nil {
-			return fmt.Errorf("failed to download script: %w (stderr: %s)", err, stderr)
+			return fail(fmt.Errorf("failed to download script: %w (stderr: %s)", err, stderr))
 		}
 		log.Infow("downloaded remote script", "url", script.Source)
 	} else {
-		// Transfer local script
-		// TODO: Implement file transfer via SSH (SCP or SFTP)
-		// For now, we'll assume the script is already on the remote host or use a workaround
-		return fmt.Errorf("local script transfer not yet implemented (script: %s)", script.Source)
+		// Upload local script, converting CRLF to LF so scripts edited on Windows run on Linux
+		localPath := cfg.ResolvePath(script.Source)
+		content, err := os.ReadFile(localPath)
+		if err != nil {
+			return fail(fmt.Errorf("failed to read local script %s: %w", localPath, err))
+		}
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		if err := sshPool.WriteFile(ctx, node.SSHFQDNorIP, scriptPath, content, 0755); err != nil {
+			return fail(fmt.Errorf("failed to upload script: %w", err))
+		}
+		log.Infow("uploaded local script", "path", localPath, "size", len(content))
 	}
 
 	// Make script executable
 	chmodCmd := fmt.Sprintf("chmod +x %s", scriptPath)
 	if _, stderr, err := sshPool.Run(ctx, node.SSHFQDNorIP, chmodCmd); err != nil {
-		return fmt.Errorf("failed to make script executable: %w (stderr: %s)", err, stderr)
+		return fail(fmt.Errorf("failed to make script executable: %w (stderr: %s)", err, stderr))
 	}
 
-	// Execute script with parameters
-	execCmd := scriptPath
-	if script.Parameters != "" {
-		execCmd = fmt.Sprintf("%s %s", scriptPath, script.Parameters)
+	execCmd := buildScriptCommand(scriptPath, script)
+	stdout, stderr, err := sshPool.Run(ctx, node.SSHFQDNorIP, execCmd)
+
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	result.ExitCode = ssh.ExitStatus(err)
+	result.TimedOut = script.TimeoutSeconds > 0 && result.ExitCode == 124
+	// Scripts may echo secrets; the report is persisted, so redact before keeping the tail
+	var truncOut, truncErr bool
+	result.Stdout, truncOut = truncateOutput(logging.Redact(stdout))
+	result.Stderr, truncErr = truncateOutput(logging.Redact(stderr))
+	result.Truncated = truncOut || truncErr
+
+	scriptLog := log.With("exitCode", result.ExitCode, "durationMs", result.DurationMs)
+	if result.Stdout != "" {
+		scriptLog.Infow("script stdout", "output", result.Stdout)
+	}
+	if result.Stderr != "" {
+		scriptLog.Warnw("script stderr", "output", result.Stderr)
 	}
 
-	stdout, stderr, err := sshPool.Run(ctx, node.SSHFQDNorIP, execCmd)
+	if result.TimedOut {
+		err = fmt.Errorf("script timed out after %ds", script.TimeoutSeconds)
+	}
 	if err != nil {
-		return fmt.Errorf("script execution failed: %w (stderr: %s)", err, stderr)
+		result.Error = logging.Redact(err.Error())
+		return result, fmt.Errorf("script execution failed (exit code %d): %w", result.ExitCode, err)
 	}
 
-	log.Infow("script executed", "stdout", stdout, "stderr", stderr)
-	return nil
+	scriptLog.Infow("script executed")
+	return result, nil
+}
+
+// buildScriptCommand builds the remote command line for a script, applying
+// its environment, timeout and runAs settings.
+func buildScriptCommand(scriptPath string, script config.ScriptConfig) string {
+	cmd := scriptPath
+	if script.Parameters != "" {
+		cmd = fmt.Sprintf("%s %s", cmd, script.Parameters)
+	}
+
+	if len(script.Environment) > 0 {
+		keys := make([]string, 0, len(script.Environment))
+		for k := range script.Environment {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		vars := make([]string, len(keys))
+		for i, k := range keys {
+			vars[i] = k + "=" + ssh.ShellQuote(script.Environment[k])
+		}
+		cmd = fmt.Sprintf("env %s %s", strings.Join(vars, " "), cmd)
+	}
+
+	if script.TimeoutSeconds > 0 {
+		cmd = fmt.Sprintf("timeout --kill-after=10 %d %s", script.TimeoutSeconds, cmd)
+	}
+
+	if script.RunAs != "" {
+		cmd = fmt.Sprintf("sudo -n -H -u %s -- %s", ssh.ShellQuote(script.RunAs), cmd)
+	}
+
+	return cmd
+}
+
+// sanitizeFileName replaces characters that are unsafe in remote file names.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
 }
 
 // rebootNodes reboots nodes if configured.
@@ -1633,6 +2034,7 @@ func applyNodeLabels(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool,
 		hostnames = append(hostnames, node.SSHFQDNorIP)
 	}
 	geoInfoMap := geolocation.DetectGeoLocationBatch(ctx, sshPool, hostnames)
+	warnManagerRegions(cfg, geoInfoMap)
 
 	// Apply labels to each node
 	for _, node := range cfg.Nodes {
@@ -1738,6 +2140,39 @@ func applyNodeLabels(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool,
 	return nil
 }
 
+// warnManagerRegions warns when every manager is in the same region, taken
+// from the node's "region" label or its detected geolocation. Managers that
+// all carry a "region" label are already reported by config.Warnings.
+func warnManagerRegions(cfg *config.Config, geoInfoMap map[string]*geolocation.GeoInfo) {
+	managers, _ := categorizeNodes(cfg)
+	if len(managers) < 2 {
+		return
+	}
+
+	regions := make(map[string]bool)
+	detected := false
+	for _, node := range getEnabledNodes(cfg) {
+		if node.Role != "manager" && node.Role != "both" {
+			continue
+		}
+		region := node.Labels["region"]
+		if geo := geoInfoMap[node.SSHFQDNorIP]; region == "" && geo != nil && geo.CountryCode != "" {
+			region = strings.ToLower(geo.CountryCode + "-" + geo.Region)
+			detected = true
+		}
+		if region == "" {
+			return
+		}
+		regions[region] = true
+	}
+
+	if len(regions) == 1 && detected {
+		for region := range regions {
+			logging.L().Warnw(fmt.Sprintf("⚠ all %d managers are in region %s; an outage of that region loses quorum", len(managers), region), "phase", "labels")
+		}
+	}
+}
+
 // removeSSHPublicKeyFromNodes removes the automatic SSH public key from all nodes.
 func removeSSHPublicKeyFromNodes(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, keyPair *sshkeys.KeyPair) error {
 	if keyPair == nil {
@@ -2126,6 +2561,30 @@ func setupDistributedStorage(ctx context.Context, sshPool *ssh.Pool, managers []
 	return nil
 }
 
+// setupRBDVolumePlugin installs the RBD Docker volume plugin on nodes so
+// services with STORAGE_CLASS: rbd can be scheduled on any of them.
+func setupRBDVolumePlugin(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, monNode string, nodes []string) error {
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage provider: %w", err)
+	}
+
+	monNodes, _, _ := getStorageNodesByRole(cfg)
+	if err := storage.SetupVolumePlugin(ctx, sshPool, provider, cfg, monNode, monNodes, nodes); err != nil {
+		return fmt.Errorf("failed to set up RBD volume plugin: %w", err)
+	}
+	return nil
+}
+
+// getNodeAddresses returns the SSH addresses of nodes.
+func getNodeAddresses(nodes []config.NodeConfig) []string {
+	addrs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, node.SSHFQDNorIP)
+	}
+	return addrs
+}
+
 // buildStorageNodeInfoMap creates a map of SSH hostname/IP to NodeInfo for storage logging.
 func buildStorageNodeInfoMap(cfg *config.Config) map[string]storage.NodeInfo {
 	nodeInfoMap := make(map[string]storage.NodeInfo)
@@ -0,0 +1,376 @@
+package deployer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dscotctl/internal/logging"
+)
+
+// Phase status values recorded in the run journal.
+const (
+	PhaseStatusRunning   = "running"
+	PhaseStatusCompleted = "completed"
+	PhaseStatusFailed    = "failed"
+	PhaseStatusPartial   = "partial"
+	PhaseStatusSkipped   = "skipped"
+)
+
+// partialError marks a phase error that does not abort the deployment. The
+// phase is journaled as partial, so -resume runs it again.
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// phasePartial wraps err so the phase runner records it and continues.
+func phasePartial(err error) error {
+	return &partialError{err: err}
+}
+
+// deployPhases lists the Deploy phases in execution order.
+// Phase IDs are used by the journal and by -from-phase / -only-phase.
+var deployPhases = []struct {
+	ID   string
+	Name string
+}{
+	{"1", "SSH keys and connections"},
+	{"2", "Hostnames"},
+	{"2.5", "Root password"},
+	{"3", "Pre-deployment scripts"},
+	{"4", "Dependencies"},
+	{"5", "Overlay network"},
+	{"6", "Distributed storage"},
+	{"7", "Docker Swarm"},
+	{"8", "Node labels"},
+	{"8b", "Keepalived"},
+	{"8c", "Per-node settings"},
+	{"9", "Services"},
+	{"10", "Post-deployment scripts"},
+	{"11", "Reboot nodes"},
+	{"12", "SSH public key removal"},
+}
+
+// DeployOptions controls which Deploy phases run.
+type DeployOptions struct {
+	Resume    bool   // Skip phases the journal records as completed for an unchanged config
+	FromPhase string // Start at this phase ID, skipping earlier phases
+	OnlyPhase string // Run only this phase ID (Phase 1 always runs to open connections)
+}
+
+// Validate checks that phase IDs in the options exist.
+func (o DeployOptions) Validate() error {
+	if o.FromPhase != "" && o.OnlyPhase != "" {
+		return fmt.Errorf("-from-phase and -only-phase cannot be combined")
+	}
+	for _, id := range []string{o.FromPhase, o.OnlyPhase} {
+		if id != "" && phaseIndex(id) < 0 {
+			return fmt.Errorf("unknown phase %q (valid phases: %s)", id, strings.Join(phaseIDs(), ", "))
+		}
+	}
+	return nil
+}
+
+// NodeRecord is the journal entry for one node within a phase.
+type NodeRecord struct {
+	Status      string    `json:"status"`
+	CompletedAt time.Time `json:"completedAt"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// PhaseRecord is the journal entry for one phase.
+type PhaseRecord struct {
+	Name        string                 `json:"name"`
+	Status      string                 `json:"status"`
+	StartedAt   time.Time              `json:"startedAt"`
+	CompletedAt time.Time              `json:"completedAt,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Nodes       map[string]*NodeRecord `json:"nodes,omitempty"`
+}
+
+// Journal is the persisted record of a Deploy run. It is written next to the
+// configuration file after every phase and node so a failed run can resume.
+type Journal struct {
+	ConfigPath string                  `json:"configPath"`
+	ConfigHash string                  `json:"configHash"`
+	StartedAt  time.Time               `json:"startedAt"`
+	UpdatedAt  time.Time               `json:"updatedAt"`
+	Phases     map[string]*PhaseRecord `json:"phases"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// JournalPath returns the journal file path for a configuration file,
+// e.g. /etc/dscotctl/cluster.json → /etc/dscotctl/cluster.journal.json.
+func JournalPath(configPath string) string {
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + ".journal.json"
+}
+
+// hashConfigFile returns the SHA-256 of the configuration file contents.
+func hashConfigFile(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config for hashing: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// openJournal loads the journal for configPath. When resume is false, or the
+// stored config hash differs from the current one, a fresh journal is started.
+func openJournal(configPath string, resume bool) (*Journal, error) {
+	log := logging.L().With("component", "journal")
+
+	configHash, err := hashConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	path := JournalPath(configPath)
+	fresh := &Journal{
+		ConfigPath: configPath,
+		ConfigHash: configHash,
+		StartedAt:  time.Now(),
+		Phases:     make(map[string]*PhaseRecord),
+		path:       path,
+	}
+
+	if !resume {
+		return fresh, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Infow("no run journal found, starting from the beginning", "path", path)
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run journal %s: %w", path, err)
+	}
+
+	var existing Journal
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return nil, fmt.Errorf("failed to parse run journal %s: %w", path, err)
+	}
+	if existing.ConfigHash != configHash {
+		log.Warnw("⚠ configuration changed since the journaled run, ignoring journal", "path", path)
+		return fresh, nil
+	}
+
+	existing.path = path
+	if existing.Phases == nil {
+		existing.Phases = make(map[string]*PhaseRecord)
+	}
+	log.Infow("resuming from run journal", "path", path, "startedAt", existing.StartedAt.Format(time.RFC3339))
+	return &existing, nil
+}
+
+// completed reports whether a phase finished successfully in the journaled run.
+func (j *Journal) completed(phaseID string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec, ok := j.Phases[phaseID]
+	return ok && rec.Status == PhaseStatusCompleted
+}
+
+// startPhase marks a phase as running.
+func (j *Journal) startPhase(phaseID, name string) {
+	j.mu.Lock()
+	j.Phases[phaseID] = &PhaseRecord{
+		Name:      name,
+		Status:    PhaseStatusRunning,
+		StartedAt: time.Now(),
+		Nodes:     make(map[string]*NodeRecord),
+	}
+	j.mu.Unlock()
+	j.save()
+}
+
+// finishPhase records the outcome of a phase.
+func (j *Journal) finishPhase(phaseID string, err error) {
+	j.mu.Lock()
+	if rec, ok := j.Phases[phaseID]; ok {
+		rec.CompletedAt = time.Now()
+		rec.Status = PhaseStatusCompleted
+		var partial *partialError
+		if errors.As(err, &partial) {
+			rec.Status = PhaseStatusPartial
+			rec.Error = logging.Redact(err.Error())
+		} else if err != nil {
+			rec.Status = PhaseStatusFailed
+			rec.Error = logging.Redact(err.Error())
+		}
+	}
+	j.mu.Unlock()
+	j.save()
+}
+
+// skipPhase records that a phase was not run in this invocation. A phase that
+// completed in an earlier run keeps its completed record.
+func (j *Journal) skipPhase(phaseID, name string) {
+	j.mu.Lock()
+	if rec, ok := j.Phases[phaseID]; !ok || rec.Status != PhaseStatusCompleted {
+		j.Phases[phaseID] = &PhaseRecord{Name: name, Status: PhaseStatusSkipped}
+	}
+	j.mu.Unlock()
+	j.save()
+}
+
+// recordNode records the outcome of a phase on a single node.
+func (j *Journal) recordNode(phaseID, host string, err error) {
+	j.mu.Lock()
+	rec, ok := j.Phases[phaseID]
+	if !ok {
+		j.mu.Unlock()
+		return
+	}
+	node := &NodeRecord{Status: PhaseStatusCompleted, CompletedAt: time.Now()}
+	if err != nil {
+		node.Status = PhaseStatusFailed
+		node.Error = logging.Redact(err.Error())
+	}
+	rec.Nodes[host] = node
+	j.mu.Unlock()
+	j.save()
+}
+
+// save writes the journal to disk. Failures are logged but never abort a deployment.
+// Errors are redacted when they are recorded, as they may quote secrets.
+func (j *Journal) save() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		logging.L().Warnw("failed to encode run journal", "error", err)
+		return
+	}
+
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		logging.L().Warnw("failed to write run journal", "path", j.path, "error", err)
+		return
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		logging.L().Warnw("failed to write run journal", "path", j.path, "error", err)
+	}
+}
+
+// journalContextKey carries the journal and current phase through a context so
+// per-node loops can record progress without threading extra parameters.
+type journalContextKey struct{}
+
+type journalContext struct {
+	journal *Journal
+	phaseID string
+}
+
+// withPhaseJournal returns a context that records node results for phaseID.
+func withPhaseJournal(ctx context.Context, j *Journal, phaseID string) context.Context {
+	return context.WithValue(ctx, journalContextKey{}, journalContext{journal: j, phaseID: phaseID})
+}
+
+// recordNodeResult records a node outcome for the phase carried by ctx, if any.
+func recordNodeResult(ctx context.Context, host string, err error) {
+	jc, ok := ctx.Value(journalContextKey{}).(journalContext)
+	if !ok || jc.journal == nil {
+		return
+	}
+	jc.journal.recordNode(jc.phaseID, host, err)
+}
+
+// phaseRunner decides which phases run and records them in the journal.
+type phaseRunner struct {
+	journal   *Journal
+	opts      DeployOptions
+	completed int // Phases run successfully in this invocation
+	partial   int // Phases that finished with non-fatal errors
+	skipped   int // Phases skipped by options or journal
+}
+
+// run executes fn for phaseID unless the options or journal say to skip it.
+// A phasePartial error is journaled and logged but not returned.
+func (r *phaseRunner) run(ctx context.Context, phaseID string, fn func(ctx context.Context) error) error {
+	log := logging.L().With("component", "deployer")
+	name := phaseName(phaseID)
+
+	if skip, reason := r.shouldSkip(phaseID); skip {
+		log.Infow(fmt.Sprintf("Phase %s: Skipping %s (%s)", phaseID, strings.ToLower(name), reason))
+		r.journal.skipPhase(phaseID, name)
+		r.skipped++
+		return nil
+	}
+
+	r.journal.startPhase(phaseID, name)
+	err := fn(withPhaseJournal(ctx, r.journal, phaseID))
+	r.journal.finishPhase(phaseID, err)
+	var partial *partialError
+	if errors.As(err, &partial) {
+		log.Warnw(fmt.Sprintf("⚠ Phase %s: %s incomplete, continuing (rerun with -resume)", phaseID, strings.ToLower(name)), "error", partial.err)
+		r.partial++
+		return nil
+	}
+	if err == nil {
+		r.completed++
+	}
+	return err
+}
+
+// shouldSkip reports whether a phase should be skipped and why.
+// Phase 1 always runs because every later phase needs SSH connections.
+func (r *phaseRunner) shouldSkip(phaseID string) (bool, string) {
+	if phaseID == "1" {
+		return false, ""
+	}
+	if r.opts.OnlyPhase != "" && phaseID != r.opts.OnlyPhase {
+		return true, fmt.Sprintf("-only-phase=%s", r.opts.OnlyPhase)
+	}
+	if r.opts.FromPhase != "" && phaseIndex(phaseID) < phaseIndex(r.opts.FromPhase) {
+		return true, fmt.Sprintf("-from-phase=%s", r.opts.FromPhase)
+	}
+	if r.opts.Resume && r.opts.OnlyPhase == "" && r.journal.completed(phaseID) {
+		return true, "completed in previous run"
+	}
+	return false, ""
+}
+
+// phaseIndex returns the execution order of a phase ID, or -1 if unknown.
+func phaseIndex(id string) int {
+	for i, p := range deployPhases {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// phaseName returns the display name of a phase ID.
+func phaseName(id string) string {
+	if i := phaseIndex(id); i >= 0 {
+		return deployPhases[i].Name
+	}
+	return id
+}
+
+// phaseIDs returns all phase IDs in execution order.
+func phaseIDs() []string {
+	ids := make([]string, len(deployPhases))
+	for i, p := range deployPhases {
+		ids[i] = p.ID
+	}
+	return ids
+}
@@ -0,0 +1,284 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+)
+
+// cephHealthStates are the Ceph health states exported as dscotctl_ceph_health.
+var cephHealthStates = []string{"HEALTH_OK", "HEALTH_WARN", "HEALTH_ERR"}
+
+// MonitorOptions configures Monitor.
+type MonitorOptions struct {
+	ListenAddr  string        // Address of the metrics endpoint (default defaults.MonitorListenAddr)
+	Interval    time.Duration // Time between two collections (default defaults.MonitorIntervalSeconds)
+	Concurrency int           // Nodes checked in parallel (default defaults.StatusConcurrency)
+}
+
+// Monitor collects the cluster status at a fixed interval and serves the
+// latest result on /metrics in the Prometheus text format. One SSH pool is
+// kept for the whole run; connections to unreachable nodes are reset so they
+// are retried on the next collection. Blocks until ctx is cancelled.
+func Monitor(ctx context.Context, cfg *config.Config, opts MonitorOptions) error {
+	log := logging.L().With("component", "monitor")
+
+	if opts.ListenAddr == "" {
+		opts.ListenAddr = defaults.MonitorListenAddr
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.MonitorIntervalSeconds * time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.StatusConcurrency
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	var (
+		mu      sync.RWMutex
+		metrics []byte
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		body := metrics
+		mu.RUnlock()
+		if body == nil {
+			http.Error(w, "first collection still running", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(body)
+	})
+	server := &http.Server{
+		Addr:              opts.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+	log.Infow("✅ serving metrics", "listen", opts.ListenAddr, "interval", opts.Interval, "concurrency", opts.Concurrency)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		report, err := collectStatus(ctx, cfg, sshPool, opts.Concurrency)
+		if err != nil {
+			log.Warnw("failed to collect cluster status", "error", err)
+		} else {
+			for _, n := range report.Nodes {
+				if !n.Reachable {
+					sshPool.Reset(n.Host)
+				}
+			}
+			body := renderMetrics(report, time.Since(start))
+			mu.Lock()
+			metrics = body
+			mu.Unlock()
+			log.Infow("✓ cluster status collected", "healthy", report.Healthy, "problems", len(report.Problems), "duration", time.Since(start).Round(time.Millisecond))
+		}
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+			return nil
+		case err, ok := <-serveErr:
+			if ok {
+				return fmt.Errorf("failed to serve metrics on %s: %w", opts.ListenAddr, err)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// metricsWriter writes metric families in the Prometheus text format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the HELP and TYPE lines of a metric.
+func (m *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(&m.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one sample. labels are name/value pairs.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&m.buf, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.buf.WriteByte('\n')
+}
+
+// escapeLabelValue escapes backslashes, quotes and newlines in a label value.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// boolValue converts a bool to a gauge value.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// renderMetrics renders a status report as Prometheus metrics.
+func renderMetrics(r *StatusReport, duration time.Duration) []byte {
+	m := &metricsWriter{}
+
+	m.family("dscotctl_cluster_healthy", "gauge", "Whether the cluster has no problems (1) or is degraded (0).")
+	m.sample("dscotctl_cluster_healthy", boolValue(r.Healthy), "cluster", r.ClusterName)
+	m.family("dscotctl_cluster_problems", "gauge", "Number of problems found by the last collection.")
+	m.sample("dscotctl_cluster_problems", float64(len(r.Problems)), "cluster", r.ClusterName)
+	m.family("dscotctl_collect_duration_seconds", "gauge", "Duration of the last collection.")
+	m.sample("dscotctl_collect_duration_seconds", duration.Seconds())
+	m.family("dscotctl_collect_timestamp_seconds", "gauge", "Unix time of the last collection.")
+	m.sample("dscotctl_collect_timestamp_seconds", float64(r.GeneratedAt.Unix()))
+
+	m.family("dscotctl_node_reachable", "gauge", "Whether the node answers over SSH.")
+	for _, n := range r.Nodes {
+		m.sample("dscotctl_node_reachable", boolValue(n.Reachable), "node", n.Host, "role", n.Role)
+	}
+	m.family("dscotctl_swarm_node_ready", "gauge", "Whether the Swarm reports the node as Ready.")
+	for _, n := range r.Nodes {
+		m.sample("dscotctl_swarm_node_ready", boolValue(n.SwarmStatus == "Ready"), "node", n.Host, "role", n.Role)
+	}
+	m.family("dscotctl_swarm_node_active", "gauge", "Whether the node's Swarm availability is Active.")
+	for _, n := range r.Nodes {
+		m.sample("dscotctl_swarm_node_active", boolValue(n.SwarmAvailability == "Active"), "node", n.Host, "role", n.Role)
+	}
+	m.family("dscotctl_swarm_manager_reachable", "gauge", "Whether the manager is reachable in the Raft quorum.")
+	for _, n := range r.Nodes {
+		if n.Role == "manager" || n.Role == "both" {
+			m.sample("dscotctl_swarm_manager_reachable", boolValue(n.ManagerStatus == "Leader" || n.ManagerStatus == "Reachable"), "node", n.Host)
+		}
+	}
+
+	if r.Storage != nil {
+		m.family("dscotctl_ceph_health", "gauge", "Ceph health state, 1 for the current state.")
+		for _, state := range cephHealthStates {
+			m.sample("dscotctl_ceph_health", boolValue(r.Storage.Health == state), "state", state)
+		}
+		m.family("dscotctl_ceph_osds", "gauge", "Number of OSDs.")
+		m.sample("dscotctl_ceph_osds", float64(r.Storage.OSDs))
+		m.family("dscotctl_ceph_osds_up", "gauge", "Number of OSDs up.")
+		m.sample("dscotctl_ceph_osds_up", float64(r.Storage.OSDsUp))
+		m.family("dscotctl_ceph_osds_in", "gauge", "Number of OSDs in.")
+		m.sample("dscotctl_ceph_osds_in", float64(r.Storage.OSDsIn))
+		m.family("dscotctl_ceph_used_bytes", "gauge", "Raw capacity used.")
+		m.sample("dscotctl_ceph_used_bytes", float64(r.Storage.UsedBytes))
+		m.family("dscotctl_ceph_total_bytes", "gauge", "Raw capacity.")
+		m.sample("dscotctl_ceph_total_bytes", float64(r.Storage.TotalBytes))
+
+		m.family("dscotctl_ceph_pool_stored_bytes", "gauge", "Data stored in the pool, before replication.")
+		for _, p := range r.Storage.Pools {
+			m.sample("dscotctl_ceph_pool_stored_bytes", float64(p.StoredBytes), "pool", p.Name)
+		}
+		m.family("dscotctl_ceph_pool_used_bytes", "gauge", "Raw space used by the pool, including replicas.")
+		for _, p := range r.Storage.Pools {
+			m.sample("dscotctl_ceph_pool_used_bytes", float64(p.UsedBytes), "pool", p.Name)
+		}
+		m.family("dscotctl_ceph_pool_max_avail_bytes", "gauge", "Space still available to the pool.")
+		for _, p := range r.Storage.Pools {
+			m.sample("dscotctl_ceph_pool_max_avail_bytes", float64(p.MaxAvailBytes), "pool", p.Name)
+		}
+		m.family("dscotctl_ceph_pool_used_ratio", "gauge", "Fraction of the pool's capacity used.")
+		for _, p := range r.Storage.Pools {
+			m.sample("dscotctl_ceph_pool_used_ratio", p.PercentUsed, "pool", p.Name)
+		}
+
+		m.family("dscotctl_cephfs_mounted", "gauge", "Whether CephFS is mounted on the node.")
+		for _, n := range r.Nodes {
+			if n.StorageEnabled {
+				m.sample("dscotctl_cephfs_mounted", boolValue(n.CephFSMounted), "node", n.Host)
+			}
+		}
+	}
+
+	type overlayPeers struct {
+		node             string
+		ok               bool
+		connected, total int
+	}
+	var overlay []overlayPeers
+	for _, n := range r.Nodes {
+		p := overlayPeers{node: n.Host, ok: n.OverlayConnected}
+		if _, err := fmt.Sscanf(n.OverlayPeers, "%d/%d", &p.connected, &p.total); err == nil {
+			overlay = append(overlay, p)
+		}
+	}
+	m.family("dscotctl_overlay_connected", "gauge", "Whether the node is connected to all its overlay peers.")
+	for _, p := range overlay {
+		m.sample("dscotctl_overlay_connected", boolValue(p.ok), "node", p.node)
+	}
+	m.family("dscotctl_overlay_peers", "gauge", "Overlay peers of the node, by state.")
+	for _, p := range overlay {
+		m.sample("dscotctl_overlay_peers", float64(p.connected), "node", p.node, "state", "connected")
+		m.sample("dscotctl_overlay_peers", float64(p.total-p.connected), "node", p.node, "state", "disconnected")
+	}
+
+	if r.VIP != "" {
+		m.family("dscotctl_keepalived_vip_owner", "gauge", "Whether the node holds the Keepalived VIP.")
+		for _, n := range r.Nodes {
+			m.sample("dscotctl_keepalived_vip_owner", boolValue(n.HoldsVIP), "node", n.Host, "vip", r.VIP)
+		}
+	}
+
+	var services []ServiceHealth
+	stackOf := make(map[string]string)
+	for _, stack := range r.Stacks {
+		for _, svc := range stack.Services {
+			services = append(services, svc)
+			stackOf[svc.Name] = stack.Name
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	m.family("dscotctl_service_replicas_desired", "gauge", "Desired replicas of the Swarm service.")
+	for _, svc := range services {
+		m.sample("dscotctl_service_replicas_desired", float64(svc.Desired), "stack", stackOf[svc.Name], "service", svc.Name, "mode", svc.Mode)
+	}
+	m.family("dscotctl_service_replicas_running", "gauge", "Running replicas of the Swarm service.")
+	for _, svc := range services {
+		m.sample("dscotctl_service_replicas_running", float64(svc.Running), "stack", stackOf[svc.Name], "service", svc.Name, "mode", svc.Mode)
+	}
+
+	return m.buf.Bytes()
+}
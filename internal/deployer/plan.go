@@ -0,0 +1,499 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/services"
+	"dscotctl/internal/ssh"
+)
+
+// clusterTarget is the node name used for plan changes that are not node-specific.
+const clusterTarget = "(cluster)"
+
+// NodeState is the read-only snapshot of a node collected for a plan.
+type NodeState struct {
+	Host                string            `json:"host"`
+	Role                string            `json:"role"`
+	Reachable           bool              `json:"reachable"`
+	Error               string            `json:"error,omitempty"`
+	Hostname            string            `json:"hostname"`
+	DockerVersion       string            `json:"dockerVersion,omitempty"`
+	SwarmState          string            `json:"swarmState,omitempty"`
+	SwarmManager        bool              `json:"swarmManager"`
+	SwarmClusterID      string            `json:"swarmClusterId,omitempty"`
+	SwarmLabels         map[string]string `json:"swarmLabels,omitempty"`
+	MicroCephChannel    string            `json:"microcephChannel,omitempty"`
+	MicroCephMember     bool              `json:"microcephMember"`
+	OverlayStatus       string            `json:"overlayStatus,omitempty"`
+	KeepalivedActive    bool              `json:"keepalivedActive"`
+	KeepalivedVIP       string            `json:"keepalivedVip,omitempty"`
+	IptablesInputRules  []string          `json:"iptablesInputRules,omitempty"`
+	IptablesInputPolicy string            `json:"iptablesInputPolicy,omitempty"`
+}
+
+// PlannedChange is a single change that Deploy would make.
+type PlannedChange struct {
+	Phase  string `json:"phase"`
+	Node   string `json:"node"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// DeployPlan is the result of comparing the configuration to live node state.
+type DeployPlan struct {
+	ClusterName string          `json:"clusterName"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Stacks      []string        `json:"stacks"`
+	Nodes       []NodeState     `json:"nodes"`
+	Changes     []PlannedChange `json:"changes"`
+}
+
+// BuildPlan connects to all enabled nodes read-only, collects their current
+// state and computes the per-node, per-phase changes Deploy would make.
+func BuildPlan(ctx context.Context, cfg *config.Config) (*DeployPlan, error) {
+	log := logging.L().With("component", "plan")
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	enabledNodes := getEnabledNodes(cfg)
+	log.Infow("collecting live node state", "nodes", len(enabledNodes))
+
+	states := make([]NodeState, len(enabledNodes))
+	var wg sync.WaitGroup
+	for i, node := range enabledNodes {
+		wg.Add(1)
+		go func(i int, node config.NodeConfig) {
+			defer wg.Done()
+			states[i] = collectNodeState(ctx, sshPool, cfg, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	plan := &DeployPlan{
+		ClusterName: cfg.GlobalSettings.ClusterName,
+		GeneratedAt: time.Now(),
+		Nodes:       states,
+	}
+
+	// Stacks and node labels are read from the first reachable manager
+	managers, _ := categorizeNodes(cfg)
+	if manager, err := findReachableManager(ctx, sshPool, managers); err == nil {
+		plan.Stacks = collectStacks(ctx, sshPool, manager)
+		collectSwarmLabels(ctx, sshPool, manager, plan.Nodes)
+	} else {
+		log.Warnw("no active Swarm manager found, stacks and labels not inspected", "error", err)
+	}
+
+	plan.Changes = diffPlan(cfg, plan)
+	return plan, nil
+}
+
+// collectNodeState runs read-only commands on a node to snapshot its state.
+func collectNodeState(ctx context.Context, sshPool *ssh.Pool, cfg *config.Config, node config.NodeConfig) NodeState {
+	state := NodeState{Host: node.SSHFQDNorIP, Role: node.Role}
+
+	run := func(cmd string) string {
+		stdout, _, _ := sshPool.Run(ctx, node.SSHFQDNorIP, cmd)
+		return strings.TrimSpace(stdout)
+	}
+
+	hostname, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname")
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	state.Reachable = true
+	state.Hostname = strings.TrimSpace(hostname)
+
+	state.DockerVersion = run("docker version --format '{{.Server.Version}}' 2>/dev/null")
+	if swarm := strings.Fields(run("docker info --format '{{.Swarm.LocalNodeState}} {{.Swarm.ControlAvailable}} {{.Swarm.Cluster.ID}}' 2>/dev/null")); len(swarm) >= 2 {
+		state.SwarmState = swarm[0]
+		state.SwarmManager = swarm[1] == "true"
+		if len(swarm) >= 3 {
+			state.SwarmClusterID = swarm[2]
+		}
+	}
+
+	if snap := strings.Fields(run("snap list microceph 2>/dev/null | tail -n +2")); len(snap) >= 4 {
+		state.MicroCephChannel = snap[3]
+		members := run("microceph cluster list 2>/dev/null")
+		state.MicroCephMember = state.Hostname != "" && microCephMember(members, state.Hostname)
+	}
+
+	switch strings.ToLower(cfg.GlobalSettings.OverlayProvider) {
+	case "netbird":
+		state.OverlayStatus = run("netbird status 2>/dev/null | grep -i '^Management:' | cut -d: -f2-")
+	case "tailscale":
+		state.OverlayStatus = run("tailscale status --json 2>/dev/null | grep -m1 '\"BackendState\"' | cut -d'\"' -f4")
+	case "wireguard":
+		state.OverlayStatus = run("wg show interfaces 2>/dev/null")
+	}
+
+	state.KeepalivedActive = run("systemctl is-active keepalived 2>/dev/null") == "active"
+	state.KeepalivedVIP = run("awk '/virtual_ipaddress/ {getline; print $1; exit}' /etc/keepalived/keepalived.conf 2>/dev/null")
+
+	for _, line := range strings.Split(run("iptables -S INPUT 2>/dev/null"), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-P INPUT ") {
+			state.IptablesInputPolicy = strings.TrimPrefix(line, "-P INPUT ")
+		} else if line != "" {
+			state.IptablesInputRules = append(state.IptablesInputRules, line)
+		}
+	}
+
+	return state
+}
+
+// microCephMember reports whether hostname is in the NAME column of the
+// `microceph cluster list` table.
+func microCephMember(members string, hostname string) bool {
+	for _, line := range strings.Split(members, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// collectStacks lists deployed stacks on a manager.
+func collectStacks(ctx context.Context, sshPool *ssh.Pool, manager string) []string {
+	stdout, _, err := sshPool.Run(ctx, manager, "docker stack ls --format '{{.Name}}'")
+	if err != nil {
+		return nil
+	}
+	var stacks []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			stacks = append(stacks, line)
+		}
+	}
+	return stacks
+}
+
+// collectSwarmLabels fills SwarmLabels for each node from docker node inspect.
+func collectSwarmLabels(ctx context.Context, sshPool *ssh.Pool, manager string, states []NodeState) {
+	cmd := "docker node ls -q | xargs -r docker node inspect --format '{{.Description.Hostname}} {{json .Spec.Labels}}'"
+	stdout, _, err := sshPool.Run(ctx, manager, cmd)
+	if err != nil {
+		return
+	}
+
+	labelsByHostname := make(map[string]map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		hostname, labelsJSON, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		var labels map[string]string
+		if json.Unmarshal([]byte(labelsJSON), &labels) == nil {
+			labelsByHostname[hostname] = labels
+		}
+	}
+
+	for i := range states {
+		states[i].SwarmLabels = labelsByHostname[states[i].Hostname]
+	}
+}
+
+// diffPlan compares the configuration with collected state and returns the
+// changes each Deploy phase would make, in phase order.
+func diffPlan(cfg *config.Config, plan *DeployPlan) []PlannedChange {
+	var changes []PlannedChange
+	add := func(phase, node, action, detail string) {
+		changes = append(changes, PlannedChange{Phase: phase, Node: node, Action: action, Detail: detail})
+	}
+
+	ds := cfg.GetDistributedStorage()
+	overlayProvider := strings.ToLower(cfg.GlobalSettings.OverlayProvider)
+	enabledNodes := getEnabledNodes(cfg)
+	managers, _ := categorizeNodes(cfg)
+
+	// Find the Swarm the managers are in, if any
+	var clusterID string
+	for _, st := range plan.Nodes {
+		if st.SwarmManager && st.SwarmClusterID != "" {
+			clusterID = st.SwarmClusterID
+			break
+		}
+	}
+	if clusterID == "" && len(managers) > 0 {
+		add("7", managers[0], "init", "initialize new Docker Swarm")
+	}
+
+	for i, node := range enabledNodes {
+		st := plan.Nodes[i]
+		host := node.SSHFQDNorIP
+
+		if !st.Reachable {
+			add("1", host, "error", fmt.Sprintf("node not reachable: %s", st.Error))
+			continue
+		}
+
+		// Phase 2: hostname
+		if node.NewHostname != "" && st.Hostname != node.NewHostname {
+			add("2", host, "change", fmt.Sprintf("hostname %s → %s", st.Hostname, node.NewHostname))
+		}
+
+		// Phase 2.5: root password (cannot be inspected)
+		if cfg.GlobalSettings.SetRootPassword != "" {
+			add("2.5", host, "change", "set root password")
+		}
+
+		// Phase 3 / 10: scripts
+		if node.ScriptsEnabled {
+			for _, sp := range []struct {
+				phase   string
+				scripts []config.ScriptConfig
+			}{{"3", cfg.GlobalSettings.PreScripts}, {"10", cfg.GlobalSettings.PostScripts}} {
+				for _, script := range sp.scripts {
+					if !script.Enabled {
+						continue
+					}
+					if matches, err := config.EvaluateScriptConditions(node, script.Conditions); err == nil && matches {
+						add(sp.phase, host, "run", fmt.Sprintf("script %s (%s)", script.Name, script.Source))
+					}
+				}
+			}
+		}
+
+		// Phase 4: Docker
+		if st.DockerVersion == "" {
+			add("4", host, "install", "Docker")
+		}
+
+		// Phase 5: overlay
+		if overlayProvider != "" && overlayProvider != "none" {
+			if !overlayConnected(overlayProvider, st.OverlayStatus) {
+				add("5", host, "install", fmt.Sprintf("%s overlay (current status: %q)", overlayProvider, st.OverlayStatus))
+			}
+		}
+
+		// Phase 6: storage
+		if ds.Enabled && node.StorageEnabled {
+			switch {
+			case ds.ForceRecreation && st.MicroCephChannel != "":
+				add("6", host, "destroy", "tear down and recreate MicroCeph (forceRecreation=true)")
+			case st.MicroCephChannel == "":
+				add("6", host, "install", fmt.Sprintf("MicroCeph (%s) and join storage cluster", ds.Providers.MicroCeph.SnapChannel))
+			case !st.MicroCephMember:
+				add("6", host, "join", "join MicroCeph cluster")
+			default:
+				if moves := storageRoleMoves(st.SwarmLabels["node.role"], node.Role); moves != "" {
+					add("6", host, "move", moves)
+				}
+			}
+		}
+
+		// Phase 7: swarm membership
+		wantManager := node.Role == "manager" || node.Role == "both"
+		switch {
+		case st.SwarmState != "active":
+			if clusterID != "" || len(managers) == 0 || host != managers[0] {
+				add("7", host, "join", fmt.Sprintf("join Swarm as %s", swarmRoleName(wantManager)))
+			}
+		case clusterID != "" && st.SwarmClusterID != clusterID:
+			add("7", host, "rejoin", fmt.Sprintf("leave stale Swarm %s and join as %s", st.SwarmClusterID, swarmRoleName(wantManager)))
+		case wantManager && !st.SwarmManager:
+			add("7", host, "promote", "promote Swarm worker to manager")
+		case !wantManager && st.SwarmManager:
+			add("7", host, "demote", "demote Swarm manager to worker")
+		}
+
+		// Phase 8: labels (geolocation labels are detected at deploy time and not compared)
+		wantLabels := map[string]string{"node.role": node.Role}
+		if cfg.GlobalSettings.ClusterName != "" {
+			wantLabels["cluster.name"] = cfg.GlobalSettings.ClusterName
+		}
+		for k, v := range node.Labels {
+			wantLabels[k] = v
+		}
+		if diff := diffLabels(st.SwarmLabels, wantLabels); diff != "" {
+			add("8", host, "change", diff)
+		}
+
+		// Phase 8b: keepalived
+		if cfg.IsKeepalivedEnabled() && node.Keepalived.Enabled {
+			if !st.KeepalivedActive {
+				add("8b", host, "install", "Keepalived")
+			} else {
+				add("8b", host, "change", fmt.Sprintf("rewrite keepalived.conf (current VIP %s)", st.KeepalivedVIP))
+			}
+		}
+
+		// Phase 8c: firewall
+		if node.Firewall.HasFirewallEnabled() {
+			add("8c", host, "change", fmt.Sprintf("apply firewall configuration (INPUT policy %s, %d existing rules)", st.IptablesInputPolicy, len(st.IptablesInputRules)))
+		}
+
+		// Phase 11: reboot
+		if node.RebootOnCompletion {
+			add("11", host, "reboot", "reboot on completion")
+		}
+	}
+
+	// Phase 9: services
+	if svcList, err := services.DiscoverServices(cfg.GlobalSettings.ServiceDefinitionDirectory); err == nil {
+		deployed := make(map[string]bool)
+		for _, stack := range plan.Stacks {
+			deployed[stack] = true
+		}
+		for _, svc := range svcList {
+			if !svc.Enabled {
+				if deployed[svc.Name] {
+					add("9", clusterTarget, "keep", fmt.Sprintf("stack %s is disabled but stays deployed", svc.Name))
+				}
+				continue
+			}
+			if deployed[svc.Name] {
+				add("9", clusterTarget, "update", fmt.Sprintf("stack %s", svc.Name))
+			} else {
+				add("9", clusterTarget, "deploy", fmt.Sprintf("stack %s", svc.Name))
+			}
+		}
+	}
+
+	// Phase 12: SSH key removal
+	if cfg.GlobalSettings.RemoveSSHPublicKeyOnCompletion {
+		add("12", clusterTarget, "change", "remove automatic SSH public key from nodes")
+	}
+
+	sort.SliceStable(changes, func(a, b int) bool {
+		return phaseIndex(changes[a].Phase) < phaseIndex(changes[b].Phase)
+	})
+	return changes
+}
+
+// overlayConnected interprets the overlay status string for a provider.
+func overlayConnected(provider, status string) bool {
+	switch provider {
+	case "netbird":
+		return strings.Contains(strings.ToLower(status), "connected") && !strings.Contains(strings.ToLower(status), "disconnected")
+	case "tailscale":
+		return status == "Running"
+	case "wireguard":
+		return status != ""
+	}
+	return true
+}
+
+// storageRoleMoves describes the MON and OSD moves reconcileStorageRoles
+// makes for a node whose role changed from prev (the "node.role" label of the
+// last deploy), or returns "" when there is nothing to move.
+func storageRoleMoves(prev, role string) string {
+	if prev == "" || prev == role {
+		return ""
+	}
+	wasMON, wantMON := prev == "manager" || prev == "both", role == "manager" || role == "both"
+	wasOSD, wantOSD := prev == "worker" || prev == "both", role == "worker" || role == "both"
+
+	var moves []string
+	if !wasMON && wantMON {
+		moves = append(moves, "add MON")
+	}
+	if wasMON && !wantMON {
+		moves = append(moves, "remove MON")
+	}
+	if wasOSD && !wantOSD {
+		moves = append(moves, "drain and remove OSDs")
+	}
+	if len(moves) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s (role %s → %s)", strings.Join(moves, ", "), prev, role)
+}
+
+// diffLabels describes labels that would be added or changed.
+func diffLabels(current, want map[string]string) string {
+	var parts []string
+	for k, v := range want {
+		if cur, ok := current[k]; !ok {
+			parts = append(parts, fmt.Sprintf("+%s=%s", k, v))
+		} else if cur != v {
+			parts = append(parts, fmt.Sprintf("~%s=%s→%s", k, cur, v))
+		}
+	}
+	sort.Strings(parts)
+	if len(parts) == 0 {
+		return ""
+	}
+	return "labels " + strings.Join(parts, " ")
+}
+
+// swarmRoleName returns "manager" or "worker".
+func swarmRoleName(manager bool) string {
+	if manager {
+		return "manager"
+	}
+	return "worker"
+}
+
+// WriteText renders the plan as a human-readable table grouped by phase.
+func (p *DeployPlan) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Plan for cluster %s (%s)\n\n", p.ClusterName, p.GeneratedAt.Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tREACHABLE\tHOSTNAME\tDOCKER\tSWARM\tMICROCEPH\tOVERLAY\tKEEPALIVED")
+	for _, n := range p.Nodes {
+		swarm := n.SwarmState
+		if n.SwarmState == "active" {
+			swarm += "/" + swarmRoleName(n.SwarmManager)
+		}
+		keepalived := "-"
+		if n.KeepalivedActive {
+			keepalived = n.KeepalivedVIP
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			n.Host, n.Reachable, dash(n.Hostname), dash(n.DockerVersion), dash(swarm), dash(n.MicroCephChannel), dash(n.OverlayStatus), keepalived)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nDeployed stacks: %s\n\n", dash(strings.Join(p.Stacks, ", ")))
+
+	if len(p.Changes) == 0 {
+		fmt.Fprintln(w, "No changes. The cluster matches the configuration.")
+		return nil
+	}
+
+	lastPhase := ""
+	for _, c := range p.Changes {
+		if c.Phase != lastPhase {
+			fmt.Fprintf(w, "Phase %s: %s\n", c.Phase, phaseName(c.Phase))
+			lastPhase = c.Phase
+		}
+		fmt.Fprintf(w, "  %-8s %-30s %s\n", c.Action, c.Node, c.Detail)
+	}
+	fmt.Fprintf(w, "\n%d change(s) planned.\n", len(p.Changes))
+	return nil
+}
+
+// WriteJSON renders the plan as indented JSON.
+func (p *DeployPlan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// dash returns "-" for empty strings in table output.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
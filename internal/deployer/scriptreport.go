@@ -0,0 +1,102 @@
+package deployer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dscotctl/internal/logging"
+)
+
+// maxScriptOutput is the number of bytes of stdout/stderr kept per script run.
+// Longer output keeps its tail, which usually holds the error.
+const maxScriptOutput = 64 * 1024
+
+// ScriptResult records one script run on one node.
+type ScriptResult struct {
+	Node       string    `json:"node"`
+	Script     string    `json:"script"`
+	Source     string    `json:"source"`
+	Phase      string    `json:"phase"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	ExitCode   int       `json:"exitCode"`
+	TimedOut   bool      `json:"timedOut,omitempty"`
+	Stdout     string    `json:"stdout,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ScriptReport collects the script results of a Deploy run. It is written
+// next to the configuration file after every result.
+type ScriptReport struct {
+	ConfigPath string          `json:"configPath"`
+	StartedAt  time.Time       `json:"startedAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+	Results    []*ScriptResult `json:"results"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// ScriptReportPath returns the script report path for a configuration file,
+// e.g. /etc/dscotctl/cluster.json → /etc/dscotctl/cluster.scripts.json.
+func ScriptReportPath(configPath string) string {
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + ".scripts.json"
+}
+
+// newScriptReport starts an empty report for configPath.
+func newScriptReport(configPath string) *ScriptReport {
+	return &ScriptReport{
+		ConfigPath: configPath,
+		StartedAt:  time.Now(),
+		Results:    []*ScriptResult{},
+		path:       ScriptReportPath(configPath),
+	}
+}
+
+// add appends a result and saves the report. A nil report ignores results.
+func (r *ScriptReport) add(result *ScriptResult) {
+	if r == nil || result == nil {
+		return
+	}
+	r.mu.Lock()
+	r.Results = append(r.Results, result)
+	r.mu.Unlock()
+	r.save()
+}
+
+// save writes the report to disk. Failures are logged but never abort a deployment.
+func (r *ScriptReport) save() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		logging.L().Warnw("failed to encode script report", "error", err)
+		return
+	}
+
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		logging.L().Warnw("failed to write script report", "path", r.path, "error", err)
+		return
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		logging.L().Warnw("failed to write script report", "path", r.path, "error", err)
+	}
+}
+
+// truncateOutput keeps the last maxScriptOutput bytes of s.
+func truncateOutput(s string) (string, bool) {
+	if len(s) <= maxScriptOutput {
+		return s, false
+	}
+	return "…" + s[len(s)-maxScriptOutput:], true
+}
@@ -0,0 +1,239 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+	"dscotctl/internal/storage"
+)
+
+// restoreConstraint keeps global services off every node while a snapshot is
+// restored; global services cannot be scaled to zero.
+const restoreConstraint = "node.labels.dscotctl.restore==true"
+
+// stackScaleTimeout bounds bringing a stack back to its previous scale, which
+// also runs after the restore was interrupted.
+const stackScaleTimeout = 5 * time.Minute
+
+// stackServiceState records how to bring a stack service back after a restore.
+type stackServiceState struct {
+	Name     string
+	Global   bool
+	Replicas int
+}
+
+// ListSnapshots returns the storage snapshots that contain a service's data directory.
+func ListSnapshots(ctx context.Context, cfg *config.Config, service string) ([]string, error) {
+	if err := validateServiceName(service); err != nil {
+		return nil, err
+	}
+
+	provider, sshPool, client, err := openSnapshotClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer sshPool.Close()
+
+	return provider.ListSnapshots(ctx, sshPool, client, serviceDataPath(service))
+}
+
+// RestoreSnapshot restores a service's data directory from a named snapshot.
+// The service's stack is scaled to zero for the restore and brought back to
+// its previous scale afterwards, also when the restore fails.
+func RestoreSnapshot(ctx context.Context, cfg *config.Config, service, snapshot string) (err error) {
+	log := logging.L().With("component", "snapshots", "service", service, "snapshot", snapshot)
+
+	if err := validateServiceName(service); err != nil {
+		return err
+	}
+
+	provider, sshPool, client, err := openSnapshotClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer sshPool.Close()
+
+	managers, _ := categorizeNodes(cfg)
+	manager, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		return err
+	}
+
+	path := serviceDataPath(service)
+	snapshots, err := provider.ListSnapshots(ctx, sshPool, client, path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, s := range snapshots {
+		found = found || s == snapshot
+	}
+	if !found {
+		return fmt.Errorf("snapshot %q not found for service %s (see 'storage snapshots list')", snapshot, service)
+	}
+
+	states, err := stackServices(ctx, sshPool, manager, service)
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		log.Warnw("⚠ stack is not deployed; restoring without stopping it")
+	}
+
+	log.Infow("→ scaling stack to zero", "services", len(states))
+	defer func() {
+		log.Infow("→ restoring stack scale", "services", len(states))
+		scaleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stackScaleTimeout)
+		defer cancel()
+		if scaleErr := scaleStackUp(scaleCtx, sshPool, manager, states); scaleErr != nil {
+			log.Errorw("failed to restore stack scale", "error", scaleErr)
+			if err == nil {
+				err = scaleErr
+			}
+		}
+	}()
+	if err := scaleStackDown(ctx, sshPool, manager, states); err != nil {
+		return err
+	}
+	if len(states) > 0 {
+		if err := waitForStackStopped(ctx, sshPool, manager, service); err != nil {
+			return err
+		}
+	}
+
+	if err := provider.RestoreSnapshot(ctx, sshPool, client, path, snapshot); err != nil {
+		return err
+	}
+	log.Infow("✅ snapshot restored")
+	return nil
+}
+
+// validateServiceName rejects names that would escape the service data root.
+func validateServiceName(service string) error {
+	if service == "" || strings.Contains(service, "/") || service == "." || service == ".." {
+		return fmt.Errorf("invalid service name %q", service)
+	}
+	return nil
+}
+
+// serviceDataPath returns a service's data directory relative to the storage root.
+func serviceDataPath(service string) string {
+	return "/" + defaults.ServiceDataSubdir + "/" + service
+}
+
+// openSnapshotClient opens an SSH pool and returns the storage provider and
+// the first storage worker that has the filesystem mounted.
+func openSnapshotClient(ctx context.Context, cfg *config.Config) (storage.Provider, *ssh.Pool, string, error) {
+	if !cfg.IsStorageEnabled() {
+		return nil, nil, "", fmt.Errorf("distributed storage is not enabled in configuration")
+	}
+
+	provider, err := storage.NewProvider(cfg)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create storage provider: %w", err)
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+
+	_, workers, _ := getStorageNodesByRole(cfg)
+	checkCmd := fmt.Sprintf("mountpoint -q %s", ssh.ShellQuote(provider.GetMountPath()))
+	for _, worker := range workers {
+		if _, _, err := sshPool.Run(ctx, worker, checkCmd); err == nil {
+			return provider, sshPool, worker, nil
+		}
+	}
+
+	sshPool.Close()
+	return nil, nil, "", fmt.Errorf("no storage node has %s mounted", provider.GetMountPath())
+}
+
+// stackServices returns the services of a stack with their current scale.
+func stackServices(ctx context.Context, sshPool *ssh.Pool, manager, stack string) ([]stackServiceState, error) {
+	listCmd := fmt.Sprintf("docker service ls --filter %s --format '{{.Name}}'", ssh.ShellQuote("label=com.docker.stack.namespace="+stack))
+	stdout, stderr, err := sshPool.Run(ctx, manager, listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack services: %w (stderr: %s)", err, stderr)
+	}
+
+	var states []stackServiceState
+	for _, name := range strings.Fields(stdout) {
+		inspectCmd := fmt.Sprintf("docker service inspect %s --format '{{if .Spec.Mode.Global}}global{{else}}{{.Spec.Mode.Replicated.Replicas}}{{end}}'", ssh.ShellQuote(name))
+		out, stderr, err := sshPool.Run(ctx, manager, inspectCmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect service %s: %w (stderr: %s)", name, err, stderr)
+		}
+
+		state := stackServiceState{Name: name}
+		if mode := strings.TrimSpace(out); mode == "global" {
+			state.Global = true
+		} else if state.Replicas, err = strconv.Atoi(mode); err != nil {
+			return nil, fmt.Errorf("unexpected replica count %q for service %s", mode, name)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// scaleStackDown stops every task of the stack's services.
+func scaleStackDown(ctx context.Context, sshPool *ssh.Pool, manager string, states []stackServiceState) error {
+	for _, state := range states {
+		cmd := fmt.Sprintf("docker service scale --detach %s", ssh.ShellQuote(state.Name+"=0"))
+		if state.Global {
+			cmd = fmt.Sprintf("docker service update --detach --constraint-add '%s' %s", restoreConstraint, ssh.ShellQuote(state.Name))
+		}
+		if _, stderr, err := sshPool.Run(ctx, manager, cmd); err != nil {
+			return fmt.Errorf("failed to stop service %s: %w (stderr: %s)", state.Name, err, stderr)
+		}
+	}
+	return nil
+}
+
+// scaleStackUp returns the stack's services to their recorded scale.
+func scaleStackUp(ctx context.Context, sshPool *ssh.Pool, manager string, states []stackServiceState) error {
+	var failed []string
+	for _, state := range states {
+		cmd := fmt.Sprintf("docker service scale --detach %s", ssh.ShellQuote(fmt.Sprintf("%s=%d", state.Name, state.Replicas)))
+		if state.Global {
+			cmd = fmt.Sprintf("docker service update --detach --constraint-rm '%s' %s", restoreConstraint, ssh.ShellQuote(state.Name))
+		}
+		if _, _, err := sshPool.Run(ctx, manager, cmd); err != nil {
+			failed = append(failed, state.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restart services: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// waitForStackStopped waits until no task of the stack is running.
+func waitForStackStopped(ctx context.Context, sshPool *ssh.Pool, manager, stack string) error {
+	checkCmd := fmt.Sprintf("docker stack ps %s --filter desired-state=running -q 2>/dev/null", ssh.ShellQuote(stack))
+	deadline := time.Now().Add(nodeDrainTimeout)
+
+	for {
+		stdout, _, err := sshPool.Run(ctx, manager, checkCmd)
+		if err == nil && strings.TrimSpace(stdout) == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for stack %s to stop", nodeDrainTimeout, stack)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
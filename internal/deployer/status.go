@@ -0,0 +1,446 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+	"dscotctl/internal/storage"
+)
+
+// wireguardHandshakeTimeout is how old the latest WireGuard handshake with a
+// peer may be before the peer is counted as disconnected.
+const wireguardHandshakeTimeout = 3 * time.Minute
+
+// NodeHealth is the health of a single node as reported by Status.
+type NodeHealth struct {
+	Host              string `json:"host"`
+	Role              string `json:"role"`
+	Reachable         bool   `json:"reachable"`
+	Error             string `json:"error,omitempty"`
+	Hostname          string `json:"hostname,omitempty"`
+	SwarmState        string `json:"swarmState,omitempty"`
+	SwarmStatus       string `json:"swarmStatus,omitempty"`
+	SwarmAvailability string `json:"swarmAvailability,omitempty"`
+	ManagerStatus     string `json:"managerStatus,omitempty"`
+	StorageEnabled    bool   `json:"storageEnabled"`
+	CephFSMounted     bool   `json:"cephfsMounted"`
+	OverlayPeers      string `json:"overlayPeers,omitempty"`
+	OverlayConnected  bool   `json:"overlayConnected"`
+	HoldsVIP          bool   `json:"holdsVip"`
+}
+
+// StorageHealth is the health of the distributed storage cluster.
+type StorageHealth struct {
+	Health     string      `json:"health"`
+	OSDs       int         `json:"osds"`
+	OSDsUp     int         `json:"osdsUp"`
+	OSDsIn     int         `json:"osdsIn"`
+	UsedBytes  int64       `json:"usedBytes"`
+	TotalBytes int64       `json:"totalBytes"`
+	Pools      []PoolUsage `json:"pools,omitempty"`
+}
+
+// PoolUsage is the space used by a storage pool.
+type PoolUsage struct {
+	Name          string  `json:"name"`
+	StoredBytes   int64   `json:"storedBytes"`
+	UsedBytes     int64   `json:"usedBytes"`
+	MaxAvailBytes int64   `json:"maxAvailBytes"`
+	PercentUsed   float64 `json:"percentUsed"`
+}
+
+// ServiceHealth is the replica convergence of a single Swarm service.
+type ServiceHealth struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"`
+	Running  int    `json:"running"`
+	Desired  int    `json:"desired"`
+	Replicas string `json:"replicas"`
+}
+
+// StackHealth is the replica convergence of the services in a stack.
+type StackHealth struct {
+	Name     string          `json:"name"`
+	Services []ServiceHealth `json:"services"`
+}
+
+// StatusReport is the aggregated health of the cluster.
+type StatusReport struct {
+	ClusterName string         `json:"clusterName"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Healthy     bool           `json:"healthy"`
+	Problems    []string       `json:"problems,omitempty"`
+	Manager     string         `json:"manager,omitempty"`
+	VIP         string         `json:"vip,omitempty"`
+	VIPHolders  []string       `json:"vipHolders,omitempty"`
+	Storage     *StorageHealth `json:"storage,omitempty"`
+	Nodes       []NodeHealth   `json:"nodes"`
+	Stacks      []StackHealth  `json:"stacks"`
+}
+
+// Status checks all enabled nodes in parallel and aggregates Swarm, storage,
+// overlay, Keepalived and stack health. Problems found are listed in the
+// report; an error is returned only if the report cannot be built.
+func Status(ctx context.Context, cfg *config.Config) (*StatusReport, error) {
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	return collectStatus(ctx, cfg, sshPool, defaults.StatusConcurrency)
+}
+
+// collectStatus builds a StatusReport over an existing SSH pool, checking at
+// most concurrency nodes at a time.
+func collectStatus(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, concurrency int) (*StatusReport, error) {
+	log := logging.L().With("component", "status")
+
+	report := &StatusReport{
+		ClusterName: cfg.GlobalSettings.ClusterName,
+		GeneratedAt: time.Now(),
+	}
+	problem := func(format string, args ...any) {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+	}
+
+	var mountPath string
+	if cfg.IsStorageEnabled() {
+		provider, err := storage.NewProvider(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage provider: %w", err)
+		}
+		mountPath = provider.GetMountPath()
+	}
+	report.VIP = getKeepalivedVIP(ctx, cfg, sshPool)
+
+	enabledNodes := getEnabledNodes(cfg)
+	log.Infow("collecting cluster status", "nodes", len(enabledNodes))
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	report.Nodes = make([]NodeHealth, len(enabledNodes))
+	var wg sync.WaitGroup
+	for i, node := range enabledNodes {
+		wg.Add(1)
+		go func(i int, node config.NodeConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			report.Nodes[i] = collectNodeHealth(ctx, sshPool, cfg, node, mountPath, report.VIP)
+		}(i, node)
+	}
+	wg.Wait()
+
+	managers, _ := categorizeNodes(cfg)
+	manager, err := findReachableManager(ctx, sshPool, managers)
+	if err != nil {
+		problem("%v", err)
+	} else {
+		report.Manager = manager
+		collectSwarmNodeStatus(ctx, sshPool, manager, report.Nodes)
+		report.Stacks = collectStackHealth(ctx, sshPool, manager)
+	}
+
+	if cfg.IsStorageEnabled() {
+		if status, err := storageStatus(ctx, cfg, sshPool); err != nil {
+			problem("storage status unavailable: %v", err)
+		} else {
+			report.Storage = &StorageHealth{
+				Health:     status.Health,
+				OSDs:       status.NodeCount,
+				OSDsUp:     status.OSDsUp,
+				OSDsIn:     status.OSDsIn,
+				UsedBytes:  status.StorageUsed,
+				TotalBytes: status.StorageTotal,
+			}
+			for _, pool := range status.Pools {
+				report.Storage.Pools = append(report.Storage.Pools, PoolUsage{
+					Name:          pool.Name,
+					StoredBytes:   pool.StoredBytes,
+					UsedBytes:     pool.UsedBytes,
+					MaxAvailBytes: pool.MaxAvailBytes,
+					PercentUsed:   pool.PercentUsed,
+				})
+			}
+			if !status.Healthy {
+				problem("storage health is %s", dash(status.Health))
+			}
+			if status.OSDsUp < status.NodeCount || status.OSDsIn < status.NodeCount {
+				problem("storage OSDs degraded: %d up, %d in of %d", status.OSDsUp, status.OSDsIn, status.NodeCount)
+			}
+		}
+	}
+
+	overlayEnabled := overlayProviderName(cfg) != ""
+	for _, n := range report.Nodes {
+		if !n.Reachable {
+			problem("%s: not reachable: %s", n.Host, n.Error)
+			continue
+		}
+		if n.SwarmState != "active" {
+			problem("%s: swarm state is %s", n.Host, dash(n.SwarmState))
+		}
+		if manager != "" && (n.SwarmStatus != "Ready" || n.SwarmAvailability != "Active") {
+			problem("%s: swarm node is %s/%s", n.Host, dash(n.SwarmStatus), dash(n.SwarmAvailability))
+		}
+		if n.ManagerStatus == "Unreachable" {
+			problem("%s: manager is unreachable from the Raft quorum", n.Host)
+		}
+		if n.StorageEnabled && mountPath != "" && !n.CephFSMounted {
+			problem("%s: CephFS not mounted at %s", n.Host, mountPath)
+		}
+		if overlayEnabled && !n.OverlayConnected {
+			problem("%s: overlay peers connected %s", n.Host, dash(n.OverlayPeers))
+		}
+		if n.HoldsVIP {
+			report.VIPHolders = append(report.VIPHolders, n.Host)
+		}
+	}
+
+	if report.VIP != "" {
+		switch len(report.VIPHolders) {
+		case 0:
+			problem("keepalived VIP %s is not held by any node", report.VIP)
+		case 1:
+		default:
+			problem("keepalived VIP %s is held by %d nodes: %s", report.VIP, len(report.VIPHolders), strings.Join(report.VIPHolders, ", "))
+		}
+	}
+
+	for _, stack := range report.Stacks {
+		for _, svc := range stack.Services {
+			if svc.Running < svc.Desired {
+				problem("service %s: %d of %d replicas running", svc.Name, svc.Running, svc.Desired)
+			}
+		}
+	}
+
+	report.Healthy = len(report.Problems) == 0
+	return report, nil
+}
+
+// overlayProviderName returns the configured overlay provider, or "" for none.
+func overlayProviderName(cfg *config.Config) string {
+	provider := strings.ToLower(strings.TrimSpace(cfg.GlobalSettings.OverlayProvider))
+	if provider == "none" {
+		return ""
+	}
+	return provider
+}
+
+// collectNodeHealth runs read-only health checks on a node.
+func collectNodeHealth(ctx context.Context, sshPool *ssh.Pool, cfg *config.Config, node config.NodeConfig, mountPath, vip string) NodeHealth {
+	health := NodeHealth{Host: node.SSHFQDNorIP, Role: node.Role, StorageEnabled: node.StorageEnabled}
+
+	run := func(cmd string) string {
+		stdout, _, _ := sshPool.Run(ctx, node.SSHFQDNorIP, cmd)
+		return strings.TrimSpace(stdout)
+	}
+
+	hostname, _, err := sshPool.Run(ctx, node.SSHFQDNorIP, "hostname")
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Reachable = true
+	health.Hostname = strings.TrimSpace(hostname)
+
+	health.SwarmState = run("docker info --format '{{.Swarm.LocalNodeState}}' 2>/dev/null")
+
+	if node.StorageEnabled && mountPath != "" {
+		health.CephFSMounted = run(fmt.Sprintf("mountpoint -q %s && echo yes", ssh.ShellQuote(mountPath))) == "yes"
+	}
+
+	if provider := overlayProviderName(cfg); provider != "" {
+		health.OverlayPeers, health.OverlayConnected = overlayPeerStatus(ctx, sshPool, node.SSHFQDNorIP, provider)
+	}
+
+	if vip != "" && node.Keepalived.Enabled {
+		health.HoldsVIP = run(fmt.Sprintf("ip -o addr show | grep -qF %s && echo yes", ssh.ShellQuote(" "+vip+"/"))) == "yes"
+	}
+
+	return health
+}
+
+// overlayPeerStatus returns the connected/total overlay peers of a node and
+// whether the node is connected to all of them.
+func overlayPeerStatus(ctx context.Context, sshPool *ssh.Pool, host, provider string) (string, bool) {
+	var connected, total int
+
+	switch provider {
+	case "netbird":
+		stdout, _, err := sshPool.Run(ctx, host, "netbird status 2>/dev/null | awk -F': ' '/^Peers count:/ {print $2}'")
+		if err != nil {
+			return "", false
+		}
+		// e.g. "3/4 Connected"
+		if _, err := fmt.Sscanf(strings.TrimSpace(stdout), "%d/%d", &connected, &total); err != nil {
+			return "", false
+		}
+	case "tailscale":
+		stdout, _, err := sshPool.Run(ctx, host, "tailscale status --json 2>/dev/null")
+		if err != nil {
+			return "", false
+		}
+		var status struct {
+			BackendState string
+			Peer         map[string]struct{ Online bool }
+		}
+		if err := json.Unmarshal([]byte(stdout), &status); err != nil || status.BackendState != "Running" {
+			return "", false
+		}
+		for _, peer := range status.Peer {
+			total++
+			if peer.Online {
+				connected++
+			}
+		}
+	case "wireguard":
+		stdout, _, err := sshPool.Run(ctx, host, "date +%s; wg show all latest-handshakes 2>/dev/null")
+		if err != nil {
+			return "", false
+		}
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		now, err := strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+		if err != nil {
+			return "", false
+		}
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			total++
+			if ts, err := strconv.ParseInt(fields[2], 10, 64); err == nil && ts > 0 && time.Duration(now-ts)*time.Second < wireguardHandshakeTimeout {
+				connected++
+			}
+		}
+	default:
+		return "", false
+	}
+
+	return fmt.Sprintf("%d/%d", connected, total), connected == total
+}
+
+// collectSwarmNodeStatus fills the Swarm status, availability and manager
+// status of each node from docker node ls on a manager.
+func collectSwarmNodeStatus(ctx context.Context, sshPool *ssh.Pool, manager string, nodes []NodeHealth) {
+	stdout, _, err := sshPool.Run(ctx, manager, "docker node ls --format '{{.Hostname}}\t{{.Status}}\t{{.Availability}}\t{{.ManagerStatus}}'")
+	if err != nil {
+		return
+	}
+
+	byHostname := make(map[string][]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		if fields := strings.Split(strings.TrimSpace(line), "\t"); len(fields) == 4 {
+			byHostname[fields[0]] = fields[1:]
+		}
+	}
+
+	for i := range nodes {
+		if fields, ok := byHostname[nodes[i].Hostname]; ok {
+			nodes[i].SwarmStatus = fields[0]
+			nodes[i].SwarmAvailability = fields[1]
+			nodes[i].ManagerStatus = fields[2]
+		}
+	}
+}
+
+// collectStackHealth lists the running and desired replicas of every service
+// in every deployed stack.
+func collectStackHealth(ctx context.Context, sshPool *ssh.Pool, manager string) []StackHealth {
+	var stacks []StackHealth
+	for _, name := range collectStacks(ctx, sshPool, manager) {
+		stack := StackHealth{Name: name}
+		cmd := fmt.Sprintf("docker stack services %s --format '{{.Name}}\t{{.Mode}}\t{{.Replicas}}'", ssh.ShellQuote(name))
+		stdout, _, err := sshPool.Run(ctx, manager, cmd)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(stdout, "\n") {
+			fields := strings.Split(strings.TrimSpace(line), "\t")
+			if len(fields) != 3 {
+				continue
+			}
+			svc := ServiceHealth{Name: fields[0], Mode: fields[1], Replicas: fields[2]}
+			// Replicas looks like "2/3" or "1/1 (max 1 per node)"
+			fmt.Sscanf(fields[2], "%d/%d", &svc.Running, &svc.Desired)
+			stack.Services = append(stack.Services, svc)
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks
+}
+
+// WriteText renders the status report as tables.
+func (r *StatusReport) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Status of cluster %s (%s)\n\n", r.ClusterName, r.GeneratedAt.Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tROLE\tREACHABLE\tSWARM\tAVAILABILITY\tMANAGER\tCEPHFS\tOVERLAY\tVIP")
+	for _, n := range r.Nodes {
+		cephfs := "-"
+		if n.StorageEnabled && r.Storage != nil {
+			cephfs = strconv.FormatBool(n.CephFSMounted)
+		}
+		vip := "-"
+		if n.HoldsVIP {
+			vip = r.VIP
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			n.Host, n.Role, n.Reachable, dash(n.SwarmStatus), dash(n.SwarmAvailability), dash(n.ManagerStatus), cephfs, dash(n.OverlayPeers), vip)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Storage != nil {
+		fmt.Fprintf(w, "\nStorage: %s, %d OSDs (%d up, %d in)\n", dash(r.Storage.Health), r.Storage.OSDs, r.Storage.OSDsUp, r.Storage.OSDsIn)
+	}
+
+	if len(r.Stacks) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "STACK\tSERVICE\tMODE\tREPLICAS")
+		for _, stack := range r.Stacks {
+			for _, svc := range stack.Services {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", stack.Name, svc.Name, svc.Mode, svc.Replicas)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if r.Healthy {
+		fmt.Fprintln(w, "\nCluster is healthy.")
+		return nil
+	}
+	fmt.Fprintf(w, "\nCluster is degraded (%d problem(s)):\n", len(r.Problems))
+	for _, p := range r.Problems {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+	return nil
+}
+
+// WriteJSON renders the status report as indented JSON.
+func (r *StatusReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
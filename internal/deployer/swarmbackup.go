@@ -0,0 +1,284 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/orchestrator"
+	"dscotctl/internal/retry"
+	"dscotctl/internal/ssh"
+)
+
+// swarmStateDir is where Docker keeps the Swarm Raft state, certificates and keys.
+const swarmStateDir = "/var/lib/docker/swarm"
+
+// dockerRestartTimeout bounds restarting Docker after its state was copied or
+// replaced. The restart runs even if the command is interrupted, so a node
+// is never left with Docker stopped.
+const dockerRestartTimeout = 5 * time.Minute
+
+// BackupSwarmState copies the Swarm Raft state of a manager to a local
+// tar.gz archive. Docker is stopped on the manager while the state is
+// archived, which stops every container on it, so a non-leader is chosen,
+// the backup is refused if stopping it would break quorum and the manager is
+// drained first so its replicated tasks move to other nodes. Global tasks
+// on the manager are down until Docker is back. A single-manager cluster is
+// backed up with a brief control-plane outage. Returns the archive path.
+func BackupSwarmState(ctx context.Context, cfg *config.Config, output string) (string, error) {
+	log := logging.L().With("component", "swarm-backup")
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	managers, _ := categorizeNodes(cfg)
+	if len(managers) == 0 {
+		return "", fmt.Errorf("no managers configured")
+	}
+
+	source, err := findBackupManager(ctx, sshPool, managers)
+	if err != nil {
+		return "", err
+	}
+	if err := orchestrator.CheckQuorum(ctx, sshPool, source, 1); err != nil {
+		if len(managers) > 1 {
+			return "", fmt.Errorf("refusing to stop Docker on %s: %w", source, err)
+		}
+		log.Warnw("⚠ single manager: the Swarm control plane is unavailable during the backup", "host", source)
+	}
+
+	stdout, stderr, err := sshPool.Run(ctx, source, "docker node inspect self --format '{{.Description.Hostname}}|{{.Spec.Availability}}'")
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w (stderr: %s)", source, err, stderr)
+	}
+	swarmName, availability, _ := strings.Cut(strings.TrimSpace(stdout), "|")
+
+	version, _, _ := sshPool.Run(ctx, source, "docker version --format '{{.Server.Version}}'")
+	if output == "" {
+		output = fmt.Sprintf("swarm-%s-%s.tar.gz", cfg.GlobalSettings.ClusterName, time.Now().UTC().Format(backupIDFormat))
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer f.Close()
+
+	if availability == "active" {
+		log.Infow("→ draining manager", "host", source, "node", swarmName)
+		if _, stderr, err := sshPool.Run(ctx, source, fmt.Sprintf("docker node update --availability drain %s", ssh.ShellQuote(swarmName))); err != nil {
+			os.Remove(output)
+			return "", fmt.Errorf("failed to drain %s: %w (stderr: %s)", source, err, stderr)
+		}
+		if err := waitForNodeDrained(ctx, sshPool, source, swarmName); err != nil {
+			log.Warnw("⚠ tasks still running after drain timeout (continuing)", "host", source, "error", err)
+		}
+	}
+
+	log.Infow("→ stopping Docker", "host", source)
+	if _, stderr, err := sshPool.Run(ctx, source, "systemctl stop docker.socket docker"); err != nil {
+		os.Remove(output)
+		restartDocker(ctx, sshPool, source, swarmName, availability == "active")
+		return "", fmt.Errorf("failed to stop Docker on %s: %w (stderr: %s)", source, err, stderr)
+	}
+
+	tarCmd := fmt.Sprintf("tar -czf - -C %s %s", ssh.ShellQuote(path.Dir(swarmStateDir)), ssh.ShellQuote(path.Base(swarmStateDir)))
+	stderr, tarErr := sshPool.RunStream(ctx, source, tarCmd, nil, f)
+
+	if err := restartDocker(ctx, sshPool, source, swarmName, availability == "active"); err != nil {
+		return "", err
+	}
+
+	if tarErr != nil {
+		os.Remove(output)
+		return "", fmt.Errorf("failed to archive swarm state: %w (stderr: %s)", tarErr, stderr)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	log.Infow("✅ swarm state backed up", "host", source, "file", output, "dockerVersion", strings.TrimSpace(version))
+	return output, nil
+}
+
+// restartDocker starts Docker on a manager after a swarm backup, waits for
+// it to rejoin the swarm and re-activates it if it was drained for the
+// backup. It runs on its own deadline so an interrupted backup still brings
+// Docker back.
+func restartDocker(ctx context.Context, sshPool *ssh.Pool, host, swarmName string, reactivate bool) error {
+	log := logging.L().With("component", "swarm-backup", "host", host)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dockerRestartTimeout)
+	defer cancel()
+
+	log.Infow("→ starting Docker")
+	if _, stderr, err := sshPool.Run(ctx, host, "systemctl start docker"); err != nil {
+		return fmt.Errorf("failed to start Docker on %s: %w (stderr: %s)", host, err, stderr)
+	}
+	if err := waitForSwarmActive(ctx, sshPool, host); err != nil {
+		return fmt.Errorf("%s did not rejoin the swarm after the backup: %w", host, err)
+	}
+	if !reactivate {
+		return nil
+	}
+
+	// The manager needs a moment to catch up with the Raft log before it accepts updates
+	activateCmd := fmt.Sprintf("docker node update --availability active %s", ssh.ShellQuote(swarmName))
+	err := retry.Do(ctx, retry.DefaultConfig("swarm-backup-activate"), func() error {
+		if _, stderr, err := sshPool.Run(ctx, host, activateCmd); err != nil {
+			return fmt.Errorf("failed to re-activate %s: %w (stderr: %s)", host, err, stderr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Infow("✓ manager re-activated")
+	return nil
+}
+
+// RecoverSwarm rebuilds the Swarm around one manager after quorum is lost.
+// With an archive from BackupSwarmState, the node's Raft state is replaced by
+// the archive first; without one, the node's own state is used. The other
+// enabled nodes are rejoined afterwards.
+func RecoverSwarm(ctx context.Context, cfg *config.Config, name, archive string) error {
+	log := logging.L().With("component", "swarm-recovery")
+
+	node, err := findNode(cfg, name)
+	if err != nil {
+		return err
+	}
+	if !node.IsEnabled() {
+		return fmt.Errorf("node %s is disabled in configuration", node.SSHFQDNorIP)
+	}
+	if node.Role != "manager" && node.Role != "both" {
+		return fmt.Errorf("node %s is not a manager", node.SSHFQDNorIP)
+	}
+	target := node.SSHFQDNorIP
+
+	var archiveFile *os.File
+	if archive != "" {
+		if archiveFile, err = os.Open(archive); err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer archiveFile.Close()
+	}
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	if archiveFile != nil {
+		if err := restoreSwarmState(ctx, sshPool, target, archiveFile); err != nil {
+			return err
+		}
+	}
+
+	info := nodeOverlayInfo(ctx, cfg, sshPool, target)
+	advertiseAddr := info.IP + ":2377"
+	if info.Interface != "" {
+		advertiseAddr = info.Interface + ":2377"
+	}
+	joinAddr := swarmJoinAddress(target, info)
+
+	managers, workers := categorizeNodes(cfg)
+	var otherManagers []string
+	for _, m := range managers {
+		if m != target {
+			otherManagers = append(otherManagers, m)
+		}
+	}
+
+	log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "recovering Docker Swarm"), "advertiseAddr", advertiseAddr, "joinAddr", joinAddr)
+	if err := orchestrator.RecoverSwarm(ctx, sshPool, target, advertiseAddr, joinAddr, otherManagers, workers); err != nil {
+		return err
+	}
+
+	if err := applyNodeLabels(ctx, cfg, sshPool, target); err != nil {
+		log.Warnw("failed to reapply node labels", "error", err)
+	}
+	return nil
+}
+
+// restoreSwarmState replaces a node's Swarm state with an archive. The
+// previous state is kept next to it as swarm.bak-<timestamp>.
+func restoreSwarmState(ctx context.Context, sshPool *ssh.Pool, host string, archive *os.File) error {
+	log := logging.L().With("component", "swarm-recovery", "host", host)
+
+	log.Infow("→ stopping Docker")
+	if _, stderr, err := sshPool.Run(ctx, host, "systemctl stop docker.socket docker"); err != nil {
+		return fmt.Errorf("failed to stop Docker: %w (stderr: %s)", err, stderr)
+	}
+
+	backupDir := swarmStateDir + ".bak-" + time.Now().UTC().Format(backupIDFormat)
+	moveCmd := fmt.Sprintf("if [ -d %[1]s ]; then mv %[1]s %[2]s; fi", ssh.ShellQuote(swarmStateDir), ssh.ShellQuote(backupDir))
+	if _, stderr, err := sshPool.Run(ctx, host, moveCmd); err != nil {
+		return fmt.Errorf("failed to move current swarm state aside: %w (stderr: %s)", err, stderr)
+	}
+
+	untarCmd := fmt.Sprintf("tar -xzf - -C %s", ssh.ShellQuote(path.Dir(swarmStateDir)))
+	if stderr, err := sshPool.RunStream(ctx, host, untarCmd, archive, io.Discard); err != nil {
+		return fmt.Errorf("failed to extract swarm state (previous state in %s): %w (stderr: %s)", backupDir, err, stderr)
+	}
+	log.Infow("✓ swarm state restored", "previous", backupDir)
+
+	// Docker is started even if the recovery was interrupted
+	startCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dockerRestartTimeout)
+	defer cancel()
+	if _, stderr, err := sshPool.Run(startCtx, host, "systemctl start docker"); err != nil {
+		return fmt.Errorf("failed to start Docker: %w (stderr: %s)", err, stderr)
+	}
+
+	stdout, _, err := sshPool.Run(ctx, host, "docker info --format '{{.Swarm.LocalNodeState}}'")
+	if err == nil && strings.TrimSpace(stdout) == "locked" {
+		return fmt.Errorf("restored swarm is autolocked: run 'docker swarm unlock' on %s, then rerun without an archive", host)
+	}
+	return nil
+}
+
+// findBackupManager returns a reachable manager that is not the Raft leader,
+// or the only manager when there is just one.
+func findBackupManager(ctx context.Context, sshPool *ssh.Pool, managers []string) (string, error) {
+	var reachable []string
+	for _, manager := range managers {
+		stdout, _, err := sshPool.Run(ctx, manager, "docker node inspect self --format '{{.ManagerStatus.Leader}}'")
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(stdout) == "false" {
+			return manager, nil
+		}
+		reachable = append(reachable, manager)
+	}
+
+	if len(managers) == 1 && len(reachable) == 1 {
+		return reachable[0], nil
+	}
+	return "", fmt.Errorf("no reachable non-leader manager among %d configured managers", len(managers))
+}
+
+// nodeOverlayInfo returns the overlay addresses of a node, falling back to
+// its SSH address when no overlay is configured or it cannot be queried.
+func nodeOverlayInfo(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, host string) OverlayInfo {
+	info := OverlayInfo{FQDN: host, IP: host}
+	provider := strings.ToLower(strings.TrimSpace(cfg.GlobalSettings.OverlayProvider))
+	if provider == "" || provider == "none" {
+		return info
+	}
+
+	overlayInfo, err := getOverlayInfoForNode(ctx, sshPool, host, provider)
+	if err != nil {
+		logging.L().Warnw("failed to get overlay info, using SSH hostname", "sshHost", host, "error", err)
+		return info
+	}
+	return overlayInfo
+}
@@ -0,0 +1,237 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/orchestrator"
+	"dscotctl/internal/retry"
+	"dscotctl/internal/ssh"
+	"dscotctl/internal/storage"
+)
+
+// nodeRebootTimeout is how long Upgrade waits for a rebooted node to return.
+const nodeRebootTimeout = 10 * time.Minute
+
+// Upgrade applies OS and Docker package upgrades one node at a time. Each node
+// is drained, upgraded, rebooted if the OS asks for it and re-activated only
+// once the Swarm and the storage cluster are healthy again. Workers go first;
+// managers are only taken down while the remaining managers keep a majority.
+func Upgrade(ctx context.Context, cfg *config.Config) error {
+	log := logging.L().With("component", "upgrade")
+
+	sshPool, err := openSSHPool(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH pool: %w", err)
+	}
+	defer sshPool.Close()
+
+	var provider storage.Provider
+	if cfg.IsStorageEnabled() {
+		provider, err = storage.NewProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create storage provider: %w", err)
+		}
+	}
+
+	// Workers first so manager capacity is untouched for as long as possible
+	var order []config.NodeConfig
+	for _, node := range getEnabledNodes(cfg) {
+		if node.Role == "worker" {
+			order = append(order, node)
+		}
+	}
+	for _, node := range getEnabledNodes(cfg) {
+		if node.Role != "worker" {
+			order = append(order, node)
+		}
+	}
+
+	log.Infow("🚀 Starting rolling upgrade", "nodes", len(order))
+	for i, node := range order {
+		log.Infow(formatNodeMessage("→", node.SSHFQDNorIP, node.NewHostname, node.Role, fmt.Sprintf("upgrading node (%d/%d)", i+1, len(order))))
+		if err := upgradeNode(ctx, cfg, sshPool, provider, node); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", node.SSHFQDNorIP, err)
+		}
+		log.Infow(formatNodeMessage("✓", node.SSHFQDNorIP, node.NewHostname, node.Role, "node upgraded"))
+	}
+
+	log.Infow("✅ Rolling upgrade complete", "nodes", len(order))
+	return nil
+}
+
+// upgradeNode drains, upgrades and re-activates a single node.
+func upgradeNode(ctx context.Context, cfg *config.Config, sshPool *ssh.Pool, provider storage.Provider, node config.NodeConfig) error {
+	log := logging.L().With("component", "upgrade", "node", node.SSHFQDNorIP)
+	target := node.SSHFQDNorIP
+	isManager := node.Role == "manager" || node.Role == "both"
+
+	managers, _ := categorizeNodes(cfg)
+	var otherManagers []string
+	for _, m := range managers {
+		if m != target {
+			otherManagers = append(otherManagers, m)
+		}
+	}
+	if len(otherManagers) == 0 {
+		return fmt.Errorf("%s is the only manager; upgrading it would lose quorum", target)
+	}
+	manager, err := findReachableManager(ctx, sshPool, otherManagers)
+	if err != nil {
+		return err
+	}
+
+	if isManager {
+		if err := orchestrator.CheckQuorum(ctx, sshPool, manager, 1); err != nil {
+			return err
+		}
+	}
+
+	stdout, stderr, err := sshPool.Run(ctx, target, "docker info --format '{{.Name}}'")
+	if err != nil {
+		return fmt.Errorf("failed to get Docker node name: %w (stderr: %s)", err, stderr)
+	}
+	swarmName := strings.TrimSpace(stdout)
+
+	log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "draining node"))
+	if _, stderr, err := sshPool.Run(ctx, manager, fmt.Sprintf("docker node update --availability drain %s", swarmName)); err != nil {
+		return fmt.Errorf("failed to drain node: %w (stderr: %s)", err, stderr)
+	}
+	if err := waitForNodeDrained(ctx, sshPool, manager, swarmName); err != nil {
+		log.Warnw(formatNodeMessage("⚠", target, node.NewHostname, node.Role, "tasks still running after drain timeout (continuing)"), "error", err)
+	}
+
+	// noout keeps Ceph from rebalancing while the node's OSDs are briefly down
+	var monNode string
+	if provider != nil && node.StorageEnabled {
+		monNode, err = findStorageMON(ctx, cfg, sshPool, provider, target)
+		if err != nil {
+			return err
+		}
+		if _, stderr, err := sshPool.Run(ctx, monNode, "ceph osd set noout"); err != nil {
+			return fmt.Errorf("failed to set Ceph noout: %w (stderr: %s)", err, stderr)
+		}
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "Ceph noout set"), "monNode", monNode)
+	}
+
+	rebootRequired, err := upgradePackages(ctx, sshPool, target, node.Role)
+	if err != nil {
+		return err
+	}
+
+	if rebootRequired {
+		log.Infow(formatNodeMessage("→", target, node.NewHostname, node.Role, "rebooting node"))
+		if err := rebootAndWait(ctx, sshPool, target); err != nil {
+			return err
+		}
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node is back online"))
+	}
+
+	if err := waitForSwarmActive(ctx, sshPool, target); err != nil {
+		return err
+	}
+	if isManager {
+		if err := orchestrator.VerifyManagerReady(ctx, sshPool, target); err != nil {
+			return fmt.Errorf("manager not ready after upgrade: %w", err)
+		}
+	}
+
+	if monNode != "" {
+		_, storageWorkers, _ := getStorageNodesByRole(cfg)
+		if err := provider.WaitForClusterHealth(ctx, sshPool, monNode, len(storageWorkers)); err != nil {
+			return fmt.Errorf("storage cluster not healthy after upgrade (noout is still set): %w", err)
+		}
+		if _, stderr, err := sshPool.Run(ctx, monNode, "ceph osd unset noout"); err != nil {
+			return fmt.Errorf("failed to unset Ceph noout: %w (stderr: %s)", err, stderr)
+		}
+		log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "Ceph noout unset"))
+	}
+
+	if _, stderr, err := sshPool.Run(ctx, manager, fmt.Sprintf("docker node update --availability active %s", swarmName)); err != nil {
+		return fmt.Errorf("failed to re-activate node: %w (stderr: %s)", err, stderr)
+	}
+	log.Infow(formatNodeMessage("✓", target, node.NewHostname, node.Role, "node re-activated"))
+	return nil
+}
+
+// upgradePackages upgrades OS and Docker packages on a node and reports
+// whether the OS requires a reboot. Docker configuration is re-applied
+// through installDocker afterwards.
+func upgradePackages(ctx context.Context, sshPool *ssh.Pool, host, nodeRole string) (bool, error) {
+	log := logging.L().With("node", host, "role", nodeRole)
+
+	log.Debugw("repairing dpkg state if needed")
+	sshPool.Run(ctx, host, "DEBIAN_FRONTEND=noninteractive dpkg --configure -a 2>/dev/null || true")
+
+	retryCfg := retry.PackageManagerConfig(fmt.Sprintf("upgrade-packages-%s", host))
+	err := retry.Do(ctx, retryCfg, func() error {
+		cmd := "DEBIAN_FRONTEND=noninteractive apt-get update -q && " +
+			"DEBIAN_FRONTEND=noninteractive apt-get -y -q -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold upgrade"
+		log.Infow("upgrading packages", "command", cmd)
+		_, stderr, err := sshPool.Run(ctx, host, cmd)
+		if err != nil {
+			return fmt.Errorf("package upgrade failed: %w (stderr: %s)", err, stderr)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := installDocker(ctx, sshPool, host, nodeRole); err != nil {
+		return false, fmt.Errorf("failed to install Docker: %w", err)
+	}
+
+	_, _, err = sshPool.Run(ctx, host, "test -f /var/run/reboot-required")
+	return err == nil, nil
+}
+
+// rebootAndWait reboots a node and waits until it answers over SSH with a new boot ID.
+func rebootAndWait(ctx context.Context, sshPool *ssh.Pool, host string) error {
+	bootIDCmd := "cat /proc/sys/kernel/random/boot_id"
+	stdout, stderr, err := sshPool.Run(ctx, host, bootIDCmd)
+	if err != nil {
+		return fmt.Errorf("failed to read boot ID: %w (stderr: %s)", err, stderr)
+	}
+	bootID := strings.TrimSpace(stdout)
+
+	// Connection may drop before the command returns
+	sshPool.Run(ctx, host, "nohup sh -c 'sleep 5 && reboot' > /dev/null 2>&1 &")
+
+	deadline := time.Now().Add(nodeRebootTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+
+		sshPool.Reset(host)
+		stdout, _, err := sshPool.Run(ctx, host, bootIDCmd)
+		if err == nil && strings.TrimSpace(stdout) != "" && strings.TrimSpace(stdout) != bootID {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s to reboot", nodeRebootTimeout, host)
+		}
+	}
+}
+
+// waitForSwarmActive waits until the node's Docker daemon reports an active Swarm membership.
+func waitForSwarmActive(ctx context.Context, sshPool *ssh.Pool, host string) error {
+	retryCfg := retry.DefaultConfig(fmt.Sprintf("swarm-active-%s", host))
+	return retry.Do(ctx, retryCfg, func() error {
+		stdout, stderr, err := sshPool.Run(ctx, host, "docker info --format '{{.Swarm.LocalNodeState}}'")
+		if err != nil {
+			return fmt.Errorf("failed to check swarm state: %w (stderr: %s)", err, stderr)
+		}
+		if state := strings.TrimSpace(stdout); state != "active" {
+			return fmt.Errorf("node swarm state is %q", state)
+		}
+		return nil
+	})
+}
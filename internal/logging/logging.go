@@ -3,6 +3,7 @@ package logging
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -91,7 +92,7 @@ func (l *simpleLogger) log(lvl level, name, msg string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	line := fmt.Sprintf("[%s] - [%s] - %s\n", ts, name, msg)
+	line := fmt.Sprintf("[%s] - [%s] - %s\n", ts, name, Redact(msg))
 	_, _ = os.Stderr.WriteString(line)
 	if l.file != nil {
 		_, _ = l.file.WriteString(line)
@@ -167,6 +168,49 @@ func Sync() {
 	logger.file = nil
 }
 
+// redactedValue replaces registered secrets in log output.
+const redactedValue = "********"
+
+// minSecretLength is the shortest value RegisterSecret accepts. Shorter values
+// would redact unrelated text.
+const minSecretLength = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret adds a value that is redacted from every log line and from
+// Redact output. Empty and very short values are ignored.
+func RegisterSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, s := range secrets {
+		if s == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+	// Replace longer secrets first so a secret containing another is fully redacted
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// Redact replaces all registered secrets in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	return s
+}
+
 // FormatNodeMessage formats a log message with node identifier.
 // Format: "prefix [hostname - [newHostname] - role] message"
 // If newHostname is blank: "prefix [hostname - role] message"
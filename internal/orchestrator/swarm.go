@@ -40,6 +40,12 @@ func SwarmSetup(ctx context.Context, sshPool *ssh.Pool, sshManagers, sshWorkers,
 	log.Infow(fmt.Sprintf("starting Docker Swarm setup: primaryManager=%s managers=%d workers=%d advertiseAddr=%s joinAddr=%s",
 		primaryManager, len(sshManagers), len(sshWorkers), primaryAdvertiseAddr, primaryJoinAddr))
 
+	// Phase 0: Promote/demote existing members whose configured role changed
+	log.Infow("phase 0: reconciling node roles of an existing swarm")
+	if err := ReconcileRoles(ctx, sshPool, sshManagers, sshWorkers); err != nil {
+		return fmt.Errorf("failed to reconcile node roles: %w", err)
+	}
+
 	// Phase 1: Initialize swarm on primary manager
 	log.Infow("phase 1: initializing Docker Swarm on primary manager")
 	if err := initSwarm(ctx, sshPool, primaryManager, primaryAdvertiseAddr); err != nil {
@@ -48,7 +54,7 @@ func SwarmSetup(ctx context.Context, sshPool *ssh.Pool, sshManagers, sshWorkers,
 
 	// Verify primary manager is ready and is actually a manager
 	log.Infow("→ verifying primary manager is ready")
-	if err := verifyManagerReady(ctx, sshPool, primaryManager); err != nil {
+	if err := VerifyManagerReady(ctx, sshPool, primaryManager); err != nil {
 		return fmt.Errorf("primary manager not ready: %w", err)
 	}
 	log.Infow("✓ primary manager is ready and active")
@@ -265,17 +271,22 @@ func verifySwarm(ctx context.Context, sshPool *ssh.Pool, primaryManager string,
 
 	lines := strings.Split(strings.TrimSpace(stdout), "\n")
 	managerCount := 0
+	reachableManagers := 0
 	workerCount := 0
 
 	for _, line := range lines {
-		if strings.Contains(line, "Leader") || strings.Contains(line, "Reachable") {
+		switch {
+		case strings.Contains(line, "Leader") || strings.Contains(line, "Reachable"):
 			managerCount++
-		} else {
+			reachableManagers++
+		case strings.Contains(line, "Unreachable"):
+			managerCount++
+		default:
 			workerCount++
 		}
 	}
 
-	logging.L().Infow(fmt.Sprintf("swarm status: %d managers, %d workers", managerCount, workerCount))
+	logging.L().Infow(fmt.Sprintf("swarm status: %d managers (%d reachable), %d workers", managerCount, reachableManagers, workerCount))
 	logging.L().Infow(fmt.Sprintf("node list:\n%s", stdout))
 
 	if managerCount != expectedManagers {
@@ -286,12 +297,64 @@ func verifySwarm(ctx context.Context, sshPool *ssh.Pool, primaryManager string,
 		return fmt.Errorf("worker count mismatch: got %d, expected %d", workerCount, expectedWorkers)
 	}
 
+	if reachableManagers < quorumMajority(managerCount) {
+		return fmt.Errorf("swarm has no quorum: %d of %d managers reachable, %d required", reachableManagers, managerCount, quorumMajority(managerCount))
+	}
+	if reachableManagers < managerCount {
+		logging.L().Warnw(fmt.Sprintf("⚠ %d manager(s) unreachable; the swarm tolerates %d more failure(s)",
+			managerCount-reachableManagers, reachableManagers-quorumMajority(managerCount)))
+	}
+
 	logging.L().Infow("✅ Docker Swarm verification PASSED")
 	return nil
 }
 
-// verifyManagerReady verifies that a node is ready and is actually a manager.
-func verifyManagerReady(ctx context.Context, sshPool *ssh.Pool, manager string) error {
+// quorumMajority returns the number of managers that must be reachable for
+// the Raft store to accept writes.
+func quorumMajority(managers int) int {
+	return managers/2 + 1
+}
+
+// ManagerCounts returns the number of managers in the Swarm and how many of
+// them are reachable, as seen by manager.
+func ManagerCounts(ctx context.Context, sshPool *ssh.Pool, manager string) (total, reachable int, err error) {
+	stdout, stderr, err := sshPool.Run(ctx, manager, "docker node ls --filter role=manager --format '{{.ManagerStatus}}'")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list managers: %w (stderr: %s)", err, stderr)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		total++
+		if line == "Leader" || line == "Reachable" {
+			reachable++
+		}
+	}
+	return total, reachable, nil
+}
+
+// CheckQuorum returns an error if taking down more reachable managers (by
+// stopping, demoting or removing them) would leave the Swarm without a
+// majority of reachable managers.
+func CheckQuorum(ctx context.Context, sshPool *ssh.Pool, manager string, down int) error {
+	total, reachable, err := ManagerCounts(ctx, sshPool, manager)
+	if err != nil {
+		return err
+	}
+
+	majority := quorumMajority(total)
+	if reachable-down < majority {
+		return fmt.Errorf("taking %d manager(s) down would break quorum: %d of %d managers reachable, %d required", down, reachable, total, majority)
+	}
+	return nil
+}
+
+// VerifyManagerReady verifies that a node is ready and is actually a manager,
+// retrying while the node's Docker daemon is still starting.
+func VerifyManagerReady(ctx context.Context, sshPool *ssh.Pool, manager string) error {
 	retryCfg := retry.DefaultConfig(fmt.Sprintf("verify-manager-%s", manager))
 
 	return retry.Do(ctx, retryCfg, func() error {
@@ -316,3 +379,228 @@ func verifyManagerReady(ctx context.Context, sshPool *ssh.Pool, manager string)
 		return nil
 	})
 }
+
+// JoinNode joins a single node to an existing swarm. The join tokens and
+// cluster ID are read from manager; joinAddr is the address (FQDN:port or
+// IP:port) the node connects to. Managers are verified to have control
+// available after joining.
+func JoinNode(ctx context.Context, sshPool *ssh.Pool, manager, node, joinAddr string, asManager bool) error {
+	log := logging.L().With("component", "orchestrator", "phase", "swarm")
+
+	managerToken, workerToken, clusterID, err := getJoinTokens(ctx, sshPool, manager)
+	if err != nil {
+		return fmt.Errorf("failed to get join tokens: %w", err)
+	}
+
+	token := workerToken
+	if asManager {
+		token = managerToken
+	}
+
+	log.Infow(fmt.Sprintf("joining %s to swarm", node), "manager", manager, "joinAddr", joinAddr, "asManager", asManager)
+	if err := joinNodes(ctx, sshPool, []string{node}, token, joinAddr, clusterID); err != nil {
+		return err
+	}
+
+	if asManager {
+		if err := VerifyManagerReady(ctx, sshPool, node); err != nil {
+			return fmt.Errorf("manager not ready: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RecoverSwarm rebuilds a Swarm that lost manager quorum. The recovery node
+// must hold the Raft state to keep (its own, or one restored from a backup);
+// it is re-initialized as the only manager with --force-new-cluster. The
+// remaining managers and workers leave their stale Swarm state and rejoin
+// through the recovery node, and nodes left behind as "Down" are removed.
+// Nodes that cannot be rejoined are reported but do not fail the recovery.
+func RecoverSwarm(ctx context.Context, sshPool *ssh.Pool, recoveryNode, advertiseAddr, joinAddr string, managers, workers []string) error {
+	log := logging.L().With("component", "orchestrator", "phase", "swarm-recovery")
+
+	cmd := fmt.Sprintf("docker swarm init --force-new-cluster --advertise-addr %s", advertiseAddr)
+	log.Infow("→ forcing new cluster from local Raft state", "host", recoveryNode, "command", cmd)
+	if _, stderr, err := sshPool.Run(ctx, recoveryNode, cmd); err != nil {
+		return fmt.Errorf("failed to force new cluster: %w (stderr: %s)", err, stderr)
+	}
+	if err := VerifyManagerReady(ctx, sshPool, recoveryNode); err != nil {
+		return fmt.Errorf("recovery node not ready: %w", err)
+	}
+	log.Infow("✓ single-manager cluster restored", "host", recoveryNode)
+
+	managerToken, workerToken, clusterID, err := getJoinTokens(ctx, sshPool, recoveryNode)
+	if err != nil {
+		return fmt.Errorf("failed to get join tokens: %w", err)
+	}
+
+	// The cluster keeps its ID, so joinNodes would treat the old members as
+	// joined; they leave first to drop their stale Raft state.
+	var failed []string
+	rejoin := func(nodes []string, token string, asManager bool) {
+		for _, node := range nodes {
+			if _, stderr, err := sshPool.Run(ctx, node, "docker swarm leave --force"); err != nil && !strings.Contains(stderr, "not part of a swarm") {
+				log.Warnw(fmt.Sprintf("%s: failed to leave stale swarm", node), "error", err, "stderr", stderr)
+				failed = append(failed, node)
+				continue
+			}
+			if err := joinNodes(ctx, sshPool, []string{node}, token, joinAddr, clusterID); err != nil {
+				log.Warnw(fmt.Sprintf("%s: failed to rejoin swarm", node), "error", err)
+				failed = append(failed, node)
+				continue
+			}
+			if asManager {
+				if err := VerifyManagerReady(ctx, sshPool, node); err != nil {
+					log.Warnw(fmt.Sprintf("%s: manager not ready after rejoin", node), "error", err)
+					failed = append(failed, node)
+				}
+			}
+		}
+	}
+	rejoin(managers, managerToken, true)
+	rejoin(workers, workerToken, false)
+
+	if err := removeDownNodes(ctx, sshPool, recoveryNode); err != nil {
+		log.Warnw("⚠ failed to remove stale node entries", "error", err)
+	}
+
+	if len(failed) > 0 {
+		log.Warnw(fmt.Sprintf("⚠ %d node(s) could not rejoin; run 'node add' for them once reachable", len(failed)), "nodes", strings.Join(failed, ", "))
+	}
+	log.Infow("✅ Docker Swarm recovered", "managers", len(managers)+1-countIn(failed, managers), "workers", len(workers)-countIn(failed, workers))
+	return nil
+}
+
+// removeDownNodes removes the node entries a recovery leaves behind: every
+// node that left and rejoined keeps its old entry with status Down.
+func removeDownNodes(ctx context.Context, sshPool *ssh.Pool, manager string) error {
+	stdout, stderr, err := sshPool.Run(ctx, manager, "docker node ls --format '{{.ID}} {{.Status}} {{.ManagerStatus}}'")
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w (stderr: %s)", err, stderr)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != "Down" {
+			continue
+		}
+		id := fields[0]
+		if len(fields) > 2 {
+			if _, stderr, err := sshPool.Run(ctx, manager, fmt.Sprintf("docker node demote %s", id)); err != nil {
+				return fmt.Errorf("failed to demote stale node %s: %w (stderr: %s)", id, err, stderr)
+			}
+		}
+		if _, stderr, err := sshPool.Run(ctx, manager, fmt.Sprintf("docker node rm --force %s", id)); err != nil {
+			return fmt.Errorf("failed to remove stale node %s: %w (stderr: %s)", id, err, stderr)
+		}
+		logging.L().Infow("✓ stale node entry removed", "component", "orchestrator", "nodeID", id)
+	}
+	return nil
+}
+
+// countIn returns how many of nodes appear in list.
+func countIn(list, nodes []string) int {
+	n := 0
+	for _, node := range nodes {
+		for _, l := range list {
+			if l == node {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// swarmMember is a node's view of its own Swarm membership.
+type swarmMember struct {
+	Host    string
+	NodeID  string
+	Manager bool
+}
+
+// ReconcileRoles promotes Swarm members configured as managers and demotes
+// members configured as workers. Promotions run first so the manager count
+// only drops once replacements are reachable; each demotion is refused if it
+// would break quorum. Nodes not yet in the Swarm are left to the join phases.
+func ReconcileRoles(ctx context.Context, sshPool *ssh.Pool, managers, workers []string) error {
+	log := logging.L().With("component", "orchestrator", "phase", "swarm-roles")
+
+	var members []swarmMember
+	controller := ""
+	for _, host := range append(append([]string{}, managers...), workers...) {
+		stdout, _, err := sshPool.Run(ctx, host, "docker info --format '{{.Swarm.LocalNodeState}} {{.Swarm.NodeID}} {{.Swarm.ControlAvailable}}'")
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(stdout)
+		if len(fields) != 3 || fields[0] != "active" {
+			continue
+		}
+		member := swarmMember{Host: host, NodeID: fields[1], Manager: fields[2] == "true"}
+		members = append(members, member)
+		if member.Manager && controller == "" {
+			controller = host
+		}
+	}
+	if controller == "" {
+		log.Infow("no existing swarm manager found; nothing to reconcile")
+		return nil
+	}
+
+	wantManager := make(map[string]bool)
+	for _, m := range managers {
+		wantManager[m] = true
+	}
+
+	var promote, demote []swarmMember
+	for _, member := range members {
+		switch {
+		case wantManager[member.Host] && !member.Manager:
+			promote = append(promote, member)
+		case !wantManager[member.Host] && member.Manager:
+			demote = append(demote, member)
+		}
+	}
+	if len(promote) == 0 && len(demote) == 0 {
+		log.Infow("✓ node roles match the configuration")
+		return nil
+	}
+
+	for _, member := range promote {
+		log.Infow(fmt.Sprintf("→ %s: promoting to manager", member.Host), "nodeID", member.NodeID, "via", controller)
+		if _, stderr, err := sshPool.Run(ctx, controller, fmt.Sprintf("docker node promote %s", member.NodeID)); err != nil {
+			return fmt.Errorf("failed to promote %s: %w (stderr: %s)", member.Host, err, stderr)
+		}
+		if err := VerifyManagerReady(ctx, sshPool, member.Host); err != nil {
+			return fmt.Errorf("promoted manager %s not ready: %w", member.Host, err)
+		}
+		log.Infow(fmt.Sprintf("✓ %s: promoted to manager", member.Host))
+	}
+
+	// Demotions run through a member that is (now) a manager and stays one
+	via := ""
+	for _, m := range members {
+		if wantManager[m.Host] {
+			via = m.Host
+			break
+		}
+	}
+	for _, member := range demote {
+		if via == "" {
+			return fmt.Errorf("cannot demote %s: no configured manager is in the swarm", member.Host)
+		}
+
+		if err := CheckQuorum(ctx, sshPool, via, 1); err != nil {
+			return fmt.Errorf("refusing to demote %s: %w", member.Host, err)
+		}
+		log.Infow(fmt.Sprintf("→ %s: demoting to worker", member.Host), "nodeID", member.NodeID, "via", via)
+		if _, stderr, err := sshPool.Run(ctx, via, fmt.Sprintf("docker node demote %s", member.NodeID)); err != nil {
+			return fmt.Errorf("failed to demote %s: %w (stderr: %s)", member.Host, err, stderr)
+		}
+		log.Infow(fmt.Sprintf("✓ %s: demoted to worker", member.Host))
+	}
+
+	return nil
+}
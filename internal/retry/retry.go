@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -61,8 +62,32 @@ func NetworkConfig(operation string) Config {
 	}
 }
 
+// nonRetryableError marks an error that must not be retried.
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string { return e.err.Error() }
+func (e *nonRetryableError) Unwrap() error { return e.err }
+
+// NonRetryable wraps err so that Do and DoWithResult return it immediately
+// instead of retrying. The original error remains reachable via errors.As/Is.
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &nonRetryableError{err: err}
+}
+
+// IsNonRetryable reports whether err was marked with NonRetryable.
+func IsNonRetryable(err error) bool {
+	var nr *nonRetryableError
+	return errors.As(err, &nr)
+}
+
 // Do executes the given function with retry logic and exponential backoff.
 // Returns nil if the operation succeeds within MaxAttempts, otherwise returns the last error.
+// Errors wrapped with NonRetryable are returned immediately.
 func Do(ctx context.Context, cfg Config, fn func() error) error {
 	backoff := cfg.InitialBackoff
 	log := logging.L()
@@ -79,6 +104,10 @@ func Do(ctx context.Context, cfg Config, fn func() error) error {
 			return nil
 		}
 
+		if IsNonRetryable(err) {
+			return fmt.Errorf("%s: failed with non-retryable error: %w", cfg.Operation, err)
+		}
+
 		if attempt < cfg.MaxAttempts {
 			log.Warnw("operation failed, retrying",
 				"operation", cfg.Operation,
@@ -126,6 +155,10 @@ func DoWithResult[T any](ctx context.Context, cfg Config, fn func() (T, error))
 			return res, nil
 		}
 
+		if IsNonRetryable(err) {
+			return result, fmt.Errorf("%s: failed with non-retryable error: %w", cfg.Operation, err)
+		}
+
 		if attempt < cfg.MaxAttempts {
 			log.Warnw("operation failed, retrying",
 				"operation", cfg.Operation,
@@ -154,4 +187,3 @@ func DoWithResult[T any](ctx context.Context, cfg Config, fn func() (T, error))
 
 	return result, fmt.Errorf("%s: unexpected retry loop exit", cfg.Operation)
 }
-
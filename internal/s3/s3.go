@@ -0,0 +1,392 @@
+// Package s3 is a minimal client for S3-compatible object storage (AWS S3,
+// MinIO, Ceph RADOS Gateway). Requests use path-style addressing and AWS
+// Signature Version 4. Uploads are streamed with multipart uploads.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// partSize is the multipart upload part size. Objects smaller than one part
+// are uploaded with a single PUT.
+const partSize = 16 << 20
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Client talks to one S3-compatible endpoint.
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+}
+
+// New creates a client for endpoint (e.g. "https://s3.example.com" or
+// "http://minio.local:9000").
+func New(endpoint, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: scheme must be http or https", endpoint)
+	}
+	return &Client{
+		endpoint:  u,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		http:      &http.Client{},
+	}, nil
+}
+
+// errorResponse is the XML error body returned by S3.
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Error is an S3 error response.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("S3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err is an S3 "not found" error.
+func IsNotFound(err error) bool {
+	var s3Err *Error
+	return errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound
+}
+
+// EnsureBucket creates bucket if it does not exist.
+func (c *Client) EnsureBucket(ctx context.Context, bucket string) error {
+	resp, err := c.do(ctx, http.MethodHead, bucket, "", nil, nil)
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if !IsNotFound(err) {
+		return err
+	}
+
+	resp, err = c.do(ctx, http.MethodPut, bucket, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutObject uploads the contents of r to bucket/key without knowing its
+// size in advance. Large objects are sent as multipart uploads.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, r io.Reader) error {
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, buf[:n])
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read upload data: %w", err)
+	}
+
+	uploadID, err := c.createMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	etags, err := c.uploadParts(ctx, bucket, key, uploadID, buf, r)
+	if err == nil {
+		err = c.completeMultipartUpload(ctx, bucket, key, uploadID, etags)
+	}
+	if err != nil {
+		// Abort so the parts do not linger in the bucket
+		if resp, abortErr := c.do(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// uploadParts uploads the already filled first part and the rest of r.
+func (c *Client) uploadParts(ctx context.Context, bucket, key, uploadID string, first []byte, r io.Reader) ([]string, error) {
+	var etags []string
+	part := first
+	for partNumber := 1; ; partNumber++ {
+		query := url.Values{"partNumber": {fmt.Sprintf("%d", partNumber)}, "uploadId": {uploadID}}
+		resp, err := c.do(ctx, http.MethodPut, bucket, key, query, part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", partNumber, err)
+		}
+		resp.Body.Close()
+		etags = append(etags, resp.Header.Get("ETag"))
+
+		buf := make([]byte, partSize)
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return etags, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read upload data: %w", err)
+		}
+		part = buf[:n]
+	}
+}
+
+// createMultipartUpload starts a multipart upload and returns its ID.
+func (c *Client) createMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload of %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse multipart upload response: %w", err)
+	}
+	return result.UploadID, nil
+}
+
+// completeMultipartUpload assembles the uploaded parts into the object.
+func (c *Client) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, etags []string) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, etag := range etags {
+		body.Parts = append(body.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure in a 200 response
+	respData, _ := io.ReadAll(resp.Body)
+	var errResp errorResponse
+	if xml.Unmarshal(respData, &errResp) == nil && errResp.Code != "" {
+		return &Error{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Message}
+	}
+	return nil
+}
+
+// GetObject returns a reader for bucket/key. The caller must close it.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject deletes bucket/key.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the keys under prefix and, when delimiter is set, the common
+// prefixes one level below it.
+func (c *Client) List(ctx context.Context, bucket, prefix, delimiter string) (keys, prefixes []string, err error) {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, bucket, "", query, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse list response: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, prefixes, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request. Responses with a status of 300 or more are
+// returned as *Error with the body closed.
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = c.endpoint.Path + "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var errResp errorResponse
+		_ = xml.Unmarshal(data, &errResp)
+		if errResp.Code == "" {
+			errResp.Code = resp.Status
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Message}
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, c.region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by key as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except unreserved characters; "/" is
+// kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+)
+
+// acmeOrderTimeout bounds the challenge validation and issuance of one certificate.
+const acmeOrderTimeout = 5 * time.Minute
+
+// ACMEOptions configures certificate issuance with IssueCertificates.
+type ACMEOptions struct {
+	DirectoryURL       string        // ACME directory URL
+	Email              string        // Contact address for the ACME account (optional)
+	RenewBefore        time.Duration // Renew certificates expiring within this window
+	InsecureSkipVerify bool          // Skip TLS verification of the directory (test servers only)
+}
+
+// RenewCertificates issues due certificates with IssueCertificates and, when
+// any were issued, regenerates and rolls out the proxy rules so the virtual
+// hosts use them.
+func RenewCertificates(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata, nginxServiceName string, nodeHostnameToSSH map[string]string, opts ACMEOptions) error {
+	issued, issueErr := IssueCertificates(ctx, sshPool, primaryMaster, storagePath, services, opts)
+	if issued > 0 {
+		if err := GenerateProxyRulesForServices(ctx, sshPool, primaryMaster, storagePath, services, nginxServiceName, nodeHostnameToSSH); err != nil {
+			return fmt.Errorf("failed to roll out proxy rules with new certificates: %w", err)
+		}
+	}
+	return issueErr
+}
+
+// IssueCertificates obtains or renews a certificate for every virtual host
+// declared with NGINX_HOST, using the ACME HTTP-01 challenge. Challenge
+// responses are written to the shared acme-challenge directory that every
+// EdgeLoadBalancer serves on port 80; keys and certificates are stored in
+// ssl/<host>/ on shared storage, where GenerateProxyRulesForServices picks
+// them up. The ACME account key is kept in ssl/acme/. Returns the number of
+// certificates issued; hosts that fail are reported in the error.
+func IssueCertificates(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata, opts ACMEOptions) (int, error) {
+	log := logging.L().With("component", "acme", "directory", opts.DirectoryURL)
+
+	var proxyServices []ServiceMetadata
+	for _, svc := range services {
+		if svc.Enabled && svc.NginxProxy {
+			proxyServices = append(proxyServices, svc)
+		}
+	}
+
+	sslPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, "ssl"))
+	var due []string
+	for _, host := range proxyHosts(proxyServices) {
+		if strings.HasPrefix(host, "*.") {
+			log.Warnw("skipping wildcard host, HTTP-01 cannot validate wildcards", "host", host)
+			continue
+		}
+		certFile := filepath.ToSlash(filepath.Join(sslPath, hostCertDir(host), "fullchain.pem"))
+		if reason := certificateRenewalReason(ctx, sshPool, primaryMaster, certFile, host, opts.RenewBefore); reason != "" {
+			log.Infow("certificate due", "host", host, "reason", reason)
+			due = append(due, host)
+		}
+	}
+	if len(due) == 0 {
+		log.Infow("✓ all virtual host certificates are current")
+		return 0, nil
+	}
+
+	accountKey, err := loadOrCreateAccountKey(ctx, sshPool, primaryMaster, filepath.ToSlash(filepath.Join(sslPath, "acme", "account.key")))
+	if err != nil {
+		return 0, err
+	}
+
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: opts.DirectoryURL,
+		UserAgent:    "dscotctl",
+	}
+	if opts.InsecureSkipVerify {
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}
+	}
+
+	account := &acme.Account{}
+	if opts.Email != "" {
+		account.Contact = []string{"mailto:" + opts.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return 0, fmt.Errorf("failed to register ACME account: %w", err)
+	}
+
+	challengeRoot := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, "acme-challenge"))
+	issued := 0
+	var failed []string
+	for _, host := range due {
+		certDir := filepath.ToSlash(filepath.Join(sslPath, hostCertDir(host)))
+		if err := obtainCertificate(ctx, client, sshPool, primaryMaster, challengeRoot, certDir, host); err != nil {
+			log.Warnw("failed to obtain certificate", "host", host, "error", err)
+			failed = append(failed, host)
+			continue
+		}
+		log.Infow("✓ certificate issued", "host", host, "certDir", certDir)
+		issued++
+	}
+
+	if len(failed) > 0 {
+		return issued, fmt.Errorf("failed to obtain certificates for %s", strings.Join(failed, ", "))
+	}
+	return issued, nil
+}
+
+// certificateRenewalReason returns why the certificate in certFile must be
+// (re)issued for host, or "" if it is valid beyond renewBefore.
+func certificateRenewalReason(ctx context.Context, sshPool *ssh.Pool, host, certFile, name string, renewBefore time.Duration) string {
+	stdout, _, err := sshPool.Run(ctx, host, fmt.Sprintf("cat '%s' 2>/dev/null", certFile))
+	if err != nil || strings.TrimSpace(stdout) == "" {
+		return "missing"
+	}
+	block, _ := pem.Decode([]byte(stdout))
+	if block == nil {
+		return "unreadable"
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "unreadable"
+	}
+	if cert.VerifyHostname(name) != nil {
+		return "hostname mismatch"
+	}
+	if time.Until(cert.NotAfter) < renewBefore {
+		return fmt.Sprintf("expires %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// loadOrCreateAccountKey reads the ACME account key from keyFile, creating it
+// on first use.
+func loadOrCreateAccountKey(ctx context.Context, sshPool *ssh.Pool, host, keyFile string) (crypto.Signer, error) {
+	stdout, _, err := sshPool.Run(ctx, host, fmt.Sprintf("cat '%s' 2>/dev/null", keyFile))
+	if err == nil && strings.TrimSpace(stdout) != "" {
+		block, _ := pem.Decode([]byte(stdout))
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode ACME account key %s", keyFile)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACME account key %s: %w", keyFile, err)
+		}
+		return key, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ACME account key: %w", err)
+	}
+	keyDir := filepath.ToSlash(filepath.Dir(keyFile))
+	if _, stderr, err := sshPool.Run(ctx, host, fmt.Sprintf("mkdir -p '%s' && chmod 700 '%s'", keyDir, keyDir)); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w (stderr: %s)", keyDir, err, stderr)
+	}
+	if err := sshPool.WriteFile(ctx, host, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write ACME account key: %w", err)
+	}
+	logging.L().Infow("created ACME account key", "file", keyFile)
+	return key, nil
+}
+
+// obtainCertificate runs one ACME order for name and stores the key and
+// certificate chain in certDir as privkey.pem and fullchain.pem.
+func obtainCertificate(ctx context.Context, client *acme.Client, sshPool *ssh.Pool, host, challengeRoot, certDir, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, acmeOrderTimeout)
+	defer cancel()
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(name))
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return fmt.Errorf("failed to get authorization: %w", err)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+
+		var chal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "http-01" {
+				chal = c
+				break
+			}
+		}
+		if chal == nil {
+			return fmt.Errorf("no http-01 challenge offered for %s", name)
+		}
+
+		response, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return fmt.Errorf("failed to compute challenge response: %w", err)
+		}
+		challengeFile := challengeRoot + client.HTTP01ChallengePath(chal.Token)
+		challengeDir := filepath.ToSlash(filepath.Dir(challengeFile))
+		if _, stderr, err := sshPool.Run(ctx, host, fmt.Sprintf("mkdir -p '%s'", challengeDir)); err != nil {
+			return fmt.Errorf("failed to create %s: %w (stderr: %s)", challengeDir, err, stderr)
+		}
+		if err := sshPool.WriteFile(ctx, host, challengeFile, []byte(response), 0644); err != nil {
+			return fmt.Errorf("failed to write challenge response: %w", err)
+		}
+
+		_, err = client.Accept(ctx, chal)
+		if err == nil {
+			_, err = client.WaitAuthorization(ctx, authz.URI)
+		}
+		_ = sshPool.Remove(context.Background(), host, challengeFile)
+		if err != nil {
+			return fmt.Errorf("http-01 challenge failed: %w", err)
+		}
+	}
+
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("order not ready: %w", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: name},
+		DNSNames: []string{name},
+	}, certKey)
+	if err != nil {
+		return fmt.Errorf("failed to create CSR: %w", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("failed to finalize order: %w", err)
+	}
+
+	var fullchain []byte
+	for _, der := range chain {
+		fullchain = append(fullchain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate key: %w", err)
+	}
+
+	// Write both files next to the current ones, then swap them in together
+	if _, stderr, err := sshPool.Run(ctx, host, fmt.Sprintf("mkdir -p '%s'", certDir)); err != nil {
+		return fmt.Errorf("failed to create %s: %w (stderr: %s)", certDir, err, stderr)
+	}
+	if err := sshPool.WriteFile(ctx, host, certDir+"/privkey.pem.new", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write certificate key: %w", err)
+	}
+	if err := sshPool.WriteFile(ctx, host, certDir+"/fullchain.pem.new", fullchain, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate chain: %w", err)
+	}
+	swapCmd := fmt.Sprintf("cd '%s' && mv -f privkey.pem.new privkey.pem && mv -f fullchain.pem.new fullchain.pem", certDir)
+	if _, stderr, err := sshPool.Run(ctx, host, swapCmd); err != nil {
+		return fmt.Errorf("failed to install certificate: %w (stderr: %s)", err, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+)
+
+const (
+	// EdgeAuthStackName is the stack of the oauth2-proxy companion that
+	// authenticates requests to services with NGINX_AUTH: oidc
+	EdgeAuthStackName = "EdgeAuth"
+	// EdgeAuthDataDir is the subdirectory for EdgeAuth data (the generated cookie secret)
+	EdgeAuthDataDir = "EdgeAuth"
+	// edgeAuthPort is the port oauth2-proxy listens on
+	edgeAuthPort = 4180
+	// edgeAuthSecretLabel marks the Docker secrets that belong to EdgeAuth
+	edgeAuthSecretLabel = "dscotctl.edgeauth"
+)
+
+// edgeAuthSecrets names the Docker secrets mounted into oauth2-proxy.
+type edgeAuthSecrets struct {
+	ClientSecret string
+	CookieSecret string
+}
+
+// EdgeAuthOptions configures the EdgeAuth companion.
+type EdgeAuthOptions struct {
+	IssuerURL          string   // OIDC issuer URL
+	ClientID           string   // OIDC client ID
+	ClientSecret       string   // OIDC client secret
+	CookieSecret       string   // Session cookie secret (generated on shared storage when empty)
+	EmailDomains       []string // Allowed e-mail domains ("*" for any)
+	AllowedGroups      []string // Allowed groups (empty for any)
+	Scope              string   // OIDC scope
+	Image              string   // oauth2-proxy image
+	InsecureSkipVerify bool     // Skip TLS verification of the provider (mock providers only)
+}
+
+// oidcDiscovery models the parts of an OpenID provider configuration we check.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// usesEdgeAuth reports whether any enabled proxied service uses NGINX_AUTH: oidc.
+func usesEdgeAuth(services []ServiceMetadata) bool {
+	for _, svc := range services {
+		if svc.Enabled && svc.NginxProxy && svc.NginxAuth == "oidc" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileEdgeAuth deploys the EdgeAuth companion when OIDC authentication is
+// configured and a service uses NGINX_AUTH: oidc, and removes it otherwise.
+// Without the companion, protected locations fail closed: the auth subrequest
+// cannot reach EdgeAuth and Nginx answers 500.
+func ReconcileEdgeAuth(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata, opts *EdgeAuthOptions) error {
+	log := logging.L().With("component", "edgeauth")
+
+	if !usesEdgeAuth(services) || opts == nil {
+		if usesEdgeAuth(services) {
+			log.Warnw("⚠ services use NGINX_AUTH: oidc but globalSettings.edgeAuth is not enabled; their locations answer 500 until it is")
+		}
+		stdout, _, _ := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("docker stack ls --format '{{.Name}}' | grep -qx %s && echo exists", EdgeAuthStackName))
+		if strings.TrimSpace(stdout) != "exists" {
+			return nil
+		}
+		log.Infow("→ removing EdgeAuth stack, no longer needed")
+		if _, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("docker stack rm %s", EdgeAuthStackName)); err != nil {
+			return fmt.Errorf("failed to remove EdgeAuth stack: %w (stderr: %s)", err, stderr)
+		}
+		removeEdgeAuthSecrets(ctx, sshPool, primaryMaster, edgeAuthSecrets{})
+		return nil
+	}
+
+	if err := checkOIDCIssuer(ctx, opts.IssuerURL, opts.InsecureSkipVerify); err != nil {
+		// dscotctl may not reach a provider that only the cluster can reach
+		log.Warnw("⚠ OIDC issuer check failed", "issuer", opts.IssuerURL, "error", err)
+	}
+
+	cookieSecret := opts.CookieSecret
+	if cookieSecret == "" {
+		var err error
+		if cookieSecret, err = loadOrCreateCookieSecret(ctx, sshPool, primaryMaster, storagePath); err != nil {
+			return err
+		}
+	}
+	logging.RegisterSecret(cookieSecret)
+
+	secrets := edgeAuthSecrets{
+		ClientSecret: edgeAuthSecretName("client-secret", opts.ClientSecret),
+		CookieSecret: edgeAuthSecretName("cookie-secret", cookieSecret),
+	}
+	if err := createEdgeAuthSecret(ctx, sshPool, primaryMaster, secrets.ClientSecret, opts.ClientSecret); err != nil {
+		return err
+	}
+	if err := createEdgeAuthSecret(ctx, sshPool, primaryMaster, secrets.CookieSecret, cookieSecret); err != nil {
+		return err
+	}
+
+	remoteFile := fmt.Sprintf("/tmp/dscotctl-service-%s.yml", EdgeAuthStackName)
+	if err := sshPool.WriteFile(ctx, primaryMaster, remoteFile, []byte(renderEdgeAuthStack(*opts, secrets)), 0600); err != nil {
+		return fmt.Errorf("failed to upload EdgeAuth stack file: %w", err)
+	}
+	defer sshPool.Run(ctx, primaryMaster, fmt.Sprintf("rm -f %s", remoteFile))
+
+	deployCmd := fmt.Sprintf("docker stack deploy --prune --detach=true -c %s %s", remoteFile, EdgeAuthStackName)
+	if _, stderr, err := sshPool.Run(ctx, primaryMaster, deployCmd); err != nil {
+		return fmt.Errorf("failed to deploy EdgeAuth stack: %w (stderr: %s)", err, stderr)
+	}
+	removeEdgeAuthSecrets(ctx, sshPool, primaryMaster, secrets)
+
+	log.Infow("✅ EdgeAuth deployed", "issuer", opts.IssuerURL, "clientId", opts.ClientID, "image", opts.Image)
+	return nil
+}
+
+// checkOIDCIssuer fetches the provider configuration of an issuer and checks
+// that it names the same issuer and has the endpoints oauth2-proxy needs.
+func checkOIDCIssuer(ctx context.Context, issuerURL string, insecureSkipVerify bool) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if insecureSkipVerify {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	discoveryURL := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid issuer URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", discoveryURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: %s", discoveryURL, resp.Status)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return fmt.Errorf("failed to parse provider configuration: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return fmt.Errorf("provider reports issuer %q, expected %q", discovery.Issuer, issuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return fmt.Errorf("provider configuration lacks authorization, token or JWKS endpoint")
+	}
+	return nil
+}
+
+// loadOrCreateCookieSecret returns the generated cookie secret from shared
+// storage, creating it on first use so sessions survive redeployments. A
+// secret that exists but cannot be read is an error: regenerating it would
+// sign every user out.
+func loadOrCreateCookieSecret(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string) (string, error) {
+	secretPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeAuthDataDir, "cookie-secret"))
+
+	_, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("test -e %s", ssh.ShellQuote(secretPath)))
+	switch {
+	case err == nil:
+		stdout, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("cat %s", ssh.ShellQuote(secretPath)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read cookie secret %s: %w (stderr: %s)", secretPath, err, stderr)
+		}
+		secret := strings.TrimSpace(stdout)
+		if len(secret) != 32 {
+			return "", fmt.Errorf("cookie secret %s is not 32 characters; fix or delete it to generate a new one", secretPath)
+		}
+		return secret, nil
+	case ssh.ExitStatus(err) != 1:
+		return "", fmt.Errorf("failed to check cookie secret %s: %w (stderr: %s)", secretPath, err, stderr)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate cookie secret: %w", err)
+	}
+	secret := hex.EncodeToString(buf)
+
+	if _, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("mkdir -p %s", ssh.ShellQuote(filepath.ToSlash(filepath.Dir(secretPath))))); err != nil {
+		return "", fmt.Errorf("failed to create EdgeAuth data directory: %w (stderr: %s)", err, stderr)
+	}
+	if err := sshPool.WriteFile(ctx, primaryMaster, secretPath, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to store cookie secret: %w", err)
+	}
+	logging.L().Infow("generated EdgeAuth cookie secret", "component", "edgeauth", "path", secretPath)
+	return secret, nil
+}
+
+// edgeAuthSecretName returns the Docker secret name for a value. Docker
+// secrets cannot be updated, so the name carries a hash of the value and a
+// changed value becomes a new secret that the stack switches to.
+func edgeAuthSecretName(kind, value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("%s_%s_%s", EdgeAuthStackName, kind, hex.EncodeToString(sum[:])[:12])
+}
+
+// createEdgeAuthSecret creates a Docker secret unless it already exists. The
+// value is passed on stdin, so it never appears in a command line.
+func createEdgeAuthSecret(ctx context.Context, sshPool *ssh.Pool, primaryMaster, name, value string) error {
+	if _, _, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("docker secret inspect %s >/dev/null 2>&1", ssh.ShellQuote(name))); err == nil {
+		return nil
+	}
+	createCmd := fmt.Sprintf("docker secret create --label %s=true %s -", edgeAuthSecretLabel, ssh.ShellQuote(name))
+	if stderr, err := sshPool.RunStream(ctx, primaryMaster, createCmd, strings.NewReader(value), io.Discard); err != nil {
+		return fmt.Errorf("failed to create Docker secret %s: %w (stderr: %s)", name, err, stderr)
+	}
+	logging.L().Infow("✓ Docker secret created", "component", "edgeauth", "secret", name)
+	return nil
+}
+
+// removeEdgeAuthSecrets removes the EdgeAuth secrets other than keep. Secrets
+// still used by tasks that are shutting down cannot be removed yet; they are
+// retried on the next deployment.
+func removeEdgeAuthSecrets(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, keep edgeAuthSecrets) {
+	stdout, _, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("docker secret ls --filter label=%s --format '{{.Name}}'", edgeAuthSecretLabel))
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Fields(stdout) {
+		if name == keep.ClientSecret || name == keep.CookieSecret {
+			continue
+		}
+		if _, _, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("docker secret rm %s", ssh.ShellQuote(name))); err != nil {
+			logging.L().Debugw("Docker secret still in use, keeping it for now", "component", "edgeauth", "secret", name)
+		}
+	}
+}
+
+// renderEdgeAuthStack renders the stack file of the EdgeAuth companion.
+// oauth2-proxy runs in auth_request mode: /oauth2/auth answers 202 or 401,
+// and the sign-in flow returns to the path in X-Auth-Request-Redirect. The
+// callback is https://<host>/oauth2/callback of the host the user signed in on.
+// The client and cookie secrets are mounted as Docker secrets and read from
+// files, so they do not show up in `docker service inspect`.
+func renderEdgeAuthStack(opts EdgeAuthOptions, secrets edgeAuthSecrets) string {
+	args := []string{
+		"--provider=oidc",
+		"--oidc-issuer-url=" + opts.IssuerURL,
+		"--client-id=" + opts.ClientID,
+		"--client-secret-file=/run/secrets/client-secret",
+		"--cookie-secret-file=/run/secrets/cookie-secret",
+		"--scope=" + opts.Scope,
+		fmt.Sprintf("--http-address=0.0.0.0:%d", edgeAuthPort),
+		"--reverse-proxy=true",
+		"--set-xauthrequest=true",
+		"--skip-provider-button=true",
+		"--upstream=static://202",
+		"--cookie-secure=true",
+	}
+	for _, domain := range opts.EmailDomains {
+		args = append(args, "--email-domain="+domain)
+	}
+	for _, group := range opts.AllowedGroups {
+		args = append(args, "--allowed-group="+group)
+	}
+	if opts.InsecureSkipVerify {
+		args = append(args, "--ssl-insecure-skip-verify=true")
+	}
+
+	var stack strings.Builder
+	stack.WriteString("# EdgeAuth - OIDC authentication for EdgeLoadBalancer locations with NGINX_AUTH: oidc\n")
+	stack.WriteString("# Generated by dscotctl from globalSettings.edgeAuth - do not edit\n\n")
+	stack.WriteString("services:\n")
+	stack.WriteString(fmt.Sprintf("  %s:\n", EdgeAuthStackName))
+	stack.WriteString(fmt.Sprintf("    image: %s\n", composeQuote(opts.Image)))
+	stack.WriteString("    command:\n")
+	for _, arg := range args {
+		stack.WriteString(fmt.Sprintf("      - %s\n", composeQuote(arg)))
+	}
+	stack.WriteString("    secrets:\n")
+	stack.WriteString("      - source: client_secret\n")
+	stack.WriteString("        target: client-secret\n")
+	stack.WriteString("      - source: cookie_secret\n")
+	stack.WriteString("        target: cookie-secret\n")
+	stack.WriteString("    networks:\n")
+	stack.WriteString("      - INTERNAL\n")
+	stack.WriteString("    deploy:\n")
+	stack.WriteString("      mode: replicated\n")
+	stack.WriteString(fmt.Sprintf("      replicas: %d\n", defaults.EdgeAuthReplicas))
+	stack.WriteString("      placement:\n")
+	stack.WriteString("        constraints:\n")
+	stack.WriteString("          - node.platform.os==linux\n")
+	stack.WriteString("      resources:\n")
+	stack.WriteString("        limits:\n")
+	stack.WriteString("          memory: 128M\n")
+	stack.WriteString("      restart_policy:\n")
+	stack.WriteString("        condition: on-failure\n")
+	stack.WriteString("        delay: 5s\n\n")
+	stack.WriteString("networks:\n")
+	stack.WriteString("  INTERNAL:\n")
+	stack.WriteString(fmt.Sprintf("    name: %s\n", defaults.InternalNetworkName))
+	stack.WriteString("    external: true\n\n")
+	stack.WriteString("secrets:\n")
+	stack.WriteString("  client_secret:\n")
+	stack.WriteString(fmt.Sprintf("    name: %s\n", secrets.ClientSecret))
+	stack.WriteString("    external: true\n")
+	stack.WriteString("  cookie_secret:\n")
+	stack.WriteString(fmt.Sprintf("    name: %s\n", secrets.CookieSecret))
+	stack.WriteString("    external: true\n")
+	return stack.String()
+}
+
+// composeQuote quotes a value for a stack file. `$` is doubled because
+// docker stack deploy interpolates variables.
+func composeQuote(value string) string {
+	return strconv.Quote(strings.ReplaceAll(value, "$", "$$"))
+}
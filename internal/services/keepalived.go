@@ -142,6 +142,7 @@ func PrepareKeepalivedDeployment(ctx context.Context, sshPool *ssh.Pool, cfg *co
 	authPass := globalKA.AuthPass
 	if config.IsAutoValue(authPass) || authPass == "" {
 		authPass = generateAuthPassword()
+		logging.RegisterSecret(authPass)
 		log.Infow("generated Keepalived auth password", "password", authPass)
 	}
 
@@ -520,12 +521,8 @@ fi
 	keepalivedConf := generateKeepalivedConf(nodeConfig, deployment)
 
 	// Write configuration
-	writeCmd := fmt.Sprintf(`cat > /etc/keepalived/keepalived.conf << 'KEEPALIVED_EOF'
-%s
-KEEPALIVED_EOF`, keepalivedConf)
-
-	if _, stderr, err := sshPool.Run(ctx, host, writeCmd); err != nil {
-		return fmt.Errorf("failed to write keepalived.conf: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, host, "/etc/keepalived/keepalived.conf", []byte(keepalivedConf), 0644); err != nil {
+		return fmt.Errorf("failed to write keepalived.conf: %w", err)
 	}
 
 	// Write health check script
@@ -620,14 +617,11 @@ else
     exit 1
 fi
 `
-	cmd := fmt.Sprintf(`cat > %s << 'SCRIPT_EOF'
-%s
-SCRIPT_EOF
-chmod +x %s`, scriptPath, script, scriptPath)
-
-	_, stderr, err := sshPool.Run(ctx, host, cmd)
-	if err != nil {
-		return fmt.Errorf("failed to write health check script: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, host, scriptPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write health check script: %w", err)
+	}
+	if _, stderr, err := sshPool.Run(ctx, host, fmt.Sprintf("chmod +x %s", scriptPath)); err != nil {
+		return fmt.Errorf("failed to make health check script executable: %w (stderr: %s)", err, stderr)
 	}
 
 	return nil
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"dscotctl/internal/logging"
@@ -59,7 +61,7 @@ func GetEdgeLoadBalancerService(services []ServiceMetadata) *ServiceMetadata {
 
 // PrepareEdgeLoadBalancerDeployment prepares EdgeLoadBalancer (Nginx) for deployment by creating base config files.
 // Directory creation is handled dynamically by parseBindMounts in services.go which parses the YAML.
-func PrepareEdgeLoadBalancerDeployment(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string) (*NginxConfig, error) {
+func PrepareEdgeLoadBalancerDeployment(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata) (*NginxConfig, error) {
 	log := logging.L().With("component", "edgeloadbalancer")
 
 	log.Infow("preparing EdgeLoadBalancer deployment")
@@ -78,7 +80,7 @@ func PrepareEdgeLoadBalancerDeployment(ctx context.Context, sshPool *ssh.Pool, p
 
 	// Create base nginx.conf if it doesn't exist (creates parent dir too)
 	nginxConfPath := filepath.ToSlash(filepath.Join(confPath, "nginx.conf"))
-	if err := createBaseNginxConfig(ctx, sshPool, primaryMaster, nginxConfPath); err != nil {
+	if err := createBaseNginxConfig(ctx, sshPool, primaryMaster, nginxConfPath, services); err != nil {
 		return nil, fmt.Errorf("failed to create nginx.conf: %w", err)
 	}
 
@@ -98,8 +100,10 @@ func PrepareEdgeLoadBalancerDeployment(ctx context.Context, sshPool *ssh.Pool, p
 	return config, nil
 }
 
-// createBaseNginxConfig creates the base nginx.conf if it doesn't exist
-func createBaseNginxConfig(ctx context.Context, sshPool *ssh.Pool, host string, configPath string) error {
+// createBaseNginxConfig creates the base nginx.conf if it doesn't exist, with the
+// limit_req_zone definitions for services with NGINX_RATE_LIMIT. An existing
+// nginx.conf is left alone; its zones are kept current by GenerateProxyRulesForServices.
+func createBaseNginxConfig(ctx context.Context, sshPool *ssh.Pool, host string, configPath string, services []ServiceMetadata) error {
 	log := logging.L().With("component", "nginx")
 
 	// Ensure parent directory exists
@@ -146,6 +150,9 @@ http {
     keepalive_timeout 65;
     types_hash_max_size 2048;
 
+    # BEGIN dscotctl rate limit zones
+    # END dscotctl rate limit zones
+
     # Gzip compression
     gzip on;
     gzip_vary on;
@@ -164,9 +171,10 @@ http {
 include /etc/nginx/stream.d/*.conf;
 `
 
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOFNGINX'\n%sEOFNGINX", configPath, nginxConf)
-	if _, stderr, err := sshPool.Run(ctx, host, writeCmd); err != nil {
-		return fmt.Errorf("failed to write nginx.conf: %w (stderr: %s)", err, stderr)
+	nginxConf = withRateLimitZones(nginxConf, renderRateLimitZones(services))
+
+	if err := sshPool.WriteFile(ctx, host, configPath, []byte(nginxConf), 0644); err != nil {
+		return fmt.Errorf("failed to write nginx.conf: %w", err)
 	}
 
 	log.Infow("created nginx.conf", "path", configPath)
@@ -215,9 +223,8 @@ server {
 }
 `
 
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOFDEFAULT'\n%sEOFDEFAULT", configPath, defaultConf)
-	if _, stderr, err := sshPool.Run(ctx, host, writeCmd); err != nil {
-		return fmt.Errorf("failed to write default.conf: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, host, configPath, []byte(defaultConf), 0644); err != nil {
+		return fmt.Errorf("failed to write default.conf: %w", err)
 	}
 
 	log.Infow("created default.conf", "path", configPath)
@@ -316,9 +323,8 @@ func AddProxyRule(ctx context.Context, sshPool *ssh.Pool, primaryMaster string,
 	proxyConf.WriteString("}\n")
 
 	// Write the config file
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOFPROXY'\n%sEOFPROXY", ruleFile, proxyConf.String())
-	if _, stderr, err := sshPool.Run(ctx, primaryMaster, writeCmd); err != nil {
-		return fmt.Errorf("failed to write proxy rule: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, primaryMaster, ruleFile, []byte(proxyConf.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write proxy rule: %w", err)
 	}
 
 	log.Infow("added proxy rule", "location", rule.Location, "upstream", rule.Upstream, "file", ruleFile)
@@ -374,9 +380,12 @@ func createHtpasswdFile(ctx context.Context, sshPool *ssh.Pool, host string, aut
 }
 
 // GenerateProxyRulesForServices generates Nginx proxy configurations for enabled services with NGINX_PROXY: true
+// and TCP stream configurations for services with NGINX_TCP_STREAM, then rolls them out with applyNginxConfig:
+// the configs are validated with nginx -t before they replace the live ones, and the previous configs are
+// restored if Nginx is unhealthy after the reload.
 // Services are identified by their Docker Swarm service name pattern: StackName_ServiceName
 // We use variable-based proxy_pass with resolver so Nginx can start even if upstreams haven't started yet.
-func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata) error {
+func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata, nginxServiceName string, nodeHostnameToSSH map[string]string) error {
 	log := logging.L().With("component", "nginx")
 
 	var proxyServices []ServiceMetadata
@@ -391,27 +400,100 @@ func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, prima
 		}
 	}
 
+	var files []nginxConfigFile
 	if len(proxyServices) == 0 {
 		log.Infow("no services require Nginx proxy rules")
-		return nil
+	} else {
+		log.Infow("generating Nginx proxy rules for services", "count", len(proxyServices))
+
+		// Create htpasswd files for services with basic auth
+		authPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir, "auth"))
+		for _, svc := range proxyServices {
+			if svc.NginxBasicAuth != "" {
+				if err := createHtpasswdFile(ctx, sshPool, primaryMaster, authPath, svc.Name, svc.NginxBasicAuth); err != nil {
+					log.Warnw("failed to create htpasswd file", "service", svc.Name, "error", err)
+				}
+			}
+		}
+
+		// Use the certificate issued for a virtual host when it exists, the default one otherwise
+		sslPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, "ssl"))
+		hostCerts := make(map[string]bool)
+		for _, host := range proxyHosts(proxyServices) {
+			certDir := filepath.ToSlash(filepath.Join(sslPath, hostCertDir(host)))
+			checkCmd := fmt.Sprintf("test -f '%s/fullchain.pem' && test -f '%s/privkey.pem' && echo 'exists'", certDir, certDir)
+			stdout, _, _ := sshPool.Run(ctx, primaryMaster, checkCmd)
+			hostCerts[host] = strings.TrimSpace(stdout) == "exists"
+			if !hostCerts[host] {
+				log.Infow("no certificate for virtual host, using default certificate", "host", host, "certDir", certDir)
+			}
+		}
+
+		// default.conf - the default server with path-based rules plus one server
+		// block per virtual host. This replaces the placeholder created by
+		// createDefaultServerConfig
+		files = append(files, nginxConfigFile{Path: "conf.d/default.conf", Content: renderProxyConfig(proxyServices, hostCerts)})
+		log.Infow("✓ generated Nginx default.conf with proxy rules", "services", len(proxyServices), "virtualHosts", len(hostCerts))
 	}
 
-	log.Infow("generating Nginx proxy rules for services", "count", len(proxyServices))
+	// Keep the rate limit zones in nginx.conf in line with NGINX_RATE_LIMIT
+	nginxConfPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir, "nginx.conf"))
+	if current, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("cat '%s'", nginxConfPath)); err != nil {
+		log.Warnw("failed to read nginx.conf, rate limit zones not updated", "error", err, "stderr", stderr)
+	} else if updated := withRateLimitZones(current, renderRateLimitZones(services)); updated != current {
+		files = append(files, nginxConfigFile{Path: "nginx.conf", Content: updated})
+		log.Infow("✓ updated rate limit zones in nginx.conf")
+	}
 
-	// Create htpasswd files for services with basic auth
-	authPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir, "auth"))
-	for _, svc := range proxyServices {
-		if svc.NginxBasicAuth != "" {
-			if err := createHtpasswdFile(ctx, sshPool, primaryMaster, authPath, svc.Name, svc.NginxBasicAuth); err != nil {
-				log.Warnw("failed to create htpasswd file", "service", svc.Name, "error", err)
+	// Generate TCP stream configs for services with NGINX_TCP_STREAM
+	if streamConfig := renderTCPStreamConfig(services); streamConfig != "" {
+		files = append(files, nginxConfigFile{Path: "stream.d/services-stream.conf", Content: streamConfig})
+	}
+
+	if len(files) == 0 {
+		// Nothing generated - reload so Nginx picks up the placeholder config
+		return ReloadNginx(ctx, sshPool, primaryMaster, nginxServiceName)
+	}
+	return applyNginxConfig(ctx, sshPool, primaryMaster, storagePath, nginxServiceName, nodeHostnameToSSH, files)
+}
+
+// hostnamePattern matches a DNS hostname, optionally with a leading wildcard label
+var hostnamePattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// proxyHosts returns the valid virtual hosts of all services, sorted and without duplicates
+func proxyHosts(services []ServiceMetadata) []string {
+	log := logging.L().With("component", "nginx")
+
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, svc := range services {
+		for _, host := range svc.NginxHosts {
+			if !hostnamePattern.MatchString(host) {
+				log.Warnw("ignoring invalid NGINX_HOST", "service", svc.Name, "host", host)
+				continue
+			}
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
 			}
 		}
 	}
+	sort.Strings(hosts)
+	return hosts
+}
 
-	// Write to default.conf - this is the single server block with health check + proxy rules
-	// This replaces the placeholder created by createDefaultServerConfig
-	confPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir, "conf.d"))
-	defaultConfigPath := filepath.ToSlash(filepath.Join(confPath, "default.conf"))
+// hostCertDir returns the directory below the ssl directory that holds the
+// certificate of a virtual host ("*.example.com" becomes "_.example.com")
+func hostCertDir(host string) string {
+	return strings.ReplaceAll(host, "*", "_")
+}
+
+// renderProxyConfig renders default.conf: an HTTP server that redirects to HTTPS,
+// the default HTTPS server with a location per path-based service, and one HTTPS
+// server per virtual host. hostCerts reports which virtual hosts have their own
+// certificate; the others use the default self-signed certificate.
+func renderProxyConfig(proxyServices []ServiceMetadata, hostCerts map[string]bool) string {
+	log := logging.L().With("component", "nginx")
 
 	var config strings.Builder
 	config.WriteString("# Default server with proxy rules - auto-generated by dscotctl\n")
@@ -419,6 +501,13 @@ func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, prima
 	config.WriteString("# Uses variable-based proxy_pass for graceful handling of missing upstreams\n")
 	config.WriteString("# All traffic served over HTTPS with self-signed certificate by default\n\n")
 
+	// Upstream groups for services with sticky sessions or retries
+	for _, svc := range proxyServices {
+		if usesUpstreamGroup(svc) {
+			writeUpstream(&config, svc)
+		}
+	}
+
 	// HTTP server block - redirect to HTTPS (except health check)
 	config.WriteString("# HTTP server - redirects all traffic to HTTPS\n")
 	config.WriteString("server {\n")
@@ -441,23 +530,14 @@ func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, prima
 	config.WriteString("    }\n")
 	config.WriteString("}\n\n")
 
-	// HTTPS server block with SSL and all proxy locations
+	// HTTPS server block with SSL and all path-based proxy locations
 	config.WriteString("# HTTPS server with proxy rules\n")
 	config.WriteString("server {\n")
 	config.WriteString("    listen 443 ssl default_server;\n")
 	config.WriteString("    listen [::]:443 ssl default_server;\n")
 	config.WriteString("    server_name _;\n\n")
 	config.WriteString("    # SSL configuration - uses default self-signed cert until replaced\n")
-	config.WriteString("    ssl_certificate /etc/nginx/ssl/default.crt;\n")
-	config.WriteString("    ssl_certificate_key /etc/nginx/ssl/default.key;\n")
-	config.WriteString("    ssl_protocols TLSv1.2 TLSv1.3;\n")
-	config.WriteString("    ssl_ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384;\n")
-	config.WriteString("    ssl_prefer_server_ciphers off;\n")
-	config.WriteString("    ssl_session_cache shared:SSL:10m;\n")
-	config.WriteString("    ssl_session_timeout 1d;\n\n")
-	config.WriteString("    # Docker embedded DNS resolver - allows Nginx to start even if upstreams don't exist yet\n")
-	config.WriteString("    resolver 127.0.0.11 valid=10s ipv6=off;\n")
-	config.WriteString("    resolver_timeout 5s;\n\n")
+	writeSSLSettings(&config, "/etc/nginx/ssl/default.crt", "/etc/nginx/ssl/default.key")
 
 	// Health check endpoint for Docker healthcheck (also on HTTPS)
 	config.WriteString("    # Health check endpoint\n")
@@ -467,116 +547,432 @@ func GenerateProxyRulesForServices(ctx context.Context, sshPool *ssh.Pool, prima
 	config.WriteString("        add_header Content-Type text/plain;\n")
 	config.WriteString("    }\n\n")
 
-	// Add proxy rules for each service
+	// Add proxy rules for each service without a virtual host
+	var defaultServices []ServiceMetadata
 	for _, svc := range proxyServices {
-		// Docker Swarm service name format: StackName_ServiceName
-		dockerServiceName := fmt.Sprintf("%s_%s", svc.Name, svc.Name)
+		if len(svc.NginxHosts) == 0 {
+			defaultServices = append(defaultServices, svc)
+		}
+	}
+	if usesEdgeAuth(defaultServices) {
+		writeEdgeAuthLocations(&config)
+	}
+	for _, svc := range defaultServices {
+		writeProxyLocation(&config, svc, svc.NginxPath)
+	}
+
+	// Default location - fallback for unmatched paths
+	config.WriteString("    # Default location - fallback for unmatched paths\n")
+	config.WriteString("    location / {\n")
+	config.WriteString("        return 200 \"Nginx EdgeLoadBalancer is running\\n\";\n")
+	config.WriteString("        add_header Content-Type text/plain;\n")
+	config.WriteString("    }\n")
+	config.WriteString("}\n")
 
-		proxyPath := svc.NginxPath
-		if !strings.HasSuffix(proxyPath, "/") {
-			proxyPath += "/"
+	// One HTTPS server block per virtual host
+	for _, host := range proxyHosts(proxyServices) {
+		certFile, keyFile := "/etc/nginx/ssl/default.crt", "/etc/nginx/ssl/default.key"
+		if hostCerts[host] {
+			certDir := "/etc/nginx/ssl/" + hostCertDir(host)
+			certFile, keyFile = certDir+"/fullchain.pem", certDir+"/privkey.pem"
 		}
 
-		port := svc.NginxPort
-		if port == 0 {
-			port = 80
+		config.WriteString(fmt.Sprintf("\n# HTTPS server for %s\n", host))
+		config.WriteString("server {\n")
+		config.WriteString("    listen 443 ssl;\n")
+		config.WriteString("    listen [::]:443 ssl;\n")
+		config.WriteString(fmt.Sprintf("    server_name %s;\n\n", host))
+		writeSSLSettings(&config, certFile, keyFile)
+
+		var hostServices []ServiceMetadata
+		for _, svc := range proxyServices {
+			if containsString(svc.NginxHosts, host) {
+				hostServices = append(hostServices, svc)
+			}
+		}
+		if usesEdgeAuth(hostServices) {
+			writeEdgeAuthLocations(&config)
 		}
 
-		// Create a safe variable name (lowercase, underscores)
-		varName := strings.ToLower(strings.ReplaceAll(svc.Name, "-", "_"))
+		paths := make(map[string]string)
+		for _, svc := range hostServices {
+			proxyPath := svc.NginxPath
+			if !strings.HasSuffix(proxyPath, "/") {
+				proxyPath += "/"
+			}
+			if other, exists := paths[proxyPath]; exists {
+				log.Warnw("skipping duplicate location on virtual host", "host", host, "path", proxyPath, "service", svc.Name, "servedBy", other)
+				continue
+			}
+			paths[proxyPath] = svc.Name
+			writeProxyLocation(&config, svc, proxyPath)
+		}
 
-		log.Infow("adding proxy rule",
-			"service", svc.Name,
-			"path", proxyPath,
-			"upstream", fmt.Sprintf("%s:%d", dockerServiceName, port),
-			"websocket", svc.NginxWebSocket,
-			"basicAuth", svc.NginxBasicAuth != "",
-			"stripPrefix", svc.NginxStripPrefix,
-		)
+		if _, exists := paths["/"]; !exists {
+			config.WriteString("    location / {\n")
+			config.WriteString("        return 404;\n")
+			config.WriteString("    }\n")
+		}
+		config.WriteString("}\n")
+	}
 
-		config.WriteString(fmt.Sprintf("    # %s\n", svc.Name))
-		config.WriteString(fmt.Sprintf("    location %s {\n", proxyPath))
+	return config.String()
+}
+
+// writeSSLSettings writes the TLS and resolver directives of an HTTPS server block
+func writeSSLSettings(config *strings.Builder, certFile, keyFile string) {
+	config.WriteString(fmt.Sprintf("    ssl_certificate %s;\n", certFile))
+	config.WriteString(fmt.Sprintf("    ssl_certificate_key %s;\n", keyFile))
+	config.WriteString("    ssl_protocols TLSv1.2 TLSv1.3;\n")
+	config.WriteString("    ssl_ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384;\n")
+	config.WriteString("    ssl_prefer_server_ciphers off;\n")
+	config.WriteString("    ssl_session_cache shared:SSL:10m;\n")
+	config.WriteString("    ssl_session_timeout 1d;\n\n")
+	config.WriteString("    # Docker embedded DNS resolver - allows Nginx to start even if upstreams don't exist yet\n")
+	config.WriteString("    resolver 127.0.0.11 valid=10s ipv6=off;\n")
+	config.WriteString("    resolver_timeout 5s;\n\n")
+}
+
+// writeProxyLocation writes the location block that proxies proxyPath to a service
+func writeProxyLocation(config *strings.Builder, svc ServiceMetadata, proxyPath string) {
+	log := logging.L().With("component", "nginx")
+
+	// Docker Swarm service name format: StackName_ServiceName
+	dockerServiceName := fmt.Sprintf("%s_%s", svc.Name, svc.Name)
+
+	if !strings.HasSuffix(proxyPath, "/") {
+		proxyPath += "/"
+	}
+	// There is no prefix to strip from the root location
+	stripPrefix := svc.NginxStripPrefix && proxyPath != "/"
+
+	port := svc.NginxPort
+	if port == 0 {
+		port = 80
+	}
+
+	// Create a safe variable name (lowercase, underscores)
+	varName := strings.ToLower(strings.ReplaceAll(svc.Name, "-", "_"))
+
+	// Proxy timeouts: NGINX_TIMEOUT wins over the long WebSocket default
+	readTimeout := ""
+	if svc.NginxWebSocket {
+		readTimeout = "86400"
+	}
+	if svc.NginxTimeout != "" {
+		if nginxTimePattern.MatchString(svc.NginxTimeout) {
+			readTimeout = svc.NginxTimeout
+		} else {
+			log.Warnw("invalid NGINX_TIMEOUT, expected e.g. 60s or 5m", "service", svc.Name, "value", svc.NginxTimeout)
+		}
+	}
+	maxBodySize := ""
+	if svc.NginxMaxBodySize != "" {
+		if nginxSizePattern.MatchString(svc.NginxMaxBodySize) {
+			maxBodySize = svc.NginxMaxBodySize
+		} else {
+			log.Warnw("invalid NGINX_MAX_BODY_SIZE, expected e.g. 100m or 1g", "service", svc.Name, "value", svc.NginxMaxBodySize)
+		}
+	}
+	rate, burst, rateLimited := parseRateLimit(svc.NginxRateLimit)
+	if svc.NginxRateLimit != "" && !rateLimited {
+		log.Warnw("invalid NGINX_RATE_LIMIT, expected e.g. 10r/s or 10r/s burst=20", "service", svc.Name, "value", svc.NginxRateLimit)
+	}
 
-		// Add basic auth if configured
+	log.Infow("adding proxy rule",
+		"service", svc.Name,
+		"hosts", svc.NginxHosts,
+		"path", proxyPath,
+		"upstream", fmt.Sprintf("%s:%d", dockerServiceName, port),
+		"websocket", svc.NginxWebSocket,
+		"basicAuth", svc.NginxBasicAuth != "",
+		"auth", svc.NginxAuth,
+		"stripPrefix", stripPrefix,
+		"timeout", readTimeout,
+		"maxBodySize", maxBodySize,
+		"sticky", svc.NginxSticky,
+		"retries", svc.NginxRetries,
+		"rateLimit", rate,
+	)
+
+	config.WriteString(fmt.Sprintf("    # %s\n", svc.Name))
+	config.WriteString(fmt.Sprintf("    location %s {\n", proxyPath))
+
+	// OIDC sign-in through EdgeAuth takes precedence over basic auth
+	switch {
+	case svc.NginxAuth == "oidc":
 		if svc.NginxBasicAuth != "" {
-			htpasswdPath := filepath.ToSlash(filepath.Join("/etc/nginx/auth", fmt.Sprintf("%s.htpasswd", strings.ToLower(svc.Name))))
-			config.WriteString(fmt.Sprintf("        auth_basic \"%s\";\n", svc.Name))
-			config.WriteString(fmt.Sprintf("        auth_basic_user_file %s;\n", htpasswdPath))
+			log.Warnw("NGINX_AUTH: oidc set, ignoring NGINX_BASIC_AUTH", "service", svc.Name)
 		}
+		writeEdgeAuthRequest(config)
+	case svc.NginxAuth != "":
+		log.Warnw("invalid NGINX_AUTH, expected oidc", "service", svc.Name, "value", svc.NginxAuth)
+	}
 
-		// Use variable-based proxy_pass so resolver is used at request time, not startup
-		config.WriteString(fmt.Sprintf("        set $%s_backend \"%s:%d\";\n", varName, dockerServiceName, port))
+	// Add basic auth if configured
+	if svc.NginxBasicAuth != "" && svc.NginxAuth != "oidc" {
+		htpasswdPath := filepath.ToSlash(filepath.Join("/etc/nginx/auth", fmt.Sprintf("%s.htpasswd", strings.ToLower(svc.Name))))
+		config.WriteString(fmt.Sprintf("        auth_basic \"%s\";\n", svc.Name))
+		config.WriteString(fmt.Sprintf("        auth_basic_user_file %s;\n", htpasswdPath))
+	}
 
-		// Strip prefix if enabled (default: true)
-		// Rewrites /path/foo -> /foo before proxying
-		if svc.NginxStripPrefix {
-			config.WriteString(fmt.Sprintf("        rewrite ^%s(.*)$ /$1 break;\n", strings.TrimSuffix(proxyPath, "/")))
-		}
+	if rateLimited {
+		config.WriteString(fmt.Sprintf("        limit_req zone=%s burst=%d nodelay;\n", rateLimitZoneName(svc), burst))
+		config.WriteString("        limit_req_status 429;\n")
+	}
+	if maxBodySize != "" {
+		config.WriteString(fmt.Sprintf("        client_max_body_size %s;\n", maxBodySize))
+	}
+
+	// Strip prefix if enabled (default: true)
+	// Rewrites /path/foo -> /foo before proxying
+	if stripPrefix {
+		config.WriteString(fmt.Sprintf("        rewrite ^%s(.*)$ /$1 break;\n", strings.TrimSuffix(proxyPath, "/")))
+	}
 
+	if usesUpstreamGroup(svc) {
+		config.WriteString(fmt.Sprintf("        proxy_pass http://%s;\n", upstreamGroupName(svc)))
+	} else {
+		// Use variable-based proxy_pass so resolver is used at request time, not startup
+		config.WriteString(fmt.Sprintf("        set $%s_backend \"%s:%d\";\n", varName, dockerServiceName, port))
 		config.WriteString(fmt.Sprintf("        proxy_pass http://$%s_backend;\n", varName))
-		config.WriteString("        proxy_http_version 1.1;\n")
-		config.WriteString("        proxy_set_header Host $host;\n")
-		config.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
-		config.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
-		config.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
-
-		// Handle upstream connection errors gracefully
-		config.WriteString("        proxy_connect_timeout 5s;\n")
+	}
+	config.WriteString("        proxy_http_version 1.1;\n")
+	config.WriteString("        proxy_set_header Host $host;\n")
+	config.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+
+	// Handle upstream connection errors gracefully
+	config.WriteString("        proxy_connect_timeout 5s;\n")
+	if svc.NginxRetries > 0 {
+		config.WriteString("        proxy_next_upstream error timeout http_502 http_503 http_504;\n")
+		config.WriteString(fmt.Sprintf("        proxy_next_upstream_tries %d;\n", svc.NginxRetries+1))
+	} else {
 		config.WriteString("        proxy_next_upstream error timeout;\n")
+	}
+	if readTimeout != "" {
+		config.WriteString(fmt.Sprintf("        proxy_read_timeout %s;\n", readTimeout))
+		config.WriteString(fmt.Sprintf("        proxy_send_timeout %s;\n", readTimeout))
+	}
 
-		// Rewrite absolute URLs in responses to include the proxy path prefix
-		// This fixes links like href="/settings" -> href="/certmate/settings"
-		if svc.NginxStripPrefix {
-			pathPrefix := strings.TrimSuffix(proxyPath, "/")
-			config.WriteString("        # Rewrite absolute URLs in responses\n")
-			config.WriteString("        sub_filter_once off;\n")
-			// text/html is included by default, don't duplicate it
-			config.WriteString("        sub_filter_types application/javascript text/css application/json;\n")
-			config.WriteString(fmt.Sprintf("        sub_filter 'href=\"/' 'href=\"%s/';\n", pathPrefix))
-			config.WriteString(fmt.Sprintf("        sub_filter 'src=\"/' 'src=\"%s/';\n", pathPrefix))
-			config.WriteString(fmt.Sprintf("        sub_filter 'action=\"/' 'action=\"%s/';\n", pathPrefix))
-			config.WriteString(fmt.Sprintf("        sub_filter 'url(/' 'url(%s/';\n", pathPrefix))
-			// Also handle redirects
-			config.WriteString("        proxy_redirect / " + proxyPath + ";\n")
-		}
+	// Rewrite absolute URLs in responses to include the proxy path prefix
+	// This fixes links like href="/settings" -> href="/certmate/settings"
+	if stripPrefix {
+		pathPrefix := strings.TrimSuffix(proxyPath, "/")
+		config.WriteString("        # Rewrite absolute URLs in responses\n")
+		config.WriteString("        sub_filter_once off;\n")
+		// text/html is included by default, don't duplicate it
+		config.WriteString("        sub_filter_types application/javascript text/css application/json;\n")
+		config.WriteString(fmt.Sprintf("        sub_filter 'href=\"/' 'href=\"%s/';\n", pathPrefix))
+		config.WriteString(fmt.Sprintf("        sub_filter 'src=\"/' 'src=\"%s/';\n", pathPrefix))
+		config.WriteString(fmt.Sprintf("        sub_filter 'action=\"/' 'action=\"%s/';\n", pathPrefix))
+		config.WriteString(fmt.Sprintf("        sub_filter 'url(/' 'url(%s/';\n", pathPrefix))
+		// Also handle redirects
+		config.WriteString("        proxy_redirect / " + proxyPath + ";\n")
+	}
 
-		if svc.NginxWebSocket {
-			config.WriteString("        proxy_set_header Upgrade $http_upgrade;\n")
-			config.WriteString("        proxy_set_header Connection \"upgrade\";\n")
-			config.WriteString("        proxy_read_timeout 86400;\n")
-		}
+	if svc.NginxWebSocket {
+		config.WriteString("        proxy_set_header Upgrade $http_upgrade;\n")
+		config.WriteString("        proxy_set_header Connection \"upgrade\";\n")
+	}
+
+	config.WriteString("    }\n\n")
+}
 
-		config.WriteString("    }\n\n")
+const (
+	// upstreamMaxFails and upstreamFailTimeout are the passive health check of
+	// upstream groups: a task is skipped for upstreamFailTimeout after
+	// upstreamMaxFails failed attempts within that time
+	upstreamMaxFails    = 3
+	upstreamFailTimeout = "30s"
+	// rateLimitZoneSize is the shared memory per limit_req_zone (about 160k client IPs)
+	rateLimitZoneSize = "10m"
+	// rateLimitZonesBegin and rateLimitZonesEnd delimit the managed block of
+	// limit_req_zone definitions in nginx.conf
+	rateLimitZonesBegin = "    # BEGIN dscotctl rate limit zones\n"
+	rateLimitZonesEnd   = "    # END dscotctl rate limit zones\n"
+)
+
+var (
+	// nginxTimePattern matches an Nginx time value (e.g. 60s, 5m, 500ms)
+	nginxTimePattern = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d)?$`)
+	// nginxSizePattern matches an Nginx size value (e.g. 100m, 1g)
+	nginxSizePattern = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+	// rateLimitPattern matches NGINX_RATE_LIMIT: a rate with an optional burst
+	rateLimitPattern = regexp.MustCompile(`^([0-9]+r/[sm])(\s+burst=([0-9]+))?$`)
+	// cookieNamePattern matches cookie names usable in an Nginx $cookie_ variable
+	cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	// httpBlockPattern matches the opening line of the http block in nginx.conf
+	httpBlockPattern = regexp.MustCompile(`(?m)^http\s*\{[ \t]*\n`)
+)
+
+// usesUpstreamGroup reports whether a service is proxied through an upstream
+// group of its tasks instead of the Docker service address. Sticky sessions
+// and retries need the individual tasks.
+func usesUpstreamGroup(svc ServiceMetadata) bool {
+	return svc.NginxSticky != "" || svc.NginxRetries > 0
+}
+
+// upstreamGroupName returns the name of a service's upstream group
+func upstreamGroupName(svc ServiceMetadata) string {
+	return strings.ToLower(strings.ReplaceAll(svc.Name, "-", "_")) + "_upstream"
+}
+
+// stickyHashKey returns the hash key for NGINX_STICKY: the client address for
+// "ip" and the cookie for "cookie:NAME"
+func stickyHashKey(sticky string) (string, bool) {
+	if sticky == "ip" {
+		return "$remote_addr", true
 	}
+	if name, ok := strings.CutPrefix(sticky, "cookie:"); ok && cookieNamePattern.MatchString(name) {
+		return "$cookie_" + name, true
+	}
+	return "", false
+}
 
-	// Default location - fallback for unmatched paths
-	config.WriteString("    # Default location - fallback for unmatched paths\n")
-	config.WriteString("    location / {\n")
-	config.WriteString("        return 200 \"Nginx EdgeLoadBalancer is running\\n\";\n")
-	config.WriteString("        add_header Content-Type text/plain;\n")
-	config.WriteString("    }\n")
+// writeUpstream writes the upstream group of a service. The servers are the
+// service's tasks (tasks.<service> in Docker DNS), re-resolved at runtime so
+// scaling is picked up and Nginx starts before the service does. Failing tasks
+// are taken out of rotation by the passive health check. Requires Nginx 1.27.3+.
+func writeUpstream(config *strings.Builder, svc ServiceMetadata) {
+	log := logging.L().With("component", "nginx")
 
-	config.WriteString("}\n")
+	port := svc.NginxPort
+	if port == 0 {
+		port = 80
+	}
+	name := upstreamGroupName(svc)
 
-	// Write the config file
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOFDEFAULT'\n%sEOFDEFAULT", defaultConfigPath, config.String())
-	if _, stderr, err := sshPool.Run(ctx, primaryMaster, writeCmd); err != nil {
-		return fmt.Errorf("failed to write default.conf: %w (stderr: %s)", err, stderr)
+	config.WriteString(fmt.Sprintf("# Upstream group for %s\n", svc.Name))
+	config.WriteString(fmt.Sprintf("upstream %s {\n", name))
+	config.WriteString(fmt.Sprintf("    zone %s 64k;\n", name))
+	config.WriteString("    resolver 127.0.0.11 valid=10s ipv6=off;\n")
+	if svc.NginxSticky != "" {
+		if key, ok := stickyHashKey(svc.NginxSticky); ok {
+			config.WriteString(fmt.Sprintf("    hash %s consistent;\n", key))
+		} else {
+			log.Warnw("invalid NGINX_STICKY, expected true or cookie:NAME", "service", svc.Name, "value", svc.NginxSticky)
+		}
 	}
+	config.WriteString(fmt.Sprintf("    server tasks.%s_%s:%d resolve max_fails=%d fail_timeout=%s;\n", svc.Name, svc.Name, port, upstreamMaxFails, upstreamFailTimeout))
+	config.WriteString("}\n\n")
+}
 
-	log.Infow("✅ generated Nginx default.conf with proxy rules", "file", defaultConfigPath, "services", len(proxyServices))
+// parseRateLimit parses NGINX_RATE_LIMIT ("10r/s" or "10r/s burst=20"). The
+// burst defaults to the number of requests in the rate.
+func parseRateLimit(value string) (rate string, burst int, ok bool) {
+	m := rateLimitPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return "", 0, false
+	}
+	rate = m[1]
+	if m[3] != "" {
+		burst, _ = strconv.Atoi(m[3])
+	} else {
+		burst, _ = strconv.Atoi(strings.SplitN(rate, "r/", 2)[0])
+	}
+	return rate, burst, true
+}
 
-	// Generate TCP stream configs for services with NGINX_TCP_STREAM
-	if err := generateTCPStreamConfigs(ctx, sshPool, primaryMaster, storagePath, services); err != nil {
-		log.Warnw("failed to generate TCP stream configs", "error", err)
+// rateLimitZoneName returns the limit_req_zone name of a service
+func rateLimitZoneName(svc ServiceMetadata) string {
+	return strings.ToLower(strings.ReplaceAll(svc.Name, "-", "_")) + "_ratelimit"
+}
+
+// renderRateLimitZones renders the managed nginx.conf block with a
+// limit_req_zone per enabled proxied service with a valid NGINX_RATE_LIMIT
+func renderRateLimitZones(services []ServiceMetadata) string {
+	var zones strings.Builder
+	zones.WriteString(rateLimitZonesBegin)
+	for _, svc := range services {
+		if !svc.Enabled || !svc.NginxProxy || IsEdgeLoadBalancerService(svc.Name) {
+			continue
+		}
+		if rate, _, ok := parseRateLimit(svc.NginxRateLimit); ok {
+			zones.WriteString(fmt.Sprintf("    limit_req_zone $binary_remote_addr zone=%s:%s rate=%s;\n", rateLimitZoneName(svc), rateLimitZoneSize, rate))
+		}
 	}
+	zones.WriteString(rateLimitZonesEnd)
+	return zones.String()
+}
 
-	return nil
+// withRateLimitZones replaces the managed rate limit block of an nginx.conf
+// with zones. A config without the block (created by an older version or
+// edited by hand) gets it at the top of the http block; everything else is
+// left as it is.
+func withRateLimitZones(nginxConf, zones string) string {
+	if begin := strings.Index(nginxConf, rateLimitZonesBegin); begin >= 0 {
+		if end := strings.Index(nginxConf[begin:], rateLimitZonesEnd); end >= 0 {
+			return nginxConf[:begin] + zones + nginxConf[begin+end+len(rateLimitZonesEnd):]
+		}
+	}
+	if loc := httpBlockPattern.FindStringIndex(nginxConf); loc != nil {
+		return nginxConf[:loc[1]] + zones + "\n" + nginxConf[loc[1]:]
+	}
+	return nginxConf
+}
+
+// writeEdgeAuthLocations writes the locations a server needs for OIDC
+// sign-in: the EdgeAuth endpoints under /oauth2/ and the internal auth
+// subrequest target. EdgeAuth is addressed through a variable so Nginx starts
+// without it; protected locations then answer 500 instead of being served.
+func writeEdgeAuthLocations(config *strings.Builder) {
+	backend := fmt.Sprintf("%s_%s:%d", EdgeAuthStackName, EdgeAuthStackName, edgeAuthPort)
+
+	config.WriteString("    # OIDC sign-in through EdgeAuth (oauth2-proxy)\n")
+	config.WriteString("    location /oauth2/ {\n")
+	config.WriteString(fmt.Sprintf("        set $edgeauth_backend \"%s\";\n", backend))
+	config.WriteString("        proxy_pass http://$edgeauth_backend;\n")
+	config.WriteString("        proxy_set_header Host $host;\n")
+	config.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Host $host;\n")
+	config.WriteString("        proxy_set_header X-Auth-Request-Redirect $request_uri;\n")
+	config.WriteString("    }\n\n")
+	config.WriteString("    location = /oauth2/auth {\n")
+	config.WriteString("        internal;\n")
+	config.WriteString(fmt.Sprintf("        set $edgeauth_backend \"%s\";\n", backend))
+	config.WriteString("        proxy_pass http://$edgeauth_backend;\n")
+	config.WriteString("        proxy_set_header Host $host;\n")
+	config.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Host $host;\n")
+	config.WriteString("        proxy_set_header X-Forwarded-Uri $request_uri;\n")
+	config.WriteString("        proxy_set_header Content-Length \"\";\n")
+	config.WriteString("        proxy_pass_request_body off;\n")
+	config.WriteString("    }\n\n")
+}
+
+// writeEdgeAuthRequest protects a location with EdgeAuth. Unauthenticated
+// requests are sent to the sign-in flow, which returns to the original URI;
+// the signed-in user is passed upstream in X-Auth-Request-User/Email.
+func writeEdgeAuthRequest(config *strings.Builder) {
+	config.WriteString("        auth_request /oauth2/auth;\n")
+	config.WriteString("        error_page 401 = /oauth2/sign_in;\n")
+	config.WriteString("        auth_request_set $auth_user $upstream_http_x_auth_request_user;\n")
+	config.WriteString("        auth_request_set $auth_email $upstream_http_x_auth_request_email;\n")
+	config.WriteString("        auth_request_set $auth_cookie $upstream_http_set_cookie;\n")
+	config.WriteString("        add_header Set-Cookie $auth_cookie;\n")
+	config.WriteString("        proxy_set_header X-Auth-Request-User $auth_user;\n")
+	config.WriteString("        proxy_set_header X-Auth-Request-Email $auth_email;\n")
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
-// generateTCPStreamConfigs generates Nginx stream configs for TCP/UDP proxying
+// renderTCPStreamConfig renders the Nginx stream config for TCP/UDP proxying,
+// or "" when no service uses NGINX_TCP_STREAM
 // NGINX_TCP_STREAM format: backend_port:nginx_port (e.g., 8000:9001)
-func generateTCPStreamConfigs(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, services []ServiceMetadata) error {
+func renderTCPStreamConfig(services []ServiceMetadata) string {
 	log := logging.L().With("component", "nginx")
 	var streamServices []ServiceMetadata
 	for _, svc := range services {
@@ -587,12 +983,9 @@ func generateTCPStreamConfigs(ctx context.Context, sshPool *ssh.Pool, primaryMas
 	}
 
 	if len(streamServices) == 0 {
-		return nil
+		return ""
 	}
 
-	streamPath := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir, "stream.d"))
-	streamConfigPath := filepath.ToSlash(filepath.Join(streamPath, "services-stream.conf"))
-
 	var streamConfig strings.Builder
 	streamConfig.WriteString("# TCP stream proxy rules - auto-generated by dscotctl\n")
 	streamConfig.WriteString("# Do not edit manually - regenerated on each deployment\n\n")
@@ -636,12 +1029,6 @@ func generateTCPStreamConfigs(ctx context.Context, sshPool *ssh.Pool, primaryMas
 
 	streamConfig.WriteString("}\n")
 
-	// Write the stream config file
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOFSTREAM'\n%sEOFSTREAM", streamConfigPath, streamConfig.String())
-	if _, stderr, err := sshPool.Run(ctx, primaryMaster, writeCmd); err != nil {
-		return fmt.Errorf("failed to write stream config: %w (stderr: %s)", err, stderr)
-	}
-
-	log.Infow("✅ generated Nginx TCP stream rules", "file", streamConfigPath, "services", len(streamServices))
-	return nil
+	log.Infow("✓ generated Nginx TCP stream rules", "services", len(streamServices))
+	return streamConfig.String()
 }
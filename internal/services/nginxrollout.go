@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"dscotctl/internal/defaults"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/retry"
+	"dscotctl/internal/ssh"
+)
+
+// nginxFallbackImage is used to test a config when the image of the running
+// EdgeLoadBalancer service cannot be determined.
+const nginxFallbackImage = "nginx:latest"
+
+// nginxBindMountedFiles are config files that the EdgeLoadBalancer mounts on
+// their own rather than through a directory. A bind mount of a single file
+// pins the inode it was created with, so these files are rewritten in place;
+// a rename would leave running tasks on the old file.
+var nginxBindMountedFiles = map[string]bool{
+	"nginx.conf": true,
+}
+
+// nginxConfigFile is a generated Nginx config file.
+type nginxConfigFile struct {
+	Path    string // Relative to the EdgeLoadBalancer conf directory (e.g. "conf.d/default.conf")
+	Content string
+}
+
+// applyNginxConfig rolls generated config files out to the EdgeLoadBalancer.
+// The conf directory is copied to a staging directory next to it, the files
+// are written there and the result is checked with `nginx -t` in a throwaway
+// container on the primary manager. Only a config that passes is moved into
+// place (a rename on the same filesystem, so Nginx never reads a partial
+// file; files in nginxBindMountedFiles are overwritten in place instead) and
+// the service is reloaded. The replaced files are kept as
+// <file>.prev and restored automatically if the reload or the /health check
+// of the running tasks fails.
+func applyNginxConfig(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, serviceName string, nodeHostnameToSSH map[string]string, files []nginxConfigFile) error {
+	log := logging.L().With("component", "nginx", "serviceName", serviceName)
+
+	confDir := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir, EdgeLoadBalancerConfDir))
+	stagingDir := confDir + ".staging"
+	defer sshPool.Run(ctx, primaryMaster, fmt.Sprintf("rm -rf %s", ssh.ShellQuote(stagingDir)))
+
+	// Stage the complete conf tree so the test sees exactly what Nginx will load
+	stageCmd := fmt.Sprintf("rm -rf %[2]s && cp -a %[1]s %[2]s", ssh.ShellQuote(confDir), ssh.ShellQuote(stagingDir))
+	if _, stderr, err := sshPool.Run(ctx, primaryMaster, stageCmd); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w (stderr: %s)", err, stderr)
+	}
+	for _, f := range files {
+		stagedPath := stagingDir + "/" + f.Path
+		if _, stderr, err := sshPool.Run(ctx, primaryMaster, fmt.Sprintf("mkdir -p %s", ssh.ShellQuote(filepath.ToSlash(filepath.Dir(stagedPath))))); err != nil {
+			return fmt.Errorf("failed to create staging directory for %s: %w (stderr: %s)", f.Path, err, stderr)
+		}
+		if err := sshPool.WriteFile(ctx, primaryMaster, stagedPath, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", f.Path, err)
+		}
+	}
+
+	if err := testNginxConfig(ctx, sshPool, primaryMaster, storagePath, stagingDir, serviceName); err != nil {
+		return err
+	}
+	log.Infow("✓ staged Nginx config passed nginx -t", "files", len(files))
+
+	// Swap the tested files in, keeping the current ones for a rollback
+	var swap, rollback []string
+	for _, f := range files {
+		live := ssh.ShellQuote(confDir + "/" + f.Path)
+		prev := ssh.ShellQuote(confDir + "/" + f.Path + ".prev")
+		staged := ssh.ShellQuote(stagingDir + "/" + f.Path)
+		if nginxBindMountedFiles[f.Path] {
+			swap = append(swap, fmt.Sprintf("if [ -f %[1]s ]; then cp -p %[1]s %[2]s; else rm -f %[2]s; fi && cat %[3]s > %[1]s", live, prev, staged))
+			rollback = append(rollback, fmt.Sprintf("if [ -f %[2]s ]; then cat %[2]s > %[1]s && rm -f %[2]s; fi", live, prev))
+			continue
+		}
+		swap = append(swap, fmt.Sprintf("if [ -f %[1]s ]; then cp -p %[1]s %[2]s; else rm -f %[2]s; fi && mv -f %[3]s %[1]s", live, prev, staged))
+		rollback = append(rollback, fmt.Sprintf("if [ -f %[2]s ]; then mv -f %[2]s %[1]s; else rm -f %[1]s; fi", live, prev))
+	}
+	if _, stderr, err := sshPool.Run(ctx, primaryMaster, strings.Join(swap, " && ")); err != nil {
+		// A partial swap is undone the same way as a failed reload
+		sshPool.Run(ctx, primaryMaster, strings.Join(rollback, "; "))
+		return fmt.Errorf("failed to move Nginx config into place: %w (stderr: %s)", err, stderr)
+	}
+
+	// Without running tasks (e.g. no node labelled yet) there is nothing to check
+	tasks, err := runningNginxTasks(ctx, sshPool, primaryMaster, serviceName)
+	checkHealth := err != nil || len(tasks) > 0
+	if !checkHealth {
+		log.Infow("no running Nginx tasks, skipping /health check")
+	}
+
+	applyErr := ReloadNginx(ctx, sshPool, primaryMaster, serviceName)
+	if applyErr == nil && checkHealth {
+		applyErr = waitForNginxHealth(ctx, sshPool, primaryMaster, serviceName, nodeHostnameToSSH)
+	}
+	if applyErr == nil {
+		log.Infow("✅ Nginx config rolled out", "files", len(files))
+		return nil
+	}
+
+	log.Warnw("⚠ Nginx unhealthy with new config, restoring previous config", "error", applyErr)
+	if _, stderr, err := sshPool.Run(ctx, primaryMaster, strings.Join(rollback, "; ")); err != nil {
+		return fmt.Errorf("Nginx unhealthy with new config (%v) and failed to restore previous config: %w (stderr: %s)", applyErr, err, stderr)
+	}
+	if err := ReloadNginx(ctx, sshPool, primaryMaster, serviceName); err != nil {
+		return fmt.Errorf("Nginx unhealthy with new config (%v) and failed to reload previous config: %w", applyErr, err)
+	}
+	if err := waitForNginxHealth(ctx, sshPool, primaryMaster, serviceName, nodeHostnameToSSH); err != nil {
+		return fmt.Errorf("Nginx unhealthy with new config (%v) and still unhealthy after restoring previous config: %w", applyErr, err)
+	}
+	return fmt.Errorf("Nginx unhealthy with new config, previous config restored: %w", applyErr)
+}
+
+// testNginxConfig runs `nginx -t` against a staged conf directory in a
+// throwaway container with the image and mounts of the EdgeLoadBalancer. The
+// container joins the internal network so upstream names resolve as they do
+// in the service.
+func testNginxConfig(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, storagePath string, stagingDir string, serviceName string) error {
+	image := nginxFallbackImage
+	inspectCmd := fmt.Sprintf("docker service inspect --format '{{.Spec.TaskTemplate.ContainerSpec.Image}}' %s", ssh.ShellQuote(serviceName))
+	if stdout, _, err := sshPool.Run(ctx, primaryMaster, inspectCmd); err == nil && strings.TrimSpace(stdout) != "" {
+		image = strings.TrimSpace(stdout)
+	}
+
+	dataDir := filepath.ToSlash(filepath.Join(storagePath, "data", EdgeLoadBalancerDataDir))
+	mounts := []struct{ source, target string }{
+		{stagingDir + "/nginx.conf", "/etc/nginx/nginx.conf"},
+		{stagingDir + "/conf.d", "/etc/nginx/conf.d"},
+		{stagingDir + "/sites-enabled", "/etc/nginx/sites-enabled"},
+		{stagingDir + "/stream.d", "/etc/nginx/stream.d"},
+		{stagingDir + "/auth", "/etc/nginx/auth"},
+		{dataDir + "/ssl", "/etc/nginx/ssl"},
+	}
+	var testCmd strings.Builder
+	fmt.Fprintf(&testCmd, "docker run --rm --network %s", ssh.ShellQuote(defaults.InternalNetworkName))
+	for _, m := range mounts {
+		fmt.Fprintf(&testCmd, " -v %s", ssh.ShellQuote(m.source+":"+m.target+":ro"))
+	}
+	fmt.Fprintf(&testCmd, " %s nginx -t 2>&1", ssh.ShellQuote(image))
+
+	stdout, stderr, err := sshPool.Run(ctx, primaryMaster, testCmd.String())
+	if err != nil {
+		return fmt.Errorf("generated Nginx config failed validation, live config left unchanged: %w (output: %s%s)", err, strings.TrimSpace(stdout), strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// nginxTask is a running EdgeLoadBalancer task.
+type nginxTask struct {
+	Node  string // Swarm hostname of the node
+	State string // Current state (e.g. "Running 5 seconds ago")
+}
+
+// runningNginxTasks lists the EdgeLoadBalancer tasks whose desired state is running.
+func runningNginxTasks(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, serviceName string) ([]nginxTask, error) {
+	psCmd := fmt.Sprintf("docker service ps %s --filter desired-state=running --format '{{.Node}}|{{.CurrentState}}'", ssh.ShellQuote(serviceName))
+	stdout, stderr, err := sshPool.Run(ctx, primaryMaster, psCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Nginx tasks: %w (stderr: %s)", err, stderr)
+	}
+
+	var tasks []nginxTask
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		node, state, ok := strings.Cut(strings.TrimSpace(line), "|")
+		if ok {
+			tasks = append(tasks, nginxTask{Node: node, State: state})
+		}
+	}
+	return tasks, nil
+}
+
+// waitForNginxHealth waits until every running EdgeLoadBalancer task answers
+// /health on its node. Tasks are mapped to SSH addresses through
+// nodeHostnameToSSH; unmapped nodes are reached by their Swarm hostname.
+func waitForNginxHealth(ctx context.Context, sshPool *ssh.Pool, primaryMaster string, serviceName string, nodeHostnameToSSH map[string]string) error {
+	return retry.Do(ctx, retry.DefaultConfig("nginx-health"), func() error {
+		tasks, err := runningNginxTasks(ctx, sshPool, primaryMaster, serviceName)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return fmt.Errorf("no running Nginx tasks")
+		}
+
+		for _, task := range tasks {
+			if !strings.HasPrefix(task.State, "Running") {
+				return fmt.Errorf("Nginx task on %s is %s", task.Node, task.State)
+			}
+			host := task.Node
+			if sshHost, ok := nodeHostnameToSSH[task.Node]; ok {
+				host = sshHost
+			}
+			if _, stderr, err := sshPool.Run(ctx, host, "curl -fsS -o /dev/null --max-time 5 http://127.0.0.1/health"); err != nil {
+				return fmt.Errorf("Nginx on %s failed /health: %w (stderr: %s)", task.Node, err, strings.TrimSpace(stderr))
+			}
+		}
+		return nil
+	})
+}
@@ -27,13 +27,22 @@ type ServiceMetadata struct {
 	FilePath    string
 	FileName    string
 	// Nginx proxy configuration (parsed from headers)
-	NginxProxy       bool   // NGINX_PROXY: true/false - whether to create a reverse proxy rule
-	NginxPath        string // NGINX_PATH: /path - URL path for the proxy (defaults to /ServiceName)
-	NginxPort        int    // NGINX_PORT: 8080 - internal port the service listens on
-	NginxWebSocket   bool   // NGINX_WEBSOCKET: true/false - enable WebSocket support
-	NginxTCPStream   string // NGINX_TCP_STREAM: backend_port:nginx_port - TCP stream proxy (e.g., 8000:9001)
-	NginxBasicAuth   string // NGINX_BASIC_AUTH: user:pass - enable basic auth with these credentials
-	NginxStripPrefix bool   // NGINX_STRIP_PREFIX: true/false - strip location prefix before proxying (default: true)
+	NginxProxy       bool     // NGINX_PROXY: true/false - whether to create a reverse proxy rule
+	NginxPath        string   // NGINX_PATH: /path - URL path for the proxy (defaults to /ServiceName, or / with NGINX_HOST)
+	NginxHosts       []string // NGINX_HOST: app.example.com - serve the service on its own virtual host (repeatable)
+	NginxPort        int      // NGINX_PORT: 8080 - internal port the service listens on
+	NginxWebSocket   bool     // NGINX_WEBSOCKET: true/false - enable WebSocket support
+	NginxTCPStream   string   // NGINX_TCP_STREAM: backend_port:nginx_port - TCP stream proxy (e.g., 8000:9001)
+	NginxBasicAuth   string   // NGINX_BASIC_AUTH: user:pass - enable basic auth with these credentials
+	NginxAuth        string   // NGINX_AUTH: oidc - require sign-in through the EdgeAuth companion (globalSettings.edgeAuth)
+	NginxStripPrefix bool     // NGINX_STRIP_PREFIX: true/false - strip location prefix before proxying (default: true)
+	NginxTimeout     string   // NGINX_TIMEOUT: 60s - proxy read/send timeout
+	NginxMaxBodySize string   // NGINX_MAX_BODY_SIZE: 100m - maximum request body size
+	NginxSticky      string   // NGINX_STICKY: true or cookie:NAME - pin clients to one task by IP ("ip") or by cookie
+	NginxRetries     int      // NGINX_RETRIES: 2 - retry failed requests on other tasks, with passive health checks
+	NginxRateLimit   string   // NGINX_RATE_LIMIT: 10r/s [burst=20] - limit requests per client IP
+	// Storage configuration
+	StorageClass string // STORAGE_CLASS: rbd - put the stack's named volumes on block storage (default: CephFS bind mounts)
 	// Portainer-specific configuration
 	PortainerAdminPassword string // PORTAINER_ADMIN_PASSWORD: password - sets initial admin password (bcrypted at runtime)
 	// ProcessedContent holds the post-processed YAML content after variable replacement
@@ -60,11 +69,14 @@ type ClusterInfo struct {
 	DistributedStorageEnabled bool              // true if distributed storage is enabled (shared across nodes)
 	PrimaryMaster             string            // primary master node SSH address
 	DockerManagerHost         string            // hostname/IP for Docker API on primary manager (for Portainer etc.)
+	BlockVolumeDriver         string            // Docker volume driver for STORAGE_CLASS: rbd (empty if RBD is disabled)
 	S3CredentialsFile         string            // path to S3 credentials file (if RGW enabled)
 	RadosGatewayPort          int               // RADOS Gateway port (if RGW enabled)
 	KeepalivedVIP             string            // virtual IP address if keepalived enabled (empty if not)
 	PortainerEnabled          bool              // true if Portainer service is deployed
 	NodeHostnameToSSH         map[string]string // Docker Swarm hostname -> SSH address mapping
+	ACME                      *ACMEOptions      // ACME certificate issuance for NGINX_HOST virtual hosts (nil if disabled)
+	EdgeAuth                  *EdgeAuthOptions  // OIDC authentication for services with NGINX_AUTH: oidc (nil if disabled)
 }
 
 const (
@@ -171,6 +183,14 @@ func parseServiceMetadata(filePath, fileName string) (ServiceMetadata, error) {
 			metadata.NginxProxy = strings.ToLower(proxyStr) == "true"
 		} else if strings.HasPrefix(line, "NGINX_PATH:") {
 			metadata.NginxPath = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_PATH:"))
+		} else if strings.HasPrefix(line, "NGINX_HOST:") {
+			// Several hosts may be given on one line or on repeated lines
+			hosts := strings.FieldsFunc(strings.TrimPrefix(line, "NGINX_HOST:"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			for _, host := range hosts {
+				metadata.NginxHosts = append(metadata.NginxHosts, strings.ToLower(host))
+			}
 		} else if strings.HasPrefix(line, "NGINX_PORT:") {
 			portStr := strings.TrimSpace(strings.TrimPrefix(line, "NGINX_PORT:"))
 			if port, err := strconv.Atoi(portStr); err == nil {
@@ -183,10 +203,35 @@ func parseServiceMetadata(filePath, fileName string) (ServiceMetadata, error) {
 			metadata.NginxTCPStream = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_TCP_STREAM:"))
 		} else if strings.HasPrefix(line, "NGINX_BASIC_AUTH:") {
 			metadata.NginxBasicAuth = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_BASIC_AUTH:"))
+		} else if strings.HasPrefix(line, "NGINX_AUTH:") {
+			metadata.NginxAuth = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "NGINX_AUTH:")))
 		} else if strings.HasPrefix(line, "NGINX_STRIP_PREFIX:") {
 			stripStr := strings.TrimSpace(strings.TrimPrefix(line, "NGINX_STRIP_PREFIX:"))
 			// Default is true, so only set false if explicitly "false"
 			metadata.NginxStripPrefix = strings.ToLower(stripStr) != "false"
+		} else if strings.HasPrefix(line, "NGINX_TIMEOUT:") {
+			metadata.NginxTimeout = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_TIMEOUT:"))
+		} else if strings.HasPrefix(line, "NGINX_MAX_BODY_SIZE:") {
+			metadata.NginxMaxBodySize = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_MAX_BODY_SIZE:"))
+		} else if strings.HasPrefix(line, "NGINX_STICKY:") {
+			stickyStr := strings.TrimSpace(strings.TrimPrefix(line, "NGINX_STICKY:"))
+			switch strings.ToLower(stickyStr) {
+			case "", "false":
+				metadata.NginxSticky = ""
+			case "true", "ip":
+				metadata.NginxSticky = "ip"
+			default:
+				metadata.NginxSticky = stickyStr
+			}
+		} else if strings.HasPrefix(line, "NGINX_RETRIES:") {
+			retriesStr := strings.TrimSpace(strings.TrimPrefix(line, "NGINX_RETRIES:"))
+			if retries, err := strconv.Atoi(retriesStr); err == nil && retries >= 0 {
+				metadata.NginxRetries = retries
+			}
+		} else if strings.HasPrefix(line, "NGINX_RATE_LIMIT:") {
+			metadata.NginxRateLimit = strings.TrimSpace(strings.TrimPrefix(line, "NGINX_RATE_LIMIT:"))
+		} else if strings.HasPrefix(line, "STORAGE_CLASS:") {
+			metadata.StorageClass = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "STORAGE_CLASS:")))
 		} else if strings.HasPrefix(line, "PORTAINER_ADMIN_PASSWORD:") {
 			metadata.PortainerAdminPassword = strings.TrimSpace(strings.TrimPrefix(line, "PORTAINER_ADMIN_PASSWORD:"))
 		}
@@ -197,9 +242,14 @@ func parseServiceMetadata(filePath, fileName string) (ServiceMetadata, error) {
 		metadata.Name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
 	}
 
-	// Default NginxPath to /ServiceName if proxy is enabled but no path specified
+	// Default NginxPath to /ServiceName if proxy is enabled but no path specified.
+	// A service on its own virtual host is served from the root instead.
 	if metadata.NginxProxy && metadata.NginxPath == "" {
-		metadata.NginxPath = "/" + strings.ToLower(metadata.Name)
+		if len(metadata.NginxHosts) > 0 {
+			metadata.NginxPath = "/"
+		} else {
+			metadata.NginxPath = "/" + strings.ToLower(metadata.Name)
+		}
 	}
 
 	// Default NginxStripPrefix to true if proxy is enabled and not explicitly set
@@ -289,7 +339,7 @@ func DeployServices(ctx context.Context, sshPool *ssh.Pool, primaryMaster string
 	var nginxConfig *NginxConfig
 	if IsEdgeLoadBalancerEnabled(services) {
 		log.Infow("EdgeLoadBalancer service detected, preparing deployment")
-		nginxConfig, err = PrepareEdgeLoadBalancerDeployment(ctx, sshPool, primaryMaster, storageMountPath)
+		nginxConfig, err = PrepareEdgeLoadBalancerDeployment(ctx, sshPool, primaryMaster, storageMountPath, services)
 		if err != nil {
 			log.Warnw("failed to prepare EdgeLoadBalancer deployment", "error", err)
 			// Continue anyway - Nginx may work with defaults
@@ -361,13 +411,20 @@ func DeployServices(ctx context.Context, sshPool *ssh.Pool, primaryMaster string
 			"storagePath", nginxConfig.StoragePath,
 		)
 
-		// Generate proxy rules for all services with NGINX_PROXY: true
-		if err := GenerateProxyRulesForServices(ctx, sshPool, primaryMaster, storageMountPath, services); err != nil {
-			log.Warnw("failed to generate Nginx proxy rules", "error", err)
-		} else {
-			// Reload Nginx to apply new proxy rules
-			if err := ReloadNginx(ctx, sshPool, primaryMaster, nginxConfig.ServiceName); err != nil {
-				log.Warnw("failed to reload Nginx after proxy rule generation", "error", err)
+		// Deploy the OIDC companion before the protected locations point at it
+		if err := ReconcileEdgeAuth(ctx, sshPool, primaryMaster, storageMountPath, services, clusterInfo.EdgeAuth); err != nil {
+			log.Warnw("failed to reconcile EdgeAuth", "error", err)
+		}
+
+		// Generate proxy rules for all services with NGINX_PROXY: true, validate and roll them out
+		if err := GenerateProxyRulesForServices(ctx, sshPool, primaryMaster, storageMountPath, services, nginxConfig.ServiceName, clusterInfo.NodeHostnameToSSH); err != nil {
+			log.Warnw("failed to roll out Nginx proxy rules", "error", err)
+		}
+
+		// Obtain certificates once Nginx serves the ACME challenges, then switch the virtual hosts over to them
+		if clusterInfo.ACME != nil {
+			if err := RenewCertificates(ctx, sshPool, primaryMaster, storageMountPath, services, nginxConfig.ServiceName, clusterInfo.NodeHostnameToSSH, *clusterInfo.ACME); err != nil {
+				log.Warnw("failed to obtain ACME certificates", "error", err)
 			}
 		}
 
@@ -449,12 +506,21 @@ func deployService(ctx context.Context, sshPool *ssh.Pool, primaryMaster string,
 		}
 	}
 
+	// Apply the storage class after saving so the file keeps plain named volumes
+	// and follows later changes to the RBD setting
+	var storageClassDirs []string
+	if svc.StorageClass != "" {
+		processedContent, storageClassDirs = applyStorageClass(processedContent, svc.Name, svc.StorageClass, storageMountPath, clusterInfo.BlockVolumeDriver)
+		log.Infow("applied storage class to named volumes", "storageClass", svc.StorageClass, "blockVolumeDriver", clusterInfo.BlockVolumeDriver)
+	}
+
 	// Parse bind mounts from the processed content and create directories
 	// Storage paths: create on one node if distributed storage, else all nodes
 	// Local paths: always create on all nodes (node-local directories like /var/lib/nginx)
 	if len(clusterInfo.AllNodes) > 0 {
 
 		bindMounts := parseBindMounts(processedContent, storageMountPath)
+		bindMounts.StoragePaths = append(bindMounts.StoragePaths, storageClassDirs...)
 
 		totalDirs := len(bindMounts.StoragePaths) + len(bindMounts.LocalPaths)
 		log.Infow("📂 bind mount analysis complete",
@@ -505,13 +571,10 @@ func deployService(ctx context.Context, sshPool *ssh.Pool, primaryMaster string,
 	// Create temporary file on remote host
 	remoteFile := fmt.Sprintf("/tmp/dscotctl-service-%s.yml", svc.Name)
 
-	// Write content to remote file
-	writeCmd := fmt.Sprintf("cat > %s << 'DSCOTCTL_EOF'\n%s\nDSCOTCTL_EOF", remoteFile, processedContent)
-
 	log.Infow("uploading service definition", "host", primaryMaster, "remoteFile", remoteFile, "size", len(processedContent))
 
-	if _, stderr, err := sshPool.Run(ctx, primaryMaster, writeCmd); err != nil {
-		return fmt.Errorf("failed to upload service file: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, primaryMaster, remoteFile, []byte(processedContent), 0644); err != nil {
+		return fmt.Errorf("failed to upload service file: %w", err)
 	}
 
 	// Deploy using docker stack deploy with --prune to remove orphaned services
@@ -647,6 +710,105 @@ func replaceStoragePaths(content string, storageMountPath string) string {
 	return re.ReplaceAllString(result, storageMountPath)
 }
 
+// namedVolumePattern matches a top-level named volume entry without options,
+// e.g. "  postgres:" or "  postgres: {}".
+var namedVolumePattern = regexp.MustCompile(`^(\s+)([A-Za-z0-9_.-]+):\s*(\{\s*\})?\s*$`)
+
+// applyStorageClass assigns the stack's top-level named volumes to a storage
+// class. For "rbd", named volumes without options use blockDriver. Without a
+// block driver they fall back to a bind volume under storageMountPath
+// (<mount>/data/<service>/<volume>) so data still follows rescheduled tasks.
+// Volumes that already declare options are left alone.
+// Returns the modified content and the fallback directories to create.
+func applyStorageClass(content, serviceName, storageClass, storageMountPath, blockDriver string) (string, []string) {
+	log := logging.L().With("component", "services", "service", serviceName)
+
+	switch storageClass {
+	case "rbd":
+	case "cephfs", "":
+		return content, nil
+	default:
+		log.Warnw("unknown STORAGE_CLASS, using default storage", "storageClass", storageClass)
+		return content, nil
+	}
+
+	if blockDriver == "" && storageMountPath == "" {
+		log.Warnw("STORAGE_CLASS: rbd requested but neither RBD nor distributed storage is enabled; named volumes stay node-local")
+		return content, nil
+	}
+	if blockDriver == "" {
+		log.Warnw("STORAGE_CLASS: rbd requested but RBD is not enabled; named volumes use the shared filesystem")
+	}
+
+	lines := strings.Split(content, "\n")
+	var out []string
+	var dirs []string
+	inVolumes := false
+	entryIndent := ""
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if !inVolumes {
+			out = append(out, line)
+			if strings.TrimRight(line, " \t\r") == "volumes:" {
+				inVolumes = true
+			}
+			continue
+		}
+
+		// A non-indented line ends the top-level volumes section
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && line[0] != ' ' && line[0] != '\t' {
+			inVolumes = false
+			out = append(out, line)
+			continue
+		}
+
+		m := namedVolumePattern.FindStringSubmatch(line)
+		if m == nil {
+			out = append(out, line)
+			continue
+		}
+		if entryIndent == "" {
+			entryIndent = m[1]
+		}
+		if m[1] != entryIndent || hasIndentedChild(lines, i, len(entryIndent)) {
+			out = append(out, line)
+			continue
+		}
+
+		name := m[2]
+		out = append(out, entryIndent+name+":")
+		if blockDriver != "" {
+			out = append(out, entryIndent+"  driver: "+blockDriver)
+			continue
+		}
+		device := strings.TrimSuffix(storageMountPath, "/") + "/" + defaults.ServiceDataSubdir + "/" + serviceName + "/" + name
+		out = append(out,
+			entryIndent+"  driver: local",
+			entryIndent+"  driver_opts:",
+			entryIndent+"    type: none",
+			entryIndent+"    o: bind",
+			entryIndent+"    device: "+device,
+		)
+		dirs = append(dirs, device)
+	}
+
+	return strings.Join(out, "\n"), dirs
+}
+
+// hasIndentedChild reports whether the next non-blank line after lines[i] is
+// indented deeper than indent, i.e. the entry at lines[i] has options.
+func hasIndentedChild(lines []string, i, indent int) bool {
+	for _, next := range lines[i+1:] {
+		if strings.TrimSpace(next) == "" {
+			continue
+		}
+		return len(next)-len(strings.TrimLeft(next, " \t")) > indent
+	}
+	return false
+}
+
 // BindMountPaths contains categorized bind mount paths from service definitions.
 type BindMountPaths struct {
 	// StoragePaths are paths under the storage mount path (shared/distributed storage)
@@ -945,9 +1107,6 @@ func uploadServiceDefinitions(ctx context.Context, sshPool *ssh.Pool, services [
 			// Build remote file path
 			remoteFile := fmt.Sprintf("%s/%s", destDir, svc.FileName)
 
-			// Write content to remote file using heredoc
-			writeCmd := fmt.Sprintf("cat > '%s' << 'DSCOTCTL_EOF'\n%s\nDSCOTCTL_EOF", remoteFile, content)
-
 			log.Infow("uploading service definition",
 				"service", svc.Name,
 				"host", node,
@@ -955,8 +1114,8 @@ func uploadServiceDefinitions(ctx context.Context, sshPool *ssh.Pool, services [
 				"size", len(content),
 			)
 
-			if _, stderr, err := sshPool.Run(ctx, node, writeCmd); err != nil {
-				log.Warnw("failed to upload service definition", "service", svc.Name, "host", node, "error", err, "stderr", stderr)
+			if err := sshPool.WriteFile(ctx, node, remoteFile, []byte(content), 0644); err != nil {
+				log.Warnw("failed to upload service definition", "service", svc.Name, "host", node, "error", err)
 				errors = append(errors, fmt.Sprintf("%s/%s: %v", node, svc.Name, err))
 			} else {
 				log.Infow("✅ service definition uploaded", "service", svc.Name, "host", node, "remotePath", remoteFile)
@@ -1296,10 +1455,8 @@ export NODE_HOSTNAME='%s'
 	remoteScript := fmt.Sprintf("/tmp/dscotctl-%s", scriptName)
 	scriptStr := strings.ReplaceAll(string(scriptContent), "\r\n", "\n")
 	scriptStr = strings.ReplaceAll(scriptStr, "\r", "\n") // Handle any standalone CR
-	uploadCmd := fmt.Sprintf("cat > %s << 'DSCOTCTL_SCRIPT_EOF'\n%s\nDSCOTCTL_SCRIPT_EOF", remoteScript, scriptStr)
-
-	if _, stderr, err := sshPool.Run(ctx, targetNode, uploadCmd); err != nil {
-		return fmt.Errorf("failed to upload script: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, targetNode, remoteScript, []byte(scriptStr), 0755); err != nil {
+		return fmt.Errorf("failed to upload script: %w", err)
 	}
 
 	// Make script executable and run it with environment variables
@@ -1,8 +1,13 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -169,3 +174,285 @@ func TestParseBindMountsRegexDetails(t *testing.T) {
 	}
 }
 
+
+func TestApplyStorageClass(t *testing.T) {
+	content := `services:
+  DB:
+    image: postgres:16-alpine
+    volumes:
+      - pgdata:/var/lib/postgresql/data:rw
+
+volumes:
+  pgdata:
+  cache: {}
+  external:
+    external: true
+
+networks:
+  INTERNAL:
+    external: true
+`
+	storageMountPath := "/mnt/MicroCephFS/docker-swarm-0001"
+
+	// RBD enabled: empty named volumes use the block driver
+	result, dirs := applyStorageClass(content, "DB", "rbd", storageMountPath, "rbd")
+	if !strings.Contains(result, "  pgdata:\n    driver: rbd\n") {
+		t.Errorf("Expected pgdata to use the rbd driver, got:\n%s", result)
+	}
+	if !strings.Contains(result, "  cache:\n    driver: rbd\n") {
+		t.Errorf("Expected cache to use the rbd driver, got:\n%s", result)
+	}
+	if !strings.Contains(result, "  external:\n    external: true\n") {
+		t.Errorf("Expected volume with options to be unchanged, got:\n%s", result)
+	}
+	if strings.Contains(result, "INTERNAL:\n    driver") {
+		t.Errorf("Expected networks section to be unchanged, got:\n%s", result)
+	}
+	if len(dirs) != 0 {
+		t.Errorf("Expected no fallback directories with RBD, got %v", dirs)
+	}
+
+	// RBD disabled: fall back to a bind volume on the shared filesystem
+	result, dirs = applyStorageClass(content, "DB", "rbd", storageMountPath, "")
+	expectedDevice := storageMountPath + "/data/DB/pgdata"
+	if !strings.Contains(result, "      device: "+expectedDevice+"\n") {
+		t.Errorf("Expected pgdata to bind %s, got:\n%s", expectedDevice, result)
+	}
+	if len(dirs) != 2 || dirs[0] != expectedDevice {
+		t.Errorf("Expected fallback directories for pgdata and cache, got %v", dirs)
+	}
+
+	// No storage at all: content unchanged
+	result, dirs = applyStorageClass(content, "DB", "rbd", "", "")
+	if result != content || len(dirs) != 0 {
+		t.Errorf("Expected content unchanged without storage")
+	}
+
+	// Unknown class: content unchanged
+	result, _ = applyStorageClass(content, "DB", "nvme", storageMountPath, "rbd")
+	if result != content {
+		t.Errorf("Expected content unchanged for unknown storage class")
+	}
+}
+
+func TestRenderProxyConfigVirtualHosts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "010-N8N.yml")
+	content := "# NAME: N8N\n# NGINX_PROXY: true\n# NGINX_HOST: n8n.example.com, automation.example.com\n# NGINX_PORT: 5678\nservices: {}\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n8n, err := parseServiceMetadata(file, "010-N8N.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n8n.NginxHosts) != 2 || n8n.NginxPath != "/" {
+		t.Fatalf("Expected two hosts served from /, got hosts %v path %q", n8n.NginxHosts, n8n.NginxPath)
+	}
+
+	certmate := ServiceMetadata{Name: "Certmate", Enabled: true, NginxProxy: true, NginxPath: "/certmate", NginxStripPrefix: true}
+	conf := renderProxyConfig([]ServiceMetadata{certmate, n8n}, map[string]bool{"n8n.example.com": true})
+
+	if !strings.Contains(conf, "server_name n8n.example.com;\n") || !strings.Contains(conf, "server_name automation.example.com;\n") {
+		t.Errorf("Expected a server block per host, got:\n%s", conf)
+	}
+	if !strings.Contains(conf, "ssl_certificate /etc/nginx/ssl/n8n.example.com/fullchain.pem;") {
+		t.Errorf("Expected the host certificate for n8n.example.com, got:\n%s", conf)
+	}
+	if strings.Count(conf, "ssl_certificate /etc/nginx/ssl/default.crt;") != 2 {
+		t.Errorf("Expected the default certificate for the default server and automation.example.com, got:\n%s", conf)
+	}
+	if !strings.Contains(conf, "location /certmate/ {") {
+		t.Errorf("Expected path-based rule for Certmate, got:\n%s", conf)
+	}
+	if strings.Contains(conf, "rewrite ^(.*)$") {
+		t.Errorf("Expected no prefix rewrite on the root location, got:\n%s", conf)
+	}
+	defaultServer := conf[:strings.Index(conf, "# HTTPS server for")]
+	if strings.Contains(defaultServer, "N8N_N8N") {
+		t.Errorf("Expected N8N only on its virtual hosts, got:\n%s", defaultServer)
+	}
+}
+
+func TestRenderTCPStreamConfig(t *testing.T) {
+	if conf := renderTCPStreamConfig([]ServiceMetadata{{Name: "N8N", Enabled: true}}); conf != "" {
+		t.Errorf("Expected no stream config without NGINX_TCP_STREAM, got:\n%s", conf)
+	}
+
+	services := []ServiceMetadata{
+		{Name: "Portainer", Enabled: true, NginxTCPStream: "8000:9001"},
+		{Name: "Broken", Enabled: true, NginxTCPStream: "8000"},
+		{Name: "Disabled", Enabled: false, NginxTCPStream: "5432:5432"},
+	}
+	conf := renderTCPStreamConfig(services)
+	if !strings.Contains(conf, "server Portainer_Portainer:8000;") || !strings.Contains(conf, "listen 9001;") {
+		t.Errorf("Expected a stream rule for Portainer, got:\n%s", conf)
+	}
+	if strings.Contains(conf, "Broken") || strings.Contains(conf, "Disabled") {
+		t.Errorf("Expected invalid and disabled services to be skipped, got:\n%s", conf)
+	}
+}
+
+func TestProxyLoadBalancingHeaders(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "020-Api.yml")
+	content := "# NAME: Api\n# NGINX_PROXY: true\n# NGINX_PORT: 8080\n# NGINX_TIMEOUT: 120s\n# NGINX_MAX_BODY_SIZE: 100m\n# NGINX_STICKY: cookie:SESSION\n# NGINX_RETRIES: 2\n# NGINX_RATE_LIMIT: 10r/s burst=20\nservices: {}\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api, err := parseServiceMetadata(file, "020-Api.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Enabled = true
+	plain := ServiceMetadata{Name: "Web", Enabled: true, NginxProxy: true, NginxPath: "/web", NginxWebSocket: true, NginxTimeout: "forever"}
+
+	conf := renderProxyConfig([]ServiceMetadata{api, plain}, nil)
+	for _, want := range []string{
+		"upstream api_upstream {",
+		"hash $cookie_SESSION consistent;",
+		"server tasks.Api_Api:8080 resolve max_fails=3 fail_timeout=30s;",
+		"proxy_pass http://api_upstream;",
+		"proxy_next_upstream_tries 3;",
+		"proxy_read_timeout 120s;",
+		"client_max_body_size 100m;",
+		"limit_req zone=api_ratelimit burst=20 nodelay;",
+		"proxy_pass http://$web_backend;",
+		"proxy_read_timeout 86400;",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("Expected %q in config, got:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "upstream web_upstream") || strings.Contains(conf, "forever") {
+		t.Errorf("Expected no upstream group and no invalid timeout for Web, got:\n%s", conf)
+	}
+
+	zones := renderRateLimitZones([]ServiceMetadata{api, plain})
+	if !strings.Contains(zones, "limit_req_zone $binary_remote_addr zone=api_ratelimit:10m rate=10r/s;") {
+		t.Errorf("Expected a zone for Api, got:\n%s", zones)
+	}
+
+	// An nginx.conf without the managed block gets it at the top of http {}, an existing block is replaced
+	legacy := "events {}\n\nhttp {\n    sendfile on;\n}\n"
+	updated := withRateLimitZones(legacy, zones)
+	if !strings.HasPrefix(updated, "events {}\n\nhttp {\n"+zones) || !strings.HasSuffix(updated, "    sendfile on;\n}\n") {
+		t.Errorf("Expected zones inserted into the http block, got:\n%s", updated)
+	}
+	emptied := withRateLimitZones(updated, renderRateLimitZones(nil))
+	if strings.Contains(emptied, "limit_req_zone") || withRateLimitZones(emptied, renderRateLimitZones(nil)) != emptied {
+		t.Errorf("Expected zones to be replaced in place, got:\n%s", emptied)
+	}
+}
+
+func TestEdgeAuthProtectedLocations(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "030-Grafana.yml")
+	content := "# NAME: Grafana\n# NGINX_PROXY: true\n# NGINX_HOST: grafana.example.com\n# NGINX_PORT: 3000\n# NGINX_AUTH: OIDC\n# NGINX_BASIC_AUTH: admin:secret\nservices: {}\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	grafana, err := parseServiceMetadata(file, "030-Grafana.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grafana.NginxAuth != "oidc" {
+		t.Fatalf("Expected NGINX_AUTH oidc, got %q", grafana.NginxAuth)
+	}
+	public := ServiceMetadata{Name: "Docs", Enabled: true, NginxProxy: true, NginxPath: "/docs"}
+
+	conf := renderProxyConfig([]ServiceMetadata{public, grafana}, nil)
+	defaultServer := conf[:strings.Index(conf, "# HTTPS server for")]
+	if strings.Contains(defaultServer, "/oauth2/") || strings.Contains(defaultServer, "auth_request") {
+		t.Errorf("Expected no OIDC wiring in the default server, got:\n%s", defaultServer)
+	}
+	hostServer := conf[strings.Index(conf, "# HTTPS server for"):]
+	for _, want := range []string{
+		"location /oauth2/ {",
+		"location = /oauth2/auth {",
+		`set $edgeauth_backend "EdgeAuth_EdgeAuth:4180";`,
+		"auth_request /oauth2/auth;",
+		"error_page 401 = /oauth2/sign_in;",
+		"proxy_set_header X-Auth-Request-Email $auth_email;",
+	} {
+		if !strings.Contains(hostServer, want) {
+			t.Errorf("Expected %q in the virtual host, got:\n%s", want, hostServer)
+		}
+	}
+	if strings.Contains(hostServer, "auth_basic") {
+		t.Errorf("Expected basic auth to be replaced by OIDC, got:\n%s", hostServer)
+	}
+	if !usesEdgeAuth([]ServiceMetadata{public, grafana}) || usesEdgeAuth([]ServiceMetadata{public}) {
+		t.Errorf("Expected usesEdgeAuth to detect NGINX_AUTH: oidc")
+	}
+}
+
+func TestRenderEdgeAuthStack(t *testing.T) {
+	opts := EdgeAuthOptions{
+		IssuerURL:     "https://login.example.com/realms/main",
+		ClientID:      "edge",
+		ClientSecret:  "pa$$word",
+		EmailDomains:  []string{"example.com"},
+		AllowedGroups: []string{"ops"},
+		Scope:         "openid email profile",
+		Image:         "quay.io/oauth2-proxy/oauth2-proxy:v7.8.1",
+	}
+	secrets := edgeAuthSecrets{
+		ClientSecret: edgeAuthSecretName("client-secret", opts.ClientSecret),
+		CookieSecret: edgeAuthSecretName("cookie-secret", "0123456789abcdef0123456789abcdef"),
+	}
+	stack := renderEdgeAuthStack(opts, secrets)
+	for _, want := range []string{
+		`- "--oidc-issuer-url=https://login.example.com/realms/main"`,
+		`- "--email-domain=example.com"`,
+		`- "--allowed-group=ops"`,
+		`- "--upstream=static://202"`,
+		`- "--client-secret-file=/run/secrets/client-secret"`,
+		`- "--cookie-secret-file=/run/secrets/cookie-secret"`,
+		"name: " + secrets.ClientSecret,
+		"name: DOCKER-SWARM-SERVICES-INTERNAL",
+	} {
+		if !strings.Contains(stack, want) {
+			t.Errorf("Expected %q in stack, got:\n%s", want, stack)
+		}
+	}
+	if strings.Contains(stack, "pa$$") || strings.Contains(stack, "0123456789abcdef") {
+		t.Errorf("Expected no secret values in stack, got:\n%s", stack)
+	}
+	if strings.Contains(stack, "ssl-insecure-skip-verify") {
+		t.Errorf("Expected TLS verification by default, got:\n%s", stack)
+	}
+}
+
+func TestCheckOIDCIssuer(t *testing.T) {
+	// Local mock OIDC provider serving only its discovery document
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/realms/main/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/auth",
+			"token_endpoint":         issuer + "/token",
+			"jwks_uri":               issuer + "/certs",
+		})
+	})
+	provider := httptest.NewTLSServer(mux)
+	defer provider.Close()
+	issuer = provider.URL + "/realms/main"
+
+	ctx := context.Background()
+	if err := checkOIDCIssuer(ctx, issuer, true); err != nil {
+		t.Errorf("Expected mock provider to pass, got: %v", err)
+	}
+	if err := checkOIDCIssuer(ctx, issuer, false); err == nil {
+		t.Errorf("Expected self-signed provider to fail without insecureSkipVerify")
+	}
+	if err := checkOIDCIssuer(ctx, provider.URL+"/realms/other", true); err == nil {
+		t.Errorf("Expected unknown realm to fail")
+	}
+
+	issuer = provider.URL + "/realms/elsewhere"
+	if err := checkOIDCIssuer(ctx, provider.URL+"/realms/main", true); err == nil || !strings.Contains(err.Error(), "reports issuer") {
+		t.Errorf("Expected issuer mismatch, got: %v", err)
+	}
+}
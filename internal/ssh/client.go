@@ -3,6 +3,7 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -27,8 +28,10 @@ type AuthConfig struct {
 	Password           string
 	PrivateKeyPEM      []byte
 	PrivateKeyPath     string
-	PrivateKeyPassword string // Password for encrypted private key (optional)
-	Port               int    // SSH port (default: 22)
+	PrivateKeyPassword string      // Password for encrypted private key (optional)
+	Port               int         // SSH port (default: 22)
+	KnownHosts         *KnownHosts // Host key store (nil = host keys are not verified)
+	HostKeyFingerprint string      // Pinned SHA256 host key fingerprint (optional)
 }
 
 // NewClient creates a new SSH client connection to the specified host using the provided authentication.
@@ -78,11 +81,17 @@ func NewClient(ctx context.Context, host string, auth AuthConfig) (*Client, erro
 		return nil, fmt.Errorf("no authentication method provided (need password or private key)")
 	}
 
+	// Verify host keys against known_hosts (and the pinned fingerprint, if any)
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if auth.KnownHosts != nil {
+		hostKeyCallback = auth.KnownHosts.HostKeyCallback(auth.HostKeyFingerprint)
+	}
+
 	// Configure SSH client
 	config := &ssh.ClientConfig{
 		User:            auth.Username,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: Consider using known_hosts for production
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         10 * time.Second,
 	}
 
@@ -119,6 +128,10 @@ func NewClient(ctx context.Context, host string, auth AuthConfig) (*Client, erro
 		sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 		if err != nil {
 			conn.Close()
+			// Never retry host key mismatches
+			if IsHostKeyMismatch(err) {
+				return retry.NonRetryable(fmt.Errorf("failed to establish ssh connection to %s: %w", addr, err))
+			}
 			// Retry on authentication and handshake failures (key might not be installed yet)
 			if isRetryableSSHError(err) {
 				return fmt.Errorf("failed to establish ssh connection to %s: %w", addr, err)
@@ -221,6 +234,36 @@ func (c *Client) RunWithInput(ctx context.Context, command string, input io.Read
 	}
 }
 
+// RunStream executes a command with stdin and stdout connected to the given
+// reader and writer (either may be nil), so large data can be streamed
+// without buffering it in memory. Returns stderr.
+func (c *Client) RunStream(ctx context.Context, command string, stdin io.Reader, stdout io.Writer) (stderr string, err error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	var stderrBuf bytes.Buffer
+	session.Stdout = stdout
+	session.Stderr = &stderrBuf
+	session.Stdin = stdin
+
+	// Run command with context support
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- session.Run(command)
+	}()
+
+	select {
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		return "", ctx.Err()
+	case err := <-errChan:
+		return stderrBuf.String(), err
+	}
+}
+
 // Close closes the SSH connection.
 func (c *Client) Close() error {
 	return c.client.Close()
@@ -231,3 +274,16 @@ func (c *Client) Host() string {
 	return c.host
 }
 
+// ExitStatus returns the remote exit status carried by err: 0 for nil,
+// the command's exit code for a remote exit error, and -1 otherwise
+// (connection failures, cancellation, or termination by signal).
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
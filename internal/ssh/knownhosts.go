@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"dscotctl/internal/logging"
+)
+
+// HostKeyMismatchError is returned when a host presents a key that does not
+// match the pinned fingerprint or the key recorded in known_hosts.
+// It is never retried: a changed host key means either a reinstalled node or
+// a man-in-the-middle, and both need an operator decision.
+type HostKeyMismatchError struct {
+	Host     string // Host as dialed (host:port)
+	Expected string // Expected SHA256 fingerprint
+	Actual   string // Fingerprint presented by the host
+	Pinned   bool   // True if Expected comes from hostKeyFingerprint in the config
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	source := "known_hosts"
+	if e.Pinned {
+		source = "pinned hostKeyFingerprint"
+	}
+	msg := fmt.Sprintf("host key mismatch for %s: %s expects %s, host presented %s", e.Host, source, e.Expected, e.Actual)
+	if !e.Pinned {
+		msg += fmt.Sprintf(" (if the node was reinstalled, run 'dscotctl known-hosts forget %s')", e.Host)
+	}
+	return msg
+}
+
+// IsHostKeyMismatch reports whether err is or wraps a HostKeyMismatchError.
+func IsHostKeyMismatch(err error) bool {
+	var mismatch *HostKeyMismatchError
+	return errors.As(err, &mismatch)
+}
+
+// KnownHosts is a trust-on-first-use host key store backed by an OpenSSH
+// compatible known_hosts file. Unknown hosts are recorded on first connect.
+// A changed key is always rejected; replacing it takes an explicit Forget.
+type KnownHosts struct {
+	path string
+	mu   sync.Mutex
+	keys map[string]ssh.PublicKey // normalized host → key
+}
+
+// NewKnownHosts loads (or creates) the known_hosts file at path.
+func NewKnownHosts(path string) (*KnownHosts, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create known_hosts directory: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read known_hosts %s: %w", path, err)
+	}
+
+	kh := &KnownHosts{
+		path: path,
+		keys: make(map[string]ssh.PublicKey),
+	}
+
+	rest := data
+	for len(rest) > 0 {
+		var (
+			marker string
+			hosts  []string
+			key    ssh.PublicKey
+		)
+		marker, hosts, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse known_hosts %s: %w", path, err)
+		}
+		if marker != "" {
+			continue
+		}
+		for _, h := range hosts {
+			kh.keys[h] = key
+		}
+	}
+
+	return kh, nil
+}
+
+// Path returns the location of the known_hosts file.
+func (k *KnownHosts) Path() string {
+	return k.path
+}
+
+// HostKeyCallback returns a callback that verifies host keys against the
+// store. If pinnedFingerprint is set (SHA256:... as printed by ssh-keygen -l),
+// the presented key must match it and, once recorded, the known_hosts entry
+// as well.
+func (k *KnownHosts) HostKeyCallback(pinnedFingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		actual := ssh.FingerprintSHA256(key)
+
+		if pinnedFingerprint != "" {
+			expected := normalizeFingerprint(pinnedFingerprint)
+			if actual != expected {
+				return &HostKeyMismatchError{Host: hostname, Expected: expected, Actual: actual, Pinned: true}
+			}
+		}
+
+		return k.check(hostname, key)
+	}
+}
+
+// check verifies key for hostname, recording it on first use.
+func (k *KnownHosts) check(hostname string, key ssh.PublicKey) error {
+	log := logging.L().With("component", "known-hosts")
+	host := knownhosts.Normalize(hostname)
+	actual := ssh.FingerprintSHA256(key)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	known, exists := k.keys[host]
+	if exists && bytes.Equal(known.Marshal(), key.Marshal()) {
+		return nil
+	}
+
+	if exists {
+		return &HostKeyMismatchError{Host: hostname, Expected: ssh.FingerprintSHA256(known), Actual: actual}
+	}
+
+	log.Infow(fmt.Sprintf("→ [%s] recording new host key", host), "fingerprint", actual, "file", k.path)
+	k.keys[host] = key
+	if err := k.save(); err != nil {
+		return fmt.Errorf("failed to update known_hosts: %w", err)
+	}
+	return nil
+}
+
+// Forget removes the recorded key of host (host or host:port), so the next
+// connection records the key the host presents. It reports whether an entry
+// was removed.
+func (k *KnownHosts) Forget(host string) (bool, error) {
+	normalized := knownhosts.Normalize(host)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, exists := k.keys[normalized]; !exists {
+		return false, nil
+	}
+	delete(k.keys, normalized)
+	if err := k.save(); err != nil {
+		return false, fmt.Errorf("failed to update known_hosts: %w", err)
+	}
+	return true, nil
+}
+
+// save rewrites the known_hosts file from the in-memory store.
+// Callers must hold k.mu.
+func (k *KnownHosts) save() error {
+	hosts := make([]string, 0, len(k.keys))
+	for host := range k.keys {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var buf bytes.Buffer
+	for _, host := range hosts {
+		buf.WriteString(knownhosts.Line([]string{host}, k.keys[host]))
+		buf.WriteString("\n")
+	}
+
+	tmpPath := k.path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, k.path)
+}
+
+// normalizeFingerprint accepts fingerprints with or without the "SHA256:" prefix.
+func normalizeFingerprint(fp string) string {
+	fp = strings.TrimSpace(fp)
+	if !strings.HasPrefix(fp, "SHA256:") {
+		fp = "SHA256:" + fp
+	}
+	return strings.TrimRight(fp, "=")
+}
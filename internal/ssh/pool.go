@@ -3,6 +3,8 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 
 	"dscotctl/internal/logging"
@@ -72,6 +74,46 @@ func (p *Pool) Run(ctx context.Context, host, command string) (stdout, stderr st
 	return client.Run(ctx, command)
 }
 
+// RunStream executes a command on the specified host with streamed stdin/stdout.
+func (p *Pool) RunStream(ctx context.Context, host, command string, stdin io.Reader, stdout io.Writer) (stderr string, err error) {
+	client, err := p.Get(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	return client.RunStream(ctx, command, stdin, stdout)
+}
+
+// WriteFile writes data to remotePath on the specified host.
+func (p *Pool) WriteFile(ctx context.Context, host, remotePath string, data []byte, mode os.FileMode) error {
+	client, err := p.Get(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	return client.WriteFile(ctx, remotePath, data, mode)
+}
+
+// Upload copies a local file to remotePath on the specified host.
+func (p *Pool) Upload(ctx context.Context, host, localPath, remotePath string) error {
+	client, err := p.Get(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	return client.Upload(ctx, localPath, remotePath)
+}
+
+// Remove deletes files on the specified host.
+func (p *Pool) Remove(ctx context.Context, host string, remotePaths ...string) error {
+	client, err := p.Get(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	return client.Remove(ctx, remotePaths...)
+}
+
 // RunAll executes a command on all specified hosts in parallel.
 // Returns a map of host -> result.
 type RunResult struct {
@@ -112,6 +154,18 @@ func (p *Pool) RunAll(ctx context.Context, hosts []string, command string) map[s
 	return results
 }
 
+// Reset closes the connection to host, if any, so the next command reconnects.
+// Used after a node reboots.
+func (p *Pool) Reset(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, exists := p.clients[host]; exists {
+		_ = client.Close()
+		delete(p.clients, host)
+	}
+}
+
 // Close closes all SSH connections in the pool.
 func (p *Pool) Close() error {
 	p.mu.Lock()
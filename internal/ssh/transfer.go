@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// WriteFile writes data to remotePath using the scp sink protocol.
+// The mode is applied when the file is created; an existing file keeps its
+// permissions and is overwritten in place. The parent directory must exist.
+func (c *Client) WriteFile(ctx context.Context, remotePath string, data []byte, mode os.FileMode) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open stdout: %w", err)
+	}
+	var stderrBuf bytes.Buffer
+	session.Stderr = &stderrBuf
+
+	if err := session.Start("scp -qt " + ShellQuote(remotePath)); err != nil {
+		return fmt.Errorf("failed to start scp on %s: %w", c.host, err)
+	}
+
+	// Run the transfer with context support
+	errChan := make(chan error, 1)
+	go func() {
+		err := scpSend(stdin, bufio.NewReader(stdout), path.Base(remotePath), data, mode)
+		stdin.Close()
+		if waitErr := session.Wait(); err == nil {
+			err = waitErr
+		}
+		errChan <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("failed to write %s on %s: %w (stderr: %s)", remotePath, c.host, err, strings.TrimSpace(stderrBuf.String()))
+		}
+		return nil
+	}
+}
+
+// Upload copies a local file to remotePath, keeping the local permission bits.
+func (c *Client) Upload(ctx context.Context, localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", localPath, err)
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	return c.WriteFile(ctx, remotePath, data, info.Mode().Perm())
+}
+
+// Remove deletes the given remote files. Missing files are not an error.
+func (c *Client) Remove(ctx context.Context, remotePaths ...string) error {
+	if len(remotePaths) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(remotePaths))
+	for i, p := range remotePaths {
+		quoted[i] = ShellQuote(p)
+	}
+	if _, stderr, err := c.Run(ctx, "rm -f "+strings.Join(quoted, " ")); err != nil {
+		return fmt.Errorf("failed to remove files on %s: %w (stderr: %s)", c.host, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// scpSend sends a single file to an scp sink ("scp -t").
+func scpSend(w io.Writer, r *bufio.Reader, name string, data []byte, mode os.FileMode) error {
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "C%04o %d %s\n", mode.Perm(), len(data), name); err != nil {
+		return err
+	}
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return err
+	}
+	return scpAck(r)
+}
+
+// scpAck reads a status byte from the scp sink. Non-zero status is followed
+// by an error message line.
+func scpAck(r *bufio.Reader) error {
+	status, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("failed to read scp response: %w", err)
+	}
+	if status == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+}
+
+// ShellQuote quotes s for use as a single POSIX shell word.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
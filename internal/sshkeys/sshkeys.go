@@ -28,6 +28,8 @@ const (
 	PublicKeyFileName = "PublicKey.pubkey"
 	// PasswordFileName is the name of the password file
 	PasswordFileName = "PrivateKey.pwd"
+	// KnownHostsFileName is the name of the known_hosts file kept next to the key directory
+	KnownHostsFileName = "known_hosts"
 
 	// KeyTypeED25519 is the ED25519 key type (default, recommended)
 	KeyTypeED25519 = "ed25519"
@@ -156,6 +158,16 @@ func getLatestKeyFolder(baseDir string) (string, error) {
 	return filepath.Join(baseDir, uuidFolders[0].Name()), nil
 }
 
+// KnownHostsPath returns the path of the known_hosts file, which lives next to
+// the default key directory (in the binary directory).
+func KnownHostsPath() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	return filepath.Join(filepath.Dir(exePath), KnownHostsFileName), nil
+}
+
 // EnsureKeyPair ensures an SSH key pair exists, generating it if necessary.
 // keyType specifies the type of key to generate: "ed25519" (default) or "rsa".
 // Returns the key pair information.
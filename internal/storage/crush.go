@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dscotctl/internal/config"
+	"dscotctl/internal/geolocation"
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+)
+
+// crushLevels are the CRUSH bucket types above host, outermost first. Node
+// labels with the same names place a host in the hierarchy.
+var crushLevels = []string{"region", "datacenter", "rack"}
+
+// crushBucketNameInvalid matches characters not used in generated bucket names.
+var crushBucketNameInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// crushBucket is one level of a host's CRUSH location.
+type crushBucket struct {
+	Type string
+	Name string
+}
+
+// crushBucketName joins the non-empty parts into a lowercase bucket name.
+// Bucket names are global in the CRUSH map, so geolocation names include
+// their parents (e.g. "us-ca-san-jose").
+func crushBucketName(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	name := crushBucketNameInvalid.ReplaceAllString(strings.ToLower(strings.Join(nonEmpty, "-")), "-")
+	return strings.Trim(name, "-")
+}
+
+// geoBucketName derives a region or datacenter bucket name from geolocation.
+// The city stands in for the datacenter; racks cannot be derived.
+func geoBucketName(level string, geo *geolocation.GeoInfo) string {
+	if geo == nil || geo.CountryCode == "" {
+		return ""
+	}
+	switch level {
+	case "region":
+		return crushBucketName(geo.CountryCode, geo.Region)
+	case "datacenter":
+		if geo.City == "" {
+			return ""
+		}
+		return crushBucketName(geo.CountryCode, geo.Region, geo.City)
+	}
+	return ""
+}
+
+// crushLocation returns the CRUSH buckets a node belongs in, outermost first.
+// With the topology disabled only the failure domain level is used.
+func crushLocation(node config.NodeConfig, geo *geolocation.GeoInfo, topology config.CrushTopology, failureDomain string) []crushBucket {
+	var location []crushBucket
+	for _, level := range crushLevels {
+		if !topology.Enabled && level != failureDomain {
+			continue
+		}
+		name := crushBucketName(node.Labels[level])
+		if name == "" && topology.UseGeolocation {
+			name = geoBucketName(level, geo)
+		}
+		if name != "" {
+			location = append(location, crushBucket{Type: level, Name: name})
+		}
+	}
+	return location
+}
+
+// chooseFailureDomain returns the outermost level that every host has and
+// that has at least replicas distinct buckets, or "" if there is none.
+func chooseFailureDomain(locations map[string][]crushBucket, replicas int) string {
+	for _, level := range crushLevels {
+		buckets := make(map[string]bool)
+		complete := len(locations) > 0
+		for _, location := range locations {
+			found := false
+			for _, bucket := range location {
+				if bucket.Type == level {
+					buckets[bucket.Name] = true
+					found = true
+				}
+			}
+			complete = complete && found
+		}
+		if complete && len(buckets) >= replicas {
+			return level
+		}
+	}
+	return ""
+}
+
+// crushFailureDomain places OSD hosts into their CRUSH buckets and returns
+// the failure domain for replicated pools. An unset failure domain is chosen
+// from the topology when it is enabled, otherwise left to MicroCeph ("").
+func (p *MicroCephProvider) crushFailureDomain(ctx context.Context, sshPool *ssh.Pool, primaryNode string) (string, error) {
+	log := logging.L().With("component", "microceph", "node", primaryNode)
+	ds := p.cfg.GetDistributedStorage()
+	failureDomain := ds.PoolPolicy.FailureDomain
+
+	if !ds.CrushTopology.Enabled && (failureDomain == "" || failureDomain == "osd" || failureDomain == "host") {
+		return failureDomain, nil
+	}
+
+	locations := p.crushLocations(ctx, sshPool)
+	if err := p.placeHosts(ctx, sshPool, primaryNode, locations); err != nil {
+		return "", err
+	}
+
+	if failureDomain == "" {
+		replicas := ds.PoolPolicy.Size
+		if replicas == 0 {
+			replicas = 3 // Ceph's default pool size
+		}
+		failureDomain = chooseFailureDomain(locations, replicas)
+		if failureDomain == "" {
+			log.Warnw("fewer CRUSH buckets than replicas at every level; copies may share a location",
+				"replicas", replicas)
+		} else {
+			log.Infow("chose CRUSH failure domain from topology", "failureDomain", failureDomain, "replicas", replicas)
+		}
+	}
+	return failureDomain, nil
+}
+
+// crushLocations returns the CRUSH location of every OSD node, keyed by SSH
+// address. Geolocation is only detected when the topology asks for it.
+func (p *MicroCephProvider) crushLocations(ctx context.Context, sshPool *ssh.Pool) map[string][]crushBucket {
+	ds := p.cfg.GetDistributedStorage()
+	topology := ds.CrushTopology
+
+	var osdNodes []config.NodeConfig
+	var hosts []string
+	for _, node := range p.cfg.GetStorageNodes() {
+		if node.Role == "manager" {
+			continue
+		}
+		osdNodes = append(osdNodes, node)
+		hosts = append(hosts, node.SSHFQDNorIP)
+	}
+
+	var geoInfoMap map[string]*geolocation.GeoInfo
+	if topology.Enabled && topology.UseGeolocation {
+		geoInfoMap = geolocation.DetectGeoLocationBatch(ctx, sshPool, hosts)
+	}
+
+	locations := make(map[string][]crushBucket, len(osdNodes))
+	for _, node := range osdNodes {
+		locations[node.SSHFQDNorIP] = crushLocation(node, geoInfoMap[node.SSHFQDNorIP], topology, ds.PoolPolicy.FailureDomain)
+	}
+	return locations
+}
+
+// ApplyTopology places the configured OSD hosts into their CRUSH buckets.
+// Called when a node joins so it does not sit directly under the root.
+func (p *MicroCephProvider) ApplyTopology(ctx context.Context, sshPool *ssh.Pool, monNode string) error {
+	_, err := p.crushFailureDomain(ctx, sshPool, monNode)
+	return err
+}
+
+// placeHosts moves each OSD host under its CRUSH location, creating missing
+// buckets under the default root. Hosts not yet in the CRUSH map are skipped.
+func (p *MicroCephProvider) placeHosts(ctx context.Context, sshPool *ssh.Pool, primaryNode string, locations map[string][]crushBucket) error {
+	log := logging.L().With("component", "microceph", "node", primaryNode)
+
+	stdout, stderr, err := sshPool.Run(ctx, primaryNode, "ceph osd tree --format json")
+	if err != nil {
+		return fmt.Errorf("failed to get OSD tree: %w (stderr: %s)", err, stderr)
+	}
+	var tree cephOSDTreeJSON
+	if err := json.Unmarshal([]byte(stdout), &tree); err != nil {
+		return fmt.Errorf("failed to parse OSD tree JSON: %w", err)
+	}
+
+	nodes := make([]string, 0, len(locations))
+	for node := range locations {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		location := locations[node]
+		if len(location) == 0 {
+			log.Warnw("no CRUSH location for OSD node, leaving it under the root", "osdNode", node)
+			continue
+		}
+
+		hostnameOut, stderr, err := sshPool.Run(ctx, node, "hostname -f 2>/dev/null || hostname")
+		if err != nil {
+			return fmt.Errorf("failed to determine hostname of %s: %w (stderr: %s)", node, err, stderr)
+		}
+		host := findOSDTreeHost(tree, strings.TrimSpace(hostnameOut))
+		if host == nil {
+			log.Warnw("OSD host not found in CRUSH map, skipping", "osdNode", node)
+			continue
+		}
+
+		// Each bucket moves under its ancestors; the host moves under all of them
+		ancestors := []string{"root=default"}
+		var cmds []string
+		for _, bucket := range location {
+			cmds = append(cmds,
+				fmt.Sprintf("ceph osd crush add-bucket %s %s", bucket.Name, bucket.Type),
+				fmt.Sprintf("ceph osd crush move %s %s", bucket.Name, strings.Join(ancestors, " ")),
+			)
+			ancestors = append(ancestors, fmt.Sprintf("%s=%s", bucket.Type, bucket.Name))
+		}
+		cmds = append(cmds, fmt.Sprintf("ceph osd crush move %s %s", host.Name, strings.Join(ancestors, " ")))
+
+		for _, cmd := range cmds {
+			if _, stderr, err := sshPool.Run(ctx, primaryNode, cmd); err != nil && !strings.Contains(stderr, "already exists") {
+				return fmt.Errorf("failed to place %s in CRUSH map: %w (stderr: %s)", host.Name, err, stderr)
+			}
+		}
+		log.Infow("✓ OSD host placed in CRUSH hierarchy", "host", host.Name, "location", strings.Join(ancestors, " "))
+	}
+	return nil
+}
+
+// ensureCrushRule creates a replicated CRUSH rule spreading copies across
+// failureDomain buckets and returns its name.
+func (p *MicroCephProvider) ensureCrushRule(ctx context.Context, sshPool *ssh.Pool, primaryNode, failureDomain string) (string, error) {
+	log := logging.L().With("component", "microceph", "node", primaryNode, "failureDomain", failureDomain)
+
+	rule := fmt.Sprintf("replicated-%s", failureDomain)
+	cmd := fmt.Sprintf("ceph osd crush rule create-replicated %s default %s", rule, failureDomain)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, cmd); err != nil && !strings.Contains(stderr, "already exists") {
+		return "", fmt.Errorf("failed to create CRUSH rule %s: %w (stderr: %s)", rule, err, stderr)
+	}
+	log.Infow("✓ CRUSH rule ready", "rule", rule)
+	return rule, nil
+}
@@ -70,6 +70,91 @@ func (p *MicroCephProvider) Install(ctx context.Context, sshPool *ssh.Pool, node
 	return nil
 }
 
+// cephReleases lists the Ceph releases MicroCeph publishes snap tracks for,
+// oldest first.
+var cephReleases = []string{"quincy", "reef", "squid", "tentacle"}
+
+// cephReleaseIndex returns the position of a channel's track in cephReleases,
+// or -1 for unknown tracks such as "latest".
+func cephReleaseIndex(channel string) int {
+	track := strings.SplitN(channel, "/", 2)[0]
+	for i, r := range cephReleases {
+		if r == track {
+			return i
+		}
+	}
+	return -1
+}
+
+// InstalledChannel returns the snap channel MicroCeph tracks on a node.
+func (p *MicroCephProvider) InstalledChannel(ctx context.Context, sshPool *ssh.Pool, node string) (string, error) {
+	stdout, stderr, err := sshPool.Run(ctx, node, "snap list microceph | awk 'NR==2 {print $4}'")
+	if err != nil {
+		return "", fmt.Errorf("failed to read microceph snap channel: %w (stderr: %s)", err, stderr)
+	}
+	channel := strings.TrimSpace(stdout)
+	if channel == "" {
+		return "", fmt.Errorf("microceph is not installed on %s", node)
+	}
+	return channel, nil
+}
+
+// ValidateUpgrade allows moving to the same or the next Ceph release only.
+// Ceph does not support downgrades, and MicroCeph is upgraded one major
+// release at a time.
+func (p *MicroCephProvider) ValidateUpgrade(from, to string) error {
+	fromIdx, toIdx := cephReleaseIndex(from), cephReleaseIndex(to)
+	if fromIdx < 0 {
+		return fmt.Errorf("cannot upgrade from channel %q: unknown Ceph release (supported: %s)", from, strings.Join(cephReleases, ", "))
+	}
+	if toIdx < 0 {
+		return fmt.Errorf("cannot upgrade to channel %q: unknown Ceph release (supported: %s)", to, strings.Join(cephReleases, ", "))
+	}
+	if toIdx < fromIdx {
+		return fmt.Errorf("cannot downgrade from %s to %s", from, to)
+	}
+	if toIdx > fromIdx+1 {
+		return fmt.Errorf("cannot upgrade from %s to %s: upgrade to %s first", from, to, cephReleases[fromIdx+1])
+	}
+	return nil
+}
+
+// Upgrade refreshes the MicroCeph snap on a node to channel. The snap is
+// held again afterwards unless EnableUpdates is set.
+func (p *MicroCephProvider) Upgrade(ctx context.Context, sshPool *ssh.Pool, node, channel string) error {
+	log := logging.L().With("component", "microceph", "node", node)
+
+	refreshCmd := fmt.Sprintf("snap refresh microceph --channel=%s", channel)
+	log.Infow("refreshing MicroCeph", "command", refreshCmd)
+	if _, stderr, err := sshPool.Run(ctx, node, refreshCmd); err != nil {
+		return fmt.Errorf("failed to refresh microceph: %w (stderr: %s)", err, stderr)
+	}
+
+	if !p.cfg.GetDistributedStorage().Providers.MicroCeph.EnableUpdates {
+		if _, stderr, err := sshPool.Run(ctx, node, "snap refresh --hold microceph"); err != nil {
+			log.Warnw("failed to hold snap updates (non-fatal)", "error", err, "stderr", stderr)
+		}
+	}
+
+	log.Infow("✓ MicroCeph refreshed", "channel", channel)
+	return nil
+}
+
+// FinalizeUpgrade requires the new release for all OSDs once every node has
+// been refreshed, which enables the release's new features.
+func (p *MicroCephProvider) FinalizeUpgrade(ctx context.Context, sshPool *ssh.Pool, monNode, channel string) error {
+	idx := cephReleaseIndex(channel)
+	if idx < 0 {
+		return fmt.Errorf("unknown Ceph release for channel %q", channel)
+	}
+
+	cmd := fmt.Sprintf("ceph osd require-osd-release %s", cephReleases[idx])
+	if _, stderr, err := sshPool.Run(ctx, monNode, cmd); err != nil {
+		return fmt.Errorf("failed to set required OSD release: %w (stderr: %s)", err, stderr)
+	}
+	return nil
+}
+
 // Bootstrap initializes the MicroCeph cluster on the primary node.
 func (p *MicroCephProvider) Bootstrap(ctx context.Context, sshPool *ssh.Pool, primaryNode string) error {
 	log := logging.L().With("component", "microceph", "node", primaryNode)
@@ -733,10 +818,284 @@ func (p *MicroCephProvider) CreatePool(ctx context.Context, sshPool *ssh.Pool, p
 		return fmt.Errorf("CephFS filesystem %s did not become ready: %w", fsName, err)
 	}
 
+	if err := p.applyPoolPolicy(ctx, sshPool, primaryNode, dataPool, metadataPool); err != nil {
+		return err
+	}
+	if p.cfg.GetDistributedStorage().PoolPolicy.ErasureCoding.Enabled {
+		if err := p.createErasureCodedDataPool(ctx, sshPool, primaryNode, fsName); err != nil {
+			return err
+		}
+	}
+
 	log.Infow("✓ CephFS filesystem created", "poolName", poolName)
 	return nil
 }
 
+// CreateBlockPool creates an RBD pool and initializes it for block images.
+// Existing pools are left as they are.
+func (p *MicroCephProvider) CreateBlockPool(ctx context.Context, sshPool *ssh.Pool, primaryNode, poolName string) error {
+	log := logging.L().With("component", "microceph", "node", primaryNode, "poolName", poolName)
+
+	createPoolCmd := fmt.Sprintf("ceph osd pool create %s 32", poolName)
+	log.Infow("creating RBD pool if needed", "command", createPoolCmd)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, createPoolCmd); err != nil {
+		if !strings.Contains(stderr, "already exists") && !strings.Contains(stderr, "EEXIST") {
+			return fmt.Errorf("failed to create RBD pool %s: %w (stderr: %s)", poolName, err, stderr)
+		}
+		log.Infow("RBD pool already exists")
+	}
+
+	enableCmd := fmt.Sprintf("ceph osd pool application enable %s rbd", poolName)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, enableCmd); err != nil && !strings.Contains(stderr, "already enabled") {
+		return fmt.Errorf("failed to enable rbd application on pool %s: %w (stderr: %s)", poolName, err, stderr)
+	}
+
+	initCmd := fmt.Sprintf("rbd pool init %s", poolName)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, initCmd); err != nil {
+		return fmt.Errorf("failed to initialize RBD pool %s: %w (stderr: %s)", poolName, err, stderr)
+	}
+
+	if err := p.applyPoolPolicy(ctx, sshPool, primaryNode, poolName); err != nil {
+		return err
+	}
+
+	log.Infow("✓ RBD pool ready")
+	return nil
+}
+
+// erasureCodedDataPool returns the name of the erasure-coded CephFS data pool.
+func erasureCodedDataPool(poolName string) string {
+	return fmt.Sprintf("%s-data-ec", poolName)
+}
+
+// countCrushBuckets returns the number of CRUSH buckets (or OSDs) of a type.
+func countCrushBuckets(ctx context.Context, sshPool *ssh.Pool, primaryNode, bucketType string) (int, error) {
+	stdout, stderr, err := sshPool.Run(ctx, primaryNode, "ceph osd tree --format json")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get OSD tree: %w (stderr: %s)", err, stderr)
+	}
+	var tree cephOSDTreeJSON
+	if err := json.Unmarshal([]byte(stdout), &tree); err != nil {
+		return 0, fmt.Errorf("failed to parse OSD tree JSON: %w", err)
+	}
+
+	count := 0
+	for _, node := range tree.Nodes {
+		if node.Type == bucketType {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// applyPoolPolicy applies the configured replica count, failure domain and
+// PG autoscale mode to replicated pools. Unset values are left to MicroCeph.
+func (p *MicroCephProvider) applyPoolPolicy(ctx context.Context, sshPool *ssh.Pool, primaryNode string, pools ...string) error {
+	log := logging.L().With("component", "microceph", "node", primaryNode)
+	policy := p.cfg.GetDistributedStorage().PoolPolicy
+
+	failureDomain, err := p.crushFailureDomain(ctx, sshPool, primaryNode)
+	if err != nil {
+		return err
+	}
+	var crushRule string
+	if failureDomain != "" {
+		rule, err := p.ensureCrushRule(ctx, sshPool, primaryNode, failureDomain)
+		if err != nil {
+			return err
+		}
+		crushRule = rule
+	}
+
+	// size before min_size so a larger min_size is never rejected
+	var settings [][2]string
+	if crushRule != "" {
+		settings = append(settings, [2]string{"crush_rule", crushRule})
+	}
+	if policy.Size > 0 {
+		settings = append(settings, [2]string{"size", fmt.Sprintf("%d", policy.Size)})
+	}
+	if policy.MinSize > 0 {
+		settings = append(settings, [2]string{"min_size", fmt.Sprintf("%d", policy.MinSize)})
+	}
+	if policy.AutoscaleMode != "" {
+		settings = append(settings, [2]string{"pg_autoscale_mode", policy.AutoscaleMode})
+	}
+
+	for _, pool := range pools {
+		for _, setting := range settings {
+			cmd := fmt.Sprintf("ceph osd pool set %s %s %s", pool, setting[0], setting[1])
+			if _, stderr, err := sshPool.Run(ctx, primaryNode, cmd); err != nil {
+				return fmt.Errorf("failed to set %s on pool %s: %w (stderr: %s)", setting[0], pool, err, stderr)
+			}
+		}
+		if len(settings) > 0 {
+			log.Infow("✓ pool policy applied", "pool", pool, "crushRule", crushRule,
+				"size", policy.Size, "minSize", policy.MinSize, "autoscaleMode", policy.AutoscaleMode)
+		}
+	}
+	return nil
+}
+
+// createErasureCodedDataPool creates an erasure-coded pool and adds it to the
+// filesystem as a second data pool. ApplyDataLayout points new files at it.
+func (p *MicroCephProvider) createErasureCodedDataPool(ctx context.Context, sshPool *ssh.Pool, primaryNode, fsName string) error {
+	policy := p.cfg.GetDistributedStorage().PoolPolicy
+	ec := policy.ErasureCoding
+	ecPool := erasureCodedDataPool(fsName)
+	profile := fmt.Sprintf("%s-k%dm%d", ecPool, ec.DataChunks, ec.CodingChunks)
+	log := logging.L().With("component", "microceph", "node", primaryNode, "pool", ecPool, "profile", profile)
+
+	failureDomain := policy.FailureDomain
+	if failureDomain == "" {
+		failureDomain = "host"
+	}
+
+	// Ceph places every chunk in its own failure domain; with fewer domains
+	// the pool's placement groups never become active
+	domains, err := countCrushBuckets(ctx, sshPool, primaryNode, failureDomain)
+	if err != nil {
+		return err
+	}
+	if ec.DataChunks+ec.CodingChunks > domains {
+		return fmt.Errorf("erasure-coded pool %s needs %d %s failure domains (k=%d + m=%d), the CRUSH map has %d",
+			ecPool, ec.DataChunks+ec.CodingChunks, failureDomain, ec.DataChunks, ec.CodingChunks, domains)
+	}
+
+	profileCmd := fmt.Sprintf("ceph osd erasure-code-profile set %s k=%d m=%d crush-failure-domain=%s",
+		profile, ec.DataChunks, ec.CodingChunks, failureDomain)
+	log.Infow("creating erasure code profile", "command", profileCmd)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, profileCmd); err != nil {
+		return fmt.Errorf("failed to create erasure code profile %s: %w (stderr: %s)", profile, err, stderr)
+	}
+
+	createCmd := fmt.Sprintf("ceph osd pool create %s erasure %s", ecPool, profile)
+	if _, stderr, err := sshPool.Run(ctx, primaryNode, createCmd); err != nil {
+		if !strings.Contains(stderr, "already exists") && !strings.Contains(stderr, "EEXIST") {
+			return fmt.Errorf("failed to create erasure-coded pool %s: %w (stderr: %s)", ecPool, err, stderr)
+		}
+		// The profile of an existing pool cannot change
+		if out, _, err := sshPool.Run(ctx, primaryNode, fmt.Sprintf("ceph osd pool get %s erasure_code_profile", ecPool)); err == nil &&
+			!strings.Contains(out, profile) {
+			log.Warnw("erasure-coded pool exists with a different profile; dataChunks/codingChunks changes need a new pool",
+				"current", strings.TrimSpace(out))
+		}
+	}
+
+	cmds := []string{
+		fmt.Sprintf("ceph osd pool set %s allow_ec_overwrites true", ecPool),
+		fmt.Sprintf("ceph fs add_data_pool %s %s", fsName, ecPool),
+	}
+	if policy.AutoscaleMode != "" {
+		cmds = append(cmds, fmt.Sprintf("ceph osd pool set %s pg_autoscale_mode %s", ecPool, policy.AutoscaleMode))
+	}
+	for _, cmd := range cmds {
+		if _, stderr, err := sshPool.Run(ctx, primaryNode, cmd); err != nil {
+			return fmt.Errorf("failed to configure erasure-coded pool %s: %w (stderr: %s)", ecPool, err, stderr)
+		}
+	}
+
+	log.Infow("✓ erasure-coded data pool ready", "k", ec.DataChunks, "m", ec.CodingChunks, "failureDomain", failureDomain)
+	return nil
+}
+
+// ApplyDataLayout sets the CephFS root layout to the erasure-coded data pool
+// when erasure coding is enabled. Only files created afterwards use the pool.
+func (p *MicroCephProvider) ApplyDataLayout(ctx context.Context, sshPool *ssh.Pool, node, poolName string) error {
+	if !p.cfg.GetDistributedStorage().PoolPolicy.ErasureCoding.Enabled {
+		return nil
+	}
+
+	mountPath := p.GetMountPath()
+	ecPool := erasureCodedDataPool(poolName)
+	log := logging.L().With("component", "microceph", "node", node, "mountPath", mountPath, "pool", ecPool)
+
+	installCmd := "command -v setfattr >/dev/null 2>&1 || DEBIAN_FRONTEND=noninteractive apt-get install -y -q attr"
+	if _, stderr, err := sshPool.Run(ctx, node, installCmd); err != nil {
+		return fmt.Errorf("failed to install attr tools: %w (stderr: %s)", err, stderr)
+	}
+
+	getCmd := fmt.Sprintf("getfattr -n ceph.dir.layout.pool --only-values %s 2>/dev/null", ssh.ShellQuote(mountPath))
+	if stdout, _, err := sshPool.Run(ctx, node, getCmd); err == nil && strings.TrimSpace(stdout) == ecPool {
+		log.Infow("CephFS layout already uses erasure-coded pool")
+		return nil
+	}
+
+	setCmd := fmt.Sprintf("setfattr -n ceph.dir.layout.pool -v %s %s", ecPool, ssh.ShellQuote(mountPath))
+	if _, stderr, err := sshPool.Run(ctx, node, setCmd); err != nil {
+		return fmt.Errorf("failed to set CephFS layout: %w (stderr: %s)", err, stderr)
+	}
+	log.Infow("✓ CephFS layout set; new files use the erasure-coded pool")
+	return nil
+}
+
+// InstallVolumePlugin writes /etc/ceph client configuration for the admin
+// user, loads the rbd kernel module and installs the RBD Docker volume plugin.
+// An installed plugin pointing at another pool is reconfigured.
+func (p *MicroCephProvider) InstallVolumePlugin(ctx context.Context, sshPool *ssh.Pool, node, poolName string, creds *ClusterCredentials) error {
+	log := logging.L().With("component", "microceph", "node", node, "poolName", poolName)
+	mcCfg := p.cfg.GetDistributedStorage().Providers.MicroCeph
+	driver := defaults.RBDVolumeDriver
+
+	if creds == nil {
+		return fmt.Errorf("cluster credentials required")
+	}
+	logging.RegisterSecret(creds.AdminKey)
+
+	if _, stderr, err := sshPool.Run(ctx, node, "mkdir -p /etc/ceph"); err != nil {
+		return fmt.Errorf("failed to create /etc/ceph: %w (stderr: %s)", err, stderr)
+	}
+	cephConf := fmt.Sprintf("[global]\nfsid = %s\nmon_host = %s\n", creds.FSID, creds.MonAddrs)
+	if err := sshPool.WriteFile(ctx, node, "/etc/ceph/ceph.conf", []byte(cephConf), 0644); err != nil {
+		return fmt.Errorf("failed to write ceph.conf: %w", err)
+	}
+	keyring := fmt.Sprintf("[client.admin]\n\tkey = %s\n", creds.AdminKey)
+	if err := sshPool.WriteFile(ctx, node, "/etc/ceph/ceph.client.admin.keyring", []byte(keyring), 0600); err != nil {
+		return fmt.Errorf("failed to write admin keyring: %w", err)
+	}
+
+	if _, stderr, err := sshPool.Run(ctx, node, "modprobe rbd"); err != nil {
+		log.Warnw("failed to load rbd kernel module (plugin may fail to map volumes)", "error", err, "stderr", strings.TrimSpace(stderr))
+	}
+
+	settings := []string{
+		"RBD_CONF_POOL=" + poolName,
+		"RBD_CONF_CLUSTER=ceph",
+		"RBD_CONF_KEYRING_USER=client.admin",
+	}
+
+	inspectCmd := fmt.Sprintf("docker plugin inspect %s --format '{{.Enabled}} {{range .Settings.Env}}{{.}} {{end}}'", driver)
+	stdout, _, err := sshPool.Run(ctx, node, inspectCmd)
+	if err != nil {
+		installCmd := fmt.Sprintf("docker plugin install --alias %s --grant-all-permissions %s %s",
+			driver, mcCfg.RBDVolumePlugin, strings.Join(settings, " "))
+		log.Infow("installing RBD volume plugin", "command", installCmd)
+		if _, stderr, err := sshPool.Run(ctx, node, installCmd); err != nil {
+			return fmt.Errorf("failed to install volume plugin: %w (stderr: %s)", err, stderr)
+		}
+		return nil
+	}
+
+	fields := strings.Fields(stdout)
+	enabled := len(fields) > 0 && fields[0] == "true"
+	if strings.Contains(stdout, "RBD_CONF_POOL="+poolName+" ") && enabled {
+		log.Infow("RBD volume plugin already installed")
+		return nil
+	}
+
+	// Settings can only be changed while the plugin is disabled
+	log.Infow("reconfiguring RBD volume plugin")
+	sshPool.Run(ctx, node, fmt.Sprintf("docker plugin disable -f %s 2>/dev/null || true", driver))
+	setCmd := fmt.Sprintf("docker plugin set %s %s", driver, strings.Join(settings, " "))
+	if _, stderr, err := sshPool.Run(ctx, node, setCmd); err != nil {
+		return fmt.Errorf("failed to configure volume plugin: %w (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := sshPool.Run(ctx, node, fmt.Sprintf("docker plugin enable %s", driver)); err != nil {
+		return fmt.Errorf("failed to enable volume plugin: %w (stderr: %s)", err, stderr)
+	}
+	return nil
+}
+
 // waitForCephFS waits until the given CephFS filesystem is reported by
 // `ceph fs ls -f json` or the timeout elapses.
 func (p *MicroCephProvider) waitForCephFS(ctx context.Context, sshPool *ssh.Pool, primaryNode, fsName string, timeout time.Duration) error {
@@ -1365,6 +1724,8 @@ const (
 	osdVerifyPollInterval     = 5 * time.Second  // How often to check OSD status
 	clusterHealthTimeout      = 5 * time.Minute  // Max time to wait for cluster to become healthy
 	clusterHealthPollInterval = 10 * time.Second // How often to check cluster health
+	osdDrainTimeout           = 2 * time.Hour    // Max time to wait for data to move off OSDs marked out
+	osdDrainPollInterval      = 30 * time.Second // How often to check whether drained OSDs are safe to destroy
 )
 
 // logDiskListJSON fetches and logs the MicroCeph disk list in JSON form so
@@ -1519,27 +1880,7 @@ func (p *MicroCephProvider) countOSDsForHost(tree cephOSDTreeJSON, hostname stri
 		nodeMap[n.ID] = n
 	}
 
-	// Find the host node (type=host, name matches hostname)
-	var hostNode *cephOSDTreeNode
-	for i, n := range tree.Nodes {
-		if n.Type == "host" {
-			// Match against full hostname, short hostname, or case-insensitive
-			if strings.EqualFold(n.Name, hostname) {
-				hostNode = &tree.Nodes[i]
-				break
-			}
-			// Also try matching short hostname
-			shortHost := hostname
-			if idx := strings.Index(hostname, "."); idx > 0 {
-				shortHost = hostname[:idx]
-			}
-			if strings.EqualFold(n.Name, shortHost) {
-				hostNode = &tree.Nodes[i]
-				break
-			}
-		}
-	}
-
+	hostNode := findOSDTreeHost(tree, hostname)
 	if hostNode == nil {
 		return false, 0, 0
 	}
@@ -1566,6 +1907,47 @@ func (p *MicroCephProvider) countOSDsForHost(tree cephOSDTreeJSON, hostname stri
 	return true, osdCount, upCount
 }
 
+// findOSDTreeHost returns the host bucket matching hostname (full or short,
+// case-insensitive), or nil if the host is not in the tree.
+func findOSDTreeHost(tree cephOSDTreeJSON, hostname string) *cephOSDTreeNode {
+	shortHost := hostname
+	if idx := strings.Index(hostname, "."); idx > 0 {
+		shortHost = hostname[:idx]
+	}
+	for i, n := range tree.Nodes {
+		if n.Type != "host" {
+			continue
+		}
+		if strings.EqualFold(n.Name, hostname) || strings.EqualFold(n.Name, shortHost) {
+			return &tree.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// osdIDsForHost returns the IDs of the existing OSDs under the given host.
+func osdIDsForHost(tree cephOSDTreeJSON, hostname string) []int {
+	hostNode := findOSDTreeHost(tree, hostname)
+	if hostNode == nil {
+		return nil
+	}
+
+	nodeMap := make(map[int]cephOSDTreeNode)
+	for _, n := range tree.Nodes {
+		nodeMap[n.ID] = n
+	}
+
+	var ids []int
+	for _, childID := range hostNode.Children {
+		child, ok := nodeMap[childID]
+		if !ok || child.Type != "osd" || child.Exists == 0 {
+			continue
+		}
+		ids = append(ids, child.ID)
+	}
+	return ids
+}
+
 // WaitForClusterHealth waits for the cluster to reach a healthy state with at least
 // the majority of expected OSDs up. This should be called after all disks are enrolled.
 func (p *MicroCephProvider) WaitForClusterHealth(ctx context.Context, sshPool *ssh.Pool, monNode string, expectedOSDs int) error {
@@ -1799,6 +2181,93 @@ func (p *MicroCephProvider) removeOSDsForNode(ctx context.Context, sshPool *ssh.
 	}
 }
 
+// DecommissionNode removes a node from a running MicroCeph cluster without
+// data loss. Its OSDs are marked out and the cluster rebalances until
+// `ceph osd safe-to-destroy` passes; then the OSDs are removed, the node leaves
+// the cluster membership and MicroCeph is purged from it.
+func (p *MicroCephProvider) DecommissionNode(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error {
+	log := logging.L().With("component", "microceph", "monNode", monNode, "node", node)
+
+	hostnameOut, stderr, err := sshPool.Run(ctx, node, "hostname -f 2>/dev/null || hostname")
+	if err != nil {
+		return fmt.Errorf("failed to determine hostname: %w (stderr: %s)", err, stderr)
+	}
+	hostname := strings.TrimSpace(hostnameOut)
+	shortHostname := hostname
+	if idx := strings.Index(hostname, "."); idx > 0 {
+		shortHostname = hostname[:idx]
+	}
+
+	if err := p.drainOSDs(ctx, sshPool, monNode, node, hostname); err != nil {
+		return err
+	}
+
+	removeCmd := fmt.Sprintf("microceph cluster remove %s", ssh.ShellQuote(shortHostname))
+	log.Infow("removing node from MicroCeph cluster", "command", removeCmd)
+	if _, stderr, err := sshPool.Run(ctx, monNode, removeCmd); err != nil {
+		if !strings.Contains(stderr, "not found") {
+			return fmt.Errorf("failed to remove node from MicroCeph cluster: %w (stderr: %s)", err, stderr)
+		}
+		log.Infow("node is not a MicroCeph cluster member", "hostname", shortHostname)
+	}
+
+	return p.Teardown(ctx, sshPool, node)
+}
+
+// drainOSDs marks a node's OSDs out, waits until `ceph osd safe-to-destroy`
+// passes and removes them. hostname is the node's CRUSH host name.
+func (p *MicroCephProvider) drainOSDs(ctx context.Context, sshPool *ssh.Pool, monNode, node, hostname string) error {
+	log := logging.L().With("component", "microceph", "monNode", monNode, "node", node)
+
+	stdout, stderr, err := sshPool.Run(ctx, monNode, "ceph osd tree --format json")
+	if err != nil {
+		return fmt.Errorf("failed to get OSD tree: %w (stderr: %s)", err, stderr)
+	}
+	var tree cephOSDTreeJSON
+	if err := json.Unmarshal([]byte(stdout), &tree); err != nil {
+		return fmt.Errorf("failed to parse OSD tree JSON: %w", err)
+	}
+
+	osdIDs := osdIDsForHost(tree, hostname)
+	if len(osdIDs) > 0 {
+		ids := make([]string, len(osdIDs))
+		for i, id := range osdIDs {
+			ids[i] = fmt.Sprintf("%d", id)
+		}
+		idList := strings.Join(ids, " ")
+
+		log.Infow("marking OSDs out", "osds", idList)
+		if _, stderr, err := sshPool.Run(ctx, monNode, "ceph osd out "+idList); err != nil {
+			return fmt.Errorf("failed to mark OSDs out: %w (stderr: %s)", err, stderr)
+		}
+
+		log.Infow("waiting for data to rebalance off OSDs", "osds", idList, "timeout", osdDrainTimeout)
+		deadline := time.Now().Add(osdDrainTimeout)
+		for {
+			stdout, stderr, err := sshPool.Run(ctx, monNode, "ceph osd safe-to-destroy "+idList+" 2>&1")
+			if err == nil {
+				log.Infow("✓ OSDs are safe to destroy", "osds", idList)
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out after %s waiting for OSDs %s to drain: %s", osdDrainTimeout, idList, strings.TrimSpace(stdout+stderr))
+			}
+			log.Infow("OSDs not yet safe to destroy", "osds", idList, "status", strings.TrimSpace(stdout))
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("context cancelled while waiting for OSDs to drain: %w", ctx.Err())
+			case <-time.After(osdDrainPollInterval):
+			}
+		}
+
+		p.removeOSDsForNode(ctx, sshPool, node)
+	} else {
+		log.Infow("no OSDs found for node", "hostname", hostname)
+	}
+	return nil
+}
+
 // Teardown removes MicroCeph from a node.
 // The teardown is designed to work even when the cluster is unhealthy:
 // 1. Stop local MicroCeph services first (no cluster communication needed)
@@ -1862,8 +2331,10 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 	// brittle text parsing. We fall back to the old text-based approach only if
 	// JSON is unavailable or cannot be parsed.
 	var (
-		healthy  bool
-		osdCount int
+		healthy        bool
+		healthStatus   string
+		osdCount       int
+		osdsUp, osdsIn int
 	)
 
 	cephStatusJSONCmd := "ceph status --format json"
@@ -1872,7 +2343,7 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 		var cs cephStatusJSON
 		if jsonErr := json.Unmarshal([]byte(cephJSONOut), &cs); jsonErr == nil {
 			// Determine health from JSON
-			healthStatus := strings.TrimSpace(cs.Health.Status)
+			healthStatus = strings.TrimSpace(cs.Health.Status)
 			if healthStatus == "" {
 				healthStatus = strings.TrimSpace(cs.Health.OverallStatus)
 			}
@@ -1896,12 +2367,14 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 			} else {
 				osdCount = cs.OSDMap.NumOSDs
 			}
+			osdsUp = cs.OSDMap.Nested.NumUpOSDs + cs.OSDMap.NumUpOSDs
+			osdsIn = cs.OSDMap.Nested.NumInOSDs + cs.OSDMap.NumInOSDs
 
 			log.Infow("Ceph cluster status (json)",
 				"health", healthStatus,
 				"osdCount", osdCount,
-				"osdsUp", cs.OSDMap.Nested.NumUpOSDs+cs.OSDMap.NumUpOSDs,
-				"osdsIn", cs.OSDMap.Nested.NumInOSDs+cs.OSDMap.NumInOSDs,
+				"osdsUp", osdsUp,
+				"osdsIn", osdsIn,
 			)
 		} else {
 			log.Warnw("failed to parse ceph status JSON; falling back to text status",
@@ -1931,7 +2404,9 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 			// Determine health from ceph status output
 			if strings.Contains(cephStdout, "HEALTH_OK") {
 				healthy = true
+				healthStatus = "HEALTH_OK"
 			} else if strings.Contains(cephStdout, "HEALTH_WARN") {
+				healthStatus = "HEALTH_WARN"
 				// HEALTH_WARN is acceptable for newly created clusters
 				healthy = true
 				log.Infow("cluster health is HEALTH_WARN (acceptable for new clusters)")
@@ -1944,9 +2419,13 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 					if strings.Contains(line, "osd:") && strings.Contains(line, "osds:") {
 						parts := strings.Fields(line)
 						for i, part := range parts {
-							if part == "osd:" && i+1 < len(parts) {
+							switch {
+							case part == "osd:" && i+1 < len(parts):
 								fmt.Sscanf(parts[i+1], "%d", &osdCount)
-								break
+							case part == "up" && i > 0:
+								fmt.Sscanf(parts[i-1], "%d", &osdsUp)
+							case strings.TrimSuffix(part, ";") == "in" && i > 0:
+								fmt.Sscanf(parts[i-1], "%d", &osdsIn)
 							}
 						}
 					}
@@ -1957,13 +2436,142 @@ func (p *MicroCephProvider) Status(ctx context.Context, sshPool *ssh.Pool, node
 
 	status := &ClusterStatus{
 		Healthy:   healthy,
+		Health:    healthStatus,
 		NodeCount: osdCount,
+		OSDsUp:    osdsUp,
+		OSDsIn:    osdsIn,
+	}
+
+	pools, err := p.poolStatus(ctx, sshPool, node)
+	if err != nil {
+		log.Warnw("failed to get pool status", "error", err)
+	}
+	if err := p.applyUsage(ctx, sshPool, node, status, pools); err != nil {
+		log.Warnw("failed to get storage usage", "error", err)
+	}
+	status.Pools = pools
+	for _, pool := range pools {
+		log.Infow("pool policy",
+			"pool", pool.Name,
+			"type", pool.Type,
+			"size", pool.Size,
+			"minSize", pool.MinSize,
+			"crushRule", pool.CrushRule,
+			"erasureCodeProfile", pool.ErasureCodeProfile,
+			"autoscaleMode", pool.AutoscaleMode,
+			"pgNum", pool.PGNum,
+		)
 	}
 
 	log.Infow("✓ cluster status verified", "healthy", status.Healthy, "osdCount", osdCount)
 	return status, nil
 }
 
+// cephPoolDetailJSON models an entry of `ceph osd pool ls detail --format json`.
+type cephPoolDetailJSON struct {
+	PoolName           string `json:"pool_name"`
+	Type               int    `json:"type"` // 1 = replicated, 3 = erasure
+	Size               int    `json:"size"`
+	MinSize            int    `json:"min_size"`
+	CrushRule          int    `json:"crush_rule"`
+	PGNum              int    `json:"pg_num"`
+	AutoscaleMode      string `json:"pg_autoscale_mode"`
+	ErasureCodeProfile string `json:"erasure_code_profile"`
+}
+
+// cephCrushRuleJSON models an entry of `ceph osd crush rule dump --format json`.
+type cephCrushRuleJSON struct {
+	RuleID   int    `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+}
+
+// poolStatus returns the replication policy in effect for every pool.
+func (p *MicroCephProvider) poolStatus(ctx context.Context, sshPool *ssh.Pool, node string) ([]PoolStatus, error) {
+	stdout, stderr, err := sshPool.Run(ctx, node, "ceph osd pool ls detail --format json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pools: %w (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+	var details []cephPoolDetailJSON
+	if err := json.Unmarshal([]byte(stdout), &details); err != nil {
+		return nil, fmt.Errorf("failed to parse pool list JSON: %w", err)
+	}
+
+	ruleNames := make(map[int]string)
+	if stdout, _, err := sshPool.Run(ctx, node, "ceph osd crush rule dump --format json"); err == nil {
+		var rules []cephCrushRuleJSON
+		if json.Unmarshal([]byte(stdout), &rules) == nil {
+			for _, rule := range rules {
+				ruleNames[rule.RuleID] = rule.RuleName
+			}
+		}
+	}
+
+	pools := make([]PoolStatus, 0, len(details))
+	for _, d := range details {
+		pool := PoolStatus{
+			Name:          d.PoolName,
+			Type:          "replicated",
+			Size:          d.Size,
+			MinSize:       d.MinSize,
+			CrushRule:     ruleNames[d.CrushRule],
+			AutoscaleMode: d.AutoscaleMode,
+			PGNum:         d.PGNum,
+		}
+		if pool.CrushRule == "" {
+			pool.CrushRule = fmt.Sprintf("%d", d.CrushRule)
+		}
+		if d.Type == 3 {
+			pool.Type = "erasure"
+			pool.ErasureCodeProfile = d.ErasureCodeProfile
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// cephDFJSON models the parts of `ceph df --format json` we use.
+type cephDFJSON struct {
+	Stats struct {
+		TotalBytes        int64 `json:"total_bytes"`
+		TotalUsedRawBytes int64 `json:"total_used_raw_bytes"`
+	} `json:"stats"`
+	Pools []struct {
+		Name  string `json:"name"`
+		Stats struct {
+			Stored      int64   `json:"stored"`
+			BytesUsed   int64   `json:"bytes_used"`
+			MaxAvail    int64   `json:"max_avail"`
+			PercentUsed float64 `json:"percent_used"`
+		} `json:"stats"`
+	} `json:"pools"`
+}
+
+// applyUsage fills the raw cluster capacity and per-pool usage from ceph df.
+func (p *MicroCephProvider) applyUsage(ctx context.Context, sshPool *ssh.Pool, node string, status *ClusterStatus, pools []PoolStatus) error {
+	stdout, stderr, err := sshPool.Run(ctx, node, "ceph df --format json")
+	if err != nil {
+		return fmt.Errorf("failed to get storage usage: %w (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+	var df cephDFJSON
+	if err := json.Unmarshal([]byte(stdout), &df); err != nil {
+		return fmt.Errorf("failed to parse storage usage JSON: %w", err)
+	}
+
+	status.StorageTotal = df.Stats.TotalBytes
+	status.StorageUsed = df.Stats.TotalUsedRawBytes
+	for i := range pools {
+		for _, dp := range df.Pools {
+			if dp.Name == pools[i].Name {
+				pools[i].StoredBytes = dp.Stats.Stored
+				pools[i].UsedBytes = dp.Stats.BytesUsed
+				pools[i].MaxAvailBytes = dp.Stats.MaxAvail
+				pools[i].PercentUsed = dp.Stats.PercentUsed
+			}
+		}
+	}
+	return nil
+}
+
 // EnableRadosGateway enables RADOS Gateway (S3-compatible) on the specified OSD nodes.
 // RGW is enabled on workers (OSD nodes) only. Each node runs the enable command locally
 // using $(hostname) for proper targeting. Individual node failures are non-fatal.
@@ -2258,9 +2866,8 @@ use_https = False
 signature_v2 = True
 `, accessKey, secretKey, rgwPort, rgwPort)
 
-	configureCmd := fmt.Sprintf("cat > /tmp/.s3cfg << 'EOF'\n%sEOF", s3cfgContent)
-	if _, stderr, err := sshPool.Run(ctx, primaryOSD, configureCmd); err != nil {
-		return fmt.Errorf("failed to configure s3cmd: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, primaryOSD, "/tmp/.s3cfg", []byte(s3cfgContent), 0600); err != nil {
+		return fmt.Errorf("failed to configure s3cmd: %w", err)
 	}
 
 	// Create the bucket using s3cmd
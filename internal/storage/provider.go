@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"dscotctl/internal/config"
 	"dscotctl/internal/logging"
@@ -56,6 +57,21 @@ type Provider interface {
 	// CreatePool creates a storage pool/filesystem for use by containers.
 	CreatePool(ctx context.Context, sshPool *ssh.Pool, primaryNode, poolName string) error
 
+	// ApplyTopology places OSD hosts into their configured failure domain
+	// buckets (region, datacenter, rack). monNode is a MON node to run commands on.
+	ApplyTopology(ctx context.Context, sshPool *ssh.Pool, monNode string) error
+
+	// ApplyDataLayout points new files on the mounted filesystem at the
+	// configured data pool. node must have the filesystem mounted.
+	ApplyDataLayout(ctx context.Context, sshPool *ssh.Pool, node, poolName string) error
+
+	// CreateBlockPool creates a pool for block (RBD) volumes.
+	CreateBlockPool(ctx context.Context, sshPool *ssh.Pool, primaryNode, poolName string) error
+
+	// InstallVolumePlugin configures a node as a block storage client and installs
+	// the Docker volume plugin that creates block volumes in poolName.
+	InstallVolumePlugin(ctx context.Context, sshPool *ssh.Pool, node, poolName string, creds *ClusterCredentials) error
+
 	// GetClusterCredentials retrieves cluster credentials (admin key, mon addresses) from the primary node.
 	// Uses overlay hostname precedence: overlay hostname > overlay IP > private hostname > private IP.
 	// monNodes is the list of MON node SSH hostnames (for resolving overlay addresses).
@@ -75,9 +91,58 @@ type Provider interface {
 	// Teardown removes the storage cluster from a node.
 	Teardown(ctx context.Context, sshPool *ssh.Pool, node string) error
 
+	// DecommissionNode removes a single node from a running cluster. Its OSDs
+	// are drained and removed before the node leaves, so no data is lost.
+	// monNode is a MON node that stays in the cluster.
+	DecommissionNode(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error
+
+	// NodeStorageRoles reports whether a node runs a MON and whether it holds OSDs.
+	NodeStorageRoles(ctx context.Context, sshPool *ssh.Pool, monNode, node string) (mon, osd bool, err error)
+
+	// EnableMON starts a MON on a cluster member (a node promoted to manager).
+	EnableMON(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error
+
+	// DisableMON removes a node's MON (a manager demoted to worker). It is
+	// refused if the remaining MONs would lose quorum. monNode must be another MON.
+	DisableMON(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error
+
+	// RemoveStorage drains and removes a node's OSDs without removing the node
+	// from the cluster (a worker promoted to manager). monNode must be another MON.
+	RemoveStorage(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error
+
+	// ApplySnapshotSchedules configures scheduled filesystem snapshots with
+	// retention. clientNode must have the filesystem mounted.
+	ApplySnapshotSchedules(ctx context.Context, sshPool *ssh.Pool, monNode, clientNode, poolName string) error
+
+	// ListSnapshots returns the snapshots of a directory relative to the filesystem root.
+	ListSnapshots(ctx context.Context, sshPool *ssh.Pool, clientNode, path string) ([]string, error)
+
+	// CreateSnapshot takes a named snapshot of a directory relative to the filesystem root.
+	CreateSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error
+
+	// DeleteSnapshot removes a named snapshot of a directory relative to the filesystem root.
+	DeleteSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error
+
+	// RestoreSnapshot replaces the contents of a directory relative to the
+	// filesystem root with a snapshot. Writers must be stopped beforehand.
+	RestoreSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error
+
 	// Status returns the status of the storage cluster.
 	Status(ctx context.Context, sshPool *ssh.Pool, node string) (*ClusterStatus, error)
 
+	// InstalledChannel returns the release channel the storage software on a node tracks.
+	InstalledChannel(ctx context.Context, sshPool *ssh.Pool, node string) (string, error)
+
+	// ValidateUpgrade returns an error if moving a cluster from one channel to
+	// another is not supported (downgrades, skipped releases, unknown tracks).
+	ValidateUpgrade(from, to string) error
+
+	// Upgrade moves the storage software on a single node to channel.
+	Upgrade(ctx context.Context, sshPool *ssh.Pool, node, channel string) error
+
+	// FinalizeUpgrade runs cluster-wide steps once every node runs channel.
+	FinalizeUpgrade(ctx context.Context, sshPool *ssh.Pool, monNode, channel string) error
+
 	// EnableRadosGateway enables the RADOS Gateway (S3-compatible) on specified OSD nodes.
 	// overlayProvider is used for hostname precedence: overlay hostname > overlay IP > private hostname > private IP.
 	// Returns the S3 endpoint URL and credentials.
@@ -111,10 +176,30 @@ type ClusterCredentials struct {
 // ClusterStatus represents the status of a storage cluster.
 type ClusterStatus struct {
 	Healthy      bool
+	Health       string // Raw health state, e.g. HEALTH_OK
 	NodeCount    int
+	OSDsUp       int
+	OSDsIn       int
 	StorageUsed  int64
 	StorageTotal int64
 	Nodes        []NodeStatus
+	Pools        []PoolStatus
+}
+
+// PoolStatus reports the replication policy in effect for a storage pool and its usage.
+type PoolStatus struct {
+	Name               string
+	Type               string // "replicated" or "erasure"
+	Size               int    // Copies (replicated) or k+m chunks (erasure)
+	MinSize            int
+	CrushRule          string
+	ErasureCodeProfile string
+	AutoscaleMode      string
+	PGNum              int
+	StoredBytes        int64   // Data stored, before replication
+	UsedBytes          int64   // Raw space used, including replicas
+	MaxAvailBytes      int64   // Space still available to the pool
+	PercentUsed        float64 // Fraction of the pool's capacity used (0-1)
 }
 
 // NodeStatus represents the status of a storage node.
@@ -378,6 +463,14 @@ func SetupCluster(ctx context.Context, sshPool *ssh.Pool, provider Provider, man
 	}
 	log.Infow("✓ storage pool created", "poolName", ds.PoolName)
 
+	if ds.Providers.MicroCeph.EnableRBD {
+		rbdPool := ds.Providers.MicroCeph.RBDPoolName
+		if err := provider.CreateBlockPool(ctx, sshPool, primaryNode, rbdPool); err != nil {
+			return fmt.Errorf("failed to create RBD pool: %w", err)
+		}
+		log.Infow("✓ RBD pool created", "poolName", rbdPool)
+	}
+
 	// Get cluster credentials from primary (admin key, mon addresses) for mounting
 	// Uses overlay hostname precedence: overlay hostname > overlay IP > private
 	overlayProvider := strings.ToLower(strings.TrimSpace(cfg.GlobalSettings.OverlayProvider))
@@ -410,6 +503,15 @@ func SetupCluster(ctx context.Context, sshPool *ssh.Pool, provider Provider, man
 		log.Infow(fmtNode("✓", node, "storage mounted"))
 	}
 
+	if len(workers) > 0 {
+		if err := provider.ApplyDataLayout(ctx, sshPool, workers[0], ds.PoolName); err != nil {
+			return fmt.Errorf("failed to apply data layout: %w", err)
+		}
+		if err := provider.ApplySnapshotSchedules(ctx, sshPool, primaryNode, workers[0], ds.PoolName); err != nil {
+			return fmt.Errorf("failed to apply snapshot schedules: %w", err)
+		}
+	}
+
 	// Create scripts folder and mount helper script on shared storage
 	if len(workers) > 0 {
 		scriptFullPath := mountPath + "/scripts/mount-cephfs.sh"
@@ -486,6 +588,97 @@ func SetupCluster(ctx context.Context, sshPool *ssh.Pool, provider Provider, man
 	return nil
 }
 
+// JoinNode adds a single node to a running storage cluster: it installs the
+// provider, joins the node via monNode, adds OSD storage for worker/both roles
+// and mounts the filesystem on OSD nodes. monNodes is the full MON list used
+// for the mount credentials.
+func JoinNode(ctx context.Context, sshPool *ssh.Pool, provider Provider, cfg *config.Config, monNode string, monNodes []string, info NodeInfo) error {
+	log := logging.L().With("component", "storage-join", "provider", provider.Name())
+	ds := cfg.GetDistributedStorage()
+	node := info.SSHFQDNorIP
+	fmtNode := func(prefix, message string) string {
+		return logging.FormatNodeMessage(prefix, info.SSHFQDNorIP, info.NewHostname, info.Role, message)
+	}
+
+	log.Infow(fmtNode("→", "installing MicroCeph"))
+	if err := provider.Install(ctx, sshPool, node); err != nil {
+		return fmt.Errorf("failed to install storage on %s: %w", node, err)
+	}
+
+	log.Infow(fmtNode("→", "joining storage cluster"), "monNode", monNode)
+	token, err := provider.GenerateJoinToken(ctx, sshPool, monNode, node)
+	if err != nil {
+		return fmt.Errorf("failed to generate join token for %s: %w", node, err)
+	}
+	if err := provider.Join(ctx, sshPool, node, token); err != nil {
+		return fmt.Errorf("failed to join %s to storage cluster: %w", node, err)
+	}
+	log.Infow(fmtNode("✓", "joined storage cluster"))
+
+	role := strings.ToLower(strings.TrimSpace(info.Role))
+	if role == "manager" {
+		return nil
+	}
+
+	log.Infow(fmtNode("→", "adding storage"))
+	if err := provider.AddStorage(ctx, sshPool, node); err != nil {
+		return fmt.Errorf("failed to add storage on %s: %w", node, err)
+	}
+	hostnameOut, _, _ := sshPool.Run(ctx, node, "hostname -f 2>/dev/null || hostname")
+	osdHostname := strings.TrimSpace(hostnameOut)
+	if osdHostname == "" {
+		osdHostname = node
+	}
+	if err := provider.VerifyOSDsUpForHost(ctx, sshPool, monNode, node, osdHostname); err != nil {
+		log.Warnw(fmtNode("⚠", "OSD verification failed (continuing)"), "error", err)
+	} else {
+		log.Infow(fmtNode("✓", "OSD verified up"))
+	}
+
+	if err := provider.ApplyTopology(ctx, sshPool, monNode); err != nil {
+		return fmt.Errorf("failed to place node in CRUSH topology: %w", err)
+	}
+
+	if err := provider.VerifyClusterHealthForMount(ctx, sshPool, monNode); err != nil {
+		return fmt.Errorf("cluster health check failed before mount: %w", err)
+	}
+	overlayProvider := strings.ToLower(strings.TrimSpace(cfg.GlobalSettings.OverlayProvider))
+	creds, err := provider.GetClusterCredentials(ctx, sshPool, monNode, monNodes, overlayProvider)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	log.Infow(fmtNode("→", "mounting storage"), "mountPath", provider.GetMountPath())
+	if err := provider.MountWithCredentials(ctx, sshPool, node, ds.PoolName, creds); err != nil {
+		return fmt.Errorf("failed to mount storage on %s: %w", node, err)
+	}
+	log.Infow(fmtNode("✓", "storage mounted"))
+
+	return nil
+}
+
+// SetupVolumePlugin installs the block volume plugin on nodes (every Swarm
+// node, not just storage nodes) so block volumes can follow tasks anywhere.
+// Credentials are fetched once from monNode.
+func SetupVolumePlugin(ctx context.Context, sshPool *ssh.Pool, provider Provider, cfg *config.Config, monNode string, monNodes, nodes []string) error {
+	log := logging.L().With("component", "storage-rbd", "provider", provider.Name())
+	mcCfg := cfg.GetDistributedStorage().Providers.MicroCeph
+
+	overlayProvider := strings.ToLower(strings.TrimSpace(cfg.GlobalSettings.OverlayProvider))
+	creds, err := provider.GetClusterCredentials(ctx, sshPool, monNode, monNodes, overlayProvider)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+
+	for i, node := range nodes {
+		log.Infow(fmt.Sprintf("→ [%s] installing RBD volume plugin (%d/%d)", node, i+1, len(nodes)), "plugin", mcCfg.RBDVolumePlugin, "pool", mcCfg.RBDPoolName)
+		if err := provider.InstallVolumePlugin(ctx, sshPool, node, mcCfg.RBDPoolName, creds); err != nil {
+			return fmt.Errorf("failed to install RBD volume plugin on %s: %w", node, err)
+		}
+		log.Infow(fmt.Sprintf("✓ [%s] RBD volume plugin ready", node))
+	}
+	return nil
+}
+
 // S3Credentials represents the S3 credentials file format.
 type S3Credentials struct {
 	Endpoints  []string `json:"endpoints"`
@@ -519,9 +712,8 @@ func writeS3CredentialsFile(ctx context.Context, sshPool *ssh.Pool, node, path s
 		return fmt.Errorf("failed to create credentials directory: %w (stderr: %s)", err, stderr)
 	}
 
-	writeCmd := fmt.Sprintf("cat > '%s' << 'EOF'\n%s\nEOF", linuxPath, string(data))
-	if _, stderr, err := sshPool.Run(ctx, node, writeCmd); err != nil {
-		return fmt.Errorf("failed to write credentials file: %w (stderr: %s)", err, stderr)
+	if err := sshPool.WriteFile(ctx, node, linuxPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
 	}
 
 	return nil
@@ -556,9 +748,10 @@ df -h "$MOUNT_PATH"
 `, mountPath, creds.AdminKey, creds.FSID, creds.FSName, creds.MonAddrOpt)
 
 	// Write the script via SSH with timeout
-	writeCmd := fmt.Sprintf("timeout 30 bash -c 'cat > %s << '\"'\"'SCRIPT_EOF'\"'\"'\n%sSCRIPT_EOF'", scriptPath, script)
-	if _, stderr, err := sshPool.Run(ctx, node, writeCmd); err != nil {
-		return fmt.Errorf("failed to write script (timeout or error): %w (stderr: %s)", err, stderr)
+	writeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := sshPool.WriteFile(writeCtx, node, scriptPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write script (timeout or error): %w", err)
 	}
 
 	// Make executable with timeout
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"dscotctl/internal/logging"
+	"dscotctl/internal/retry"
+	"dscotctl/internal/ssh"
+)
+
+// cephQuorumStatusJSON models the parts of `ceph quorum_status --format json` we use.
+type cephQuorumStatusJSON struct {
+	QuorumNames []string `json:"quorum_names"`
+	MonMap      struct {
+		Mons []struct {
+			Name string `json:"name"`
+		} `json:"mons"`
+	} `json:"monmap"`
+}
+
+// nodeHostnames returns a node's full and short hostname.
+func nodeHostnames(ctx context.Context, sshPool *ssh.Pool, node string) (full, short string, err error) {
+	stdout, stderr, err := sshPool.Run(ctx, node, "hostname -f 2>/dev/null || hostname")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to determine hostname of %s: %w (stderr: %s)", node, err, stderr)
+	}
+	full = strings.TrimSpace(stdout)
+	short = full
+	if idx := strings.Index(full, "."); idx > 0 {
+		short = full[:idx]
+	}
+	return full, short, nil
+}
+
+// quorumStatus returns the MON quorum as seen by monNode.
+func (p *MicroCephProvider) quorumStatus(ctx context.Context, sshPool *ssh.Pool, monNode string) (*cephQuorumStatusJSON, error) {
+	stdout, stderr, err := sshPool.Run(ctx, monNode, "ceph quorum_status --format json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MON quorum status: %w (stderr: %s)", err, stderr)
+	}
+	var status cephQuorumStatusJSON
+	if err := json.Unmarshal([]byte(stdout), &status); err != nil {
+		return nil, fmt.Errorf("failed to parse MON quorum status JSON: %w", err)
+	}
+	return &status, nil
+}
+
+// NodeStorageRoles reports whether a node runs a MON and whether it holds OSDs.
+func (p *MicroCephProvider) NodeStorageRoles(ctx context.Context, sshPool *ssh.Pool, monNode, node string) (mon, osd bool, err error) {
+	full, short, err := nodeHostnames(ctx, sshPool, node)
+	if err != nil {
+		return false, false, err
+	}
+
+	status, err := p.quorumStatus(ctx, sshPool, monNode)
+	if err != nil {
+		return false, false, err
+	}
+	for _, m := range status.MonMap.Mons {
+		if strings.EqualFold(m.Name, short) || strings.EqualFold(m.Name, full) {
+			mon = true
+		}
+	}
+
+	stdout, stderr, err := sshPool.Run(ctx, monNode, "ceph osd tree --format json")
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get OSD tree: %w (stderr: %s)", err, stderr)
+	}
+	var tree cephOSDTreeJSON
+	if err := json.Unmarshal([]byte(stdout), &tree); err != nil {
+		return false, false, fmt.Errorf("failed to parse OSD tree JSON: %w", err)
+	}
+	osd = len(osdIDsForHost(tree, full)) > 0
+
+	return mon, osd, nil
+}
+
+// EnableMON starts a MON on a cluster member and waits until it joins the quorum.
+func (p *MicroCephProvider) EnableMON(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error {
+	log := logging.L().With("component", "microceph-roles", "node", node)
+
+	_, short, err := nodeHostnames(ctx, sshPool, node)
+	if err != nil {
+		return err
+	}
+
+	enableCmd := fmt.Sprintf("microceph enable mon --target %s", ssh.ShellQuote(short))
+	log.Infow("enabling MON", "command", enableCmd)
+	if _, stderr, err := sshPool.Run(ctx, monNode, enableCmd); err != nil {
+		return fmt.Errorf("failed to enable MON on %s: %w (stderr: %s)", node, err, stderr)
+	}
+
+	retryCfg := retry.DefaultConfig(fmt.Sprintf("mon-quorum-%s", node))
+	if err := retry.Do(ctx, retryCfg, func() error {
+		status, err := p.quorumStatus(ctx, sshPool, monNode)
+		if err != nil {
+			return err
+		}
+		for _, name := range status.QuorumNames {
+			if strings.EqualFold(name, short) {
+				return nil
+			}
+		}
+		return fmt.Errorf("MON %s not in quorum yet", short)
+	}); err != nil {
+		return err
+	}
+
+	log.Infow("✓ MON enabled and in quorum")
+	return nil
+}
+
+// DisableMON stops a node's MON and removes it from the monmap. It is refused
+// if the remaining MONs would not keep a quorum. monNode must be another MON.
+func (p *MicroCephProvider) DisableMON(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error {
+	log := logging.L().With("component", "microceph-roles", "node", node, "monNode", monNode)
+
+	full, short, err := nodeHostnames(ctx, sshPool, node)
+	if err != nil {
+		return err
+	}
+	name := ""
+	status, err := p.quorumStatus(ctx, sshPool, monNode)
+	if err != nil {
+		return err
+	}
+	for _, m := range status.MonMap.Mons {
+		if strings.EqualFold(m.Name, short) || strings.EqualFold(m.Name, full) {
+			name = m.Name
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	remaining := len(status.MonMap.Mons) - 1
+	inQuorum := 0
+	for _, q := range status.QuorumNames {
+		if q != name {
+			inQuorum++
+		}
+	}
+	if remaining == 0 || inQuorum < remaining/2+1 {
+		return fmt.Errorf("removing MON %s would break storage quorum: %d of %d remaining MONs in quorum", name, inQuorum, remaining)
+	}
+
+	log.Infow("stopping MON", "mon", name)
+	if _, stderr, err := sshPool.Run(ctx, node, "snap stop --disable microceph.mon"); err != nil {
+		return fmt.Errorf("failed to stop MON on %s: %w (stderr: %s)", node, err, stderr)
+	}
+	removeCmd := fmt.Sprintf("ceph mon remove %s", ssh.ShellQuote(name))
+	if _, stderr, err := sshPool.Run(ctx, monNode, removeCmd); err != nil {
+		return fmt.Errorf("failed to remove MON %s: %w (stderr: %s)", name, err, stderr)
+	}
+
+	// Wait for the smaller quorum to settle before anything else changes
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(5 * time.Second):
+	}
+	if _, err := p.quorumStatus(ctx, sshPool, monNode); err != nil {
+		return fmt.Errorf("storage quorum not available after removing MON %s: %w", name, err)
+	}
+
+	log.Infow("✓ MON removed", "mon", name)
+	return nil
+}
+
+// RemoveStorage drains a node's OSDs until Ceph reports them safe to destroy
+// and removes them; the node stays a cluster member. monNode must be another MON.
+func (p *MicroCephProvider) RemoveStorage(ctx context.Context, sshPool *ssh.Pool, monNode, node string) error {
+	full, _, err := nodeHostnames(ctx, sshPool, node)
+	if err != nil {
+		return err
+	}
+	return p.drainOSDs(ctx, sshPool, monNode, node, full)
+}
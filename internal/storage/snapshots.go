@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+)
+
+// snapshotPeriods maps snapshot schedule periods to their snap_schedule
+// interval and retention unit.
+var snapshotPeriods = []struct {
+	Name     string
+	Interval string
+	Unit     string
+}{
+	{"hourly", "1h", "h"},
+	{"daily", "1d", "d"},
+	{"weekly", "1w", "w"},
+}
+
+// cephSnapScheduleJSON models an entry of `ceph fs snap-schedule status --format=json`.
+type cephSnapScheduleJSON struct {
+	Path      string         `json:"path"`
+	Schedule  string         `json:"schedule"`
+	Retention map[string]int `json:"retention"`
+	Active    bool           `json:"active"`
+}
+
+// ApplySnapshotSchedules configures the CephFS snapshot schedules from the
+// configuration with the snap_schedule manager module. Periods set to 0 are
+// removed from configured paths; paths no longer configured are left alone.
+// clientNode must have the filesystem mounted so scheduled directories exist.
+func (p *MicroCephProvider) ApplySnapshotSchedules(ctx context.Context, sshPool *ssh.Pool, monNode, clientNode, poolName string) error {
+	schedules := p.cfg.GetDistributedStorage().Providers.MicroCeph.Snapshots
+	if len(schedules) == 0 {
+		return nil
+	}
+
+	fsName := poolName
+	mountPath := p.GetMountPath()
+	log := logging.L().With("component", "microceph-snapshots", "node", monNode, "fsName", fsName)
+
+	if _, stderr, err := sshPool.Run(ctx, monNode, "ceph mgr module enable snap_schedule"); err != nil {
+		return fmt.Errorf("failed to enable snap_schedule module: %w (stderr: %s)", err, stderr)
+	}
+
+	for _, schedule := range schedules {
+		path := schedule.Path()
+		pathLog := log.With("path", path)
+
+		mkdirCmd := fmt.Sprintf("mkdir -p %s", ssh.ShellQuote(mountPath+path))
+		if _, stderr, err := sshPool.Run(ctx, clientNode, mkdirCmd); err != nil {
+			return fmt.Errorf("failed to create %s: %w (stderr: %s)", path, err, stderr)
+		}
+
+		existing, err := p.snapshotScheduleStatus(ctx, sshPool, monNode, fsName, path)
+		if err != nil {
+			return err
+		}
+		scheduled := make(map[string]bool)
+		retention := make(map[string]int)
+		for _, s := range existing {
+			if s.Path == path {
+				scheduled[s.Schedule] = true
+				for unit, count := range s.Retention {
+					retention[unit] = count
+				}
+			}
+		}
+
+		counts := map[string]int{"hourly": schedule.Hourly, "daily": schedule.Daily, "weekly": schedule.Weekly}
+		for _, period := range snapshotPeriods {
+			keep := counts[period.Name]
+			var cmds []string
+
+			if keep == 0 {
+				if scheduled[period.Interval] {
+					cmds = append(cmds, fmt.Sprintf("ceph fs snap-schedule remove %s %s --fs %s", path, period.Interval, fsName))
+				}
+				if count := retention[period.Unit]; count > 0 {
+					cmds = append(cmds, fmt.Sprintf("ceph fs snap-schedule retention remove %s %s %d --fs %s", path, period.Unit, count, fsName))
+				}
+			} else {
+				if !scheduled[period.Interval] {
+					cmds = append(cmds, fmt.Sprintf("ceph fs snap-schedule add %s %s --fs %s", path, period.Interval, fsName))
+				}
+				if count := retention[period.Unit]; count != keep {
+					if count > 0 {
+						cmds = append(cmds, fmt.Sprintf("ceph fs snap-schedule retention remove %s %s %d --fs %s", path, period.Unit, count, fsName))
+					}
+					cmds = append(cmds, fmt.Sprintf("ceph fs snap-schedule retention add %s %s %d --fs %s", path, period.Unit, keep, fsName))
+				}
+			}
+
+			for _, cmd := range cmds {
+				if _, stderr, err := sshPool.Run(ctx, monNode, cmd); err != nil {
+					return fmt.Errorf("failed to configure %s snapshots of %s: %w (stderr: %s)", period.Name, path, err, stderr)
+				}
+			}
+		}
+
+		activateCmd := fmt.Sprintf("ceph fs snap-schedule activate %s --fs %s", path, fsName)
+		if _, stderr, err := sshPool.Run(ctx, monNode, activateCmd); err != nil {
+			return fmt.Errorf("failed to activate snapshots of %s: %w (stderr: %s)", path, err, stderr)
+		}
+		pathLog.Infow("✓ snapshot schedule applied", "hourly", schedule.Hourly, "daily", schedule.Daily, "weekly", schedule.Weekly)
+	}
+
+	return nil
+}
+
+// snapshotScheduleStatus returns the schedules configured for path.
+func (p *MicroCephProvider) snapshotScheduleStatus(ctx context.Context, sshPool *ssh.Pool, monNode, fsName, path string) ([]cephSnapScheduleJSON, error) {
+	cmd := fmt.Sprintf("ceph fs snap-schedule status %s --fs %s --format=json", path, fsName)
+	stdout, stderr, err := sshPool.Run(ctx, monNode, cmd)
+	if err != nil {
+		// No schedule for the path yet
+		if strings.Contains(stderr, "No such file") || strings.Contains(stderr, "ENOENT") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get snapshot schedules for %s: %w (stderr: %s)", path, err, stderr)
+	}
+	if strings.TrimSpace(stdout) == "" {
+		return nil, nil
+	}
+
+	var schedules []cephSnapScheduleJSON
+	if err := json.Unmarshal([]byte(stdout), &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot schedules JSON: %w", err)
+	}
+	return schedules, nil
+}
+
+// ListSnapshots returns the snapshots of a directory relative to the CephFS
+// root, including those taken of its parents, sorted by name.
+func (p *MicroCephProvider) ListSnapshots(ctx context.Context, sshPool *ssh.Pool, clientNode, path string) ([]string, error) {
+	snapDir := p.GetMountPath() + path + "/.snap"
+
+	stdout, stderr, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("ls -1 %s", ssh.ShellQuote(snapDir)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w (stderr: %s)", path, err, stderr)
+	}
+
+	var snapshots []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			snapshots = append(snapshots, name)
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// CreateSnapshot takes a named snapshot of a directory relative to the CephFS root.
+func (p *MicroCephProvider) CreateSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error {
+	if err := validateSnapshotName(snapshot); err != nil {
+		return err
+	}
+
+	snapDir := p.GetMountPath() + path + "/.snap/" + snapshot
+	if _, stderr, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("mkdir %s", ssh.ShellQuote(snapDir))); err != nil {
+		return fmt.Errorf("failed to create snapshot %s of %s: %w (stderr: %s)", snapshot, path, err, stderr)
+	}
+	return nil
+}
+
+// DeleteSnapshot removes a named snapshot of a directory relative to the CephFS root.
+func (p *MicroCephProvider) DeleteSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error {
+	if err := validateSnapshotName(snapshot); err != nil {
+		return err
+	}
+
+	snapDir := p.GetMountPath() + path + "/.snap/" + snapshot
+	if _, stderr, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("rmdir %s", ssh.ShellQuote(snapDir))); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s of %s: %w (stderr: %s)", snapshot, path, err, stderr)
+	}
+	return nil
+}
+
+// validateSnapshotName rejects names that would escape the .snap directory.
+func validateSnapshotName(snapshot string) error {
+	if snapshot == "" || strings.Contains(snapshot, "/") || snapshot == "." || snapshot == ".." {
+		return fmt.Errorf("invalid snapshot name %q", snapshot)
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the contents of a directory relative to the CephFS
+// root with a snapshot. A "pre-restore" snapshot of the current contents is
+// taken first so the restore can be undone. Writers must be stopped.
+func (p *MicroCephProvider) RestoreSnapshot(ctx context.Context, sshPool *ssh.Pool, clientNode, path, snapshot string) error {
+	if err := validateSnapshotName(snapshot); err != nil {
+		return err
+	}
+
+	dir := p.GetMountPath() + path
+	snapDir := dir + "/.snap/" + snapshot
+	log := logging.L().With("component", "microceph-snapshots", "node", clientNode, "path", path, "snapshot", snapshot)
+
+	if _, _, err := sshPool.Run(ctx, clientNode, fmt.Sprintf("test -d %s", ssh.ShellQuote(snapDir))); err != nil {
+		return fmt.Errorf("snapshot %q of %s not found", snapshot, path)
+	}
+
+	preRestore := "pre-restore-" + time.Now().UTC().Format("2006-01-02-15_04_05")
+	if err := p.CreateSnapshot(ctx, sshPool, clientNode, path, preRestore); err != nil {
+		return fmt.Errorf("failed to snapshot current contents: %w", err)
+	}
+	log.Infow("✓ current contents saved", "snapshot", preRestore)
+
+	installCmd := "command -v rsync >/dev/null 2>&1 || DEBIAN_FRONTEND=noninteractive apt-get install -y -q rsync"
+	if _, stderr, err := sshPool.Run(ctx, clientNode, installCmd); err != nil {
+		return fmt.Errorf("failed to install rsync: %w (stderr: %s)", err, stderr)
+	}
+
+	// .snap is hidden from directory listings, so rsync neither copies nor deletes it
+	rsyncCmd := fmt.Sprintf("rsync -a --delete %s/ %s/", ssh.ShellQuote(snapDir), ssh.ShellQuote(dir))
+	log.Infow("restoring snapshot", "command", rsyncCmd)
+	if _, stderr, err := sshPool.Run(ctx, clientNode, rsyncCmd); err != nil {
+		return fmt.Errorf("failed to restore snapshot (current contents saved as %s): %w (stderr: %s)", preRestore, err, stderr)
+	}
+
+	log.Infow("✓ snapshot restored", "undo", preRestore)
+	return nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dscotctl/internal/logging"
+	"dscotctl/internal/ssh"
+)
+
+// UpgradeCluster moves a running storage cluster to channel in Ceph's
+// supported order: MON nodes first, then the remaining OSD nodes, one node at
+// a time. The cluster must be healthy before the run and after every node.
+// Nodes already on channel are skipped, so an interrupted run can be repeated.
+// The noout flag is set for the duration so Ceph does not start rebalancing
+// while a node's OSDs restart.
+// nodeInfoMap provides node metadata for formatted logging (optional, can be nil).
+func UpgradeCluster(ctx context.Context, sshPool *ssh.Pool, provider Provider, managers, workers []string, channel string, nodeInfoMap map[string]NodeInfo) error {
+	log := logging.L().With("component", "storage-upgrade", "provider", provider.Name())
+
+	if len(managers) == 0 {
+		return fmt.Errorf("at least one manager (MON) node is required")
+	}
+
+	fmtNode := func(prefix, node, message string) string {
+		if info, ok := nodeInfoMap[node]; ok {
+			return logging.FormatNodeMessage(prefix, info.SSHFQDNorIP, info.NewHostname, info.Role, message)
+		}
+		return logging.FormatNodeMessage(prefix, node, "", "", message)
+	}
+
+	// MONs first, then OSD nodes that are not also MONs ("both" nodes)
+	var order []string
+	seen := make(map[string]bool)
+	for _, node := range append(append([]string{}, managers...), workers...) {
+		if !seen[node] {
+			seen[node] = true
+			order = append(order, node)
+		}
+	}
+
+	// Check every node before touching any, so an unsupported jump fails early
+	pending := make(map[string]bool)
+	for _, node := range order {
+		current, err := provider.InstalledChannel(ctx, sshPool, node)
+		if err != nil {
+			return err
+		}
+		if current == channel {
+			log.Infow(fmtNode("✓", node, "already on target channel"), "channel", channel)
+			continue
+		}
+		if err := provider.ValidateUpgrade(current, channel); err != nil {
+			return fmt.Errorf("%s: %w", node, err)
+		}
+		pending[node] = true
+	}
+	if len(pending) == 0 {
+		log.Infow("✅ all storage nodes already on target channel", "channel", channel)
+		return provider.FinalizeUpgrade(ctx, sshPool, managers[0], channel)
+	}
+
+	// Expected OSD count matches SetupCluster: one per worker (including "both")
+	expectedOSDs := len(workers)
+
+	log.Infow("→ verifying cluster health before upgrade")
+	if err := provider.WaitForClusterHealth(ctx, sshPool, managers[0], expectedOSDs); err != nil {
+		return fmt.Errorf("cluster not healthy before upgrade: %w", err)
+	}
+
+	if _, stderr, err := sshPool.Run(ctx, managers[0], "ceph osd set noout"); err != nil {
+		return fmt.Errorf("failed to set Ceph noout: %w (stderr: %s)", err, stderr)
+	}
+	log.Infow("✓ Ceph noout set")
+	defer func() {
+		// Unset even if the upgrade was interrupted; any MON will do
+		unsetCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		for _, mon := range managers {
+			if _, _, err := sshPool.Run(unsetCtx, mon, "ceph osd unset noout"); err == nil {
+				log.Infow("✓ Ceph noout unset")
+				return
+			}
+		}
+		log.Errorw("failed to unset Ceph noout; run 'ceph osd unset noout' on a MON node")
+	}()
+
+	for i, node := range order {
+		if !pending[node] {
+			continue
+		}
+
+		// Query health from another MON while this one restarts
+		monNode := managers[0]
+		if monNode == node && len(managers) > 1 {
+			monNode = managers[1]
+		}
+
+		log.Infow(fmtNode("→", node, fmt.Sprintf("upgrading (%d/%d)", i+1, len(order))), "channel", channel)
+		if err := provider.Upgrade(ctx, sshPool, node, channel); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", node, err)
+		}
+
+		if err := provider.WaitForClusterHealth(ctx, sshPool, monNode, expectedOSDs); err != nil {
+			return fmt.Errorf("cluster not healthy after upgrading %s: %w", node, err)
+		}
+		log.Infow(fmtNode("✓", node, "upgraded"), "channel", channel)
+	}
+
+	if err := provider.FinalizeUpgrade(ctx, sshPool, managers[0], channel); err != nil {
+		return err
+	}
+
+	log.Infow("✅ storage cluster upgraded", "channel", channel, "nodes", len(pending))
+	return nil
+}